@@ -91,6 +91,9 @@ func main() {
 
 			if len(albumUpdates) > 0 {
 				db.Model(&album).Updates(albumUpdates)
+				if _, labelled := albumUpdates["publisher"]; labelled {
+					database.RefreshAlbumSearch(db, album.ID)
+				}
 			}
 
 			// ⚡️ Append Artists to the Album's Many-to-Many relation
@@ -107,6 +110,9 @@ func main() {
 
 			// ⚡️ Set the Album ID
 			db.Model(&track).Update("album_id", albumID)
+
+			// Artists and album are part of the search document
+			database.RefreshTrackSearch(db, track.ID)
 		}
 	}
 
@@ -5,17 +5,22 @@ import (
 	"flag"
 	"fmt"
 	"log"
+	"os"
+	"os/signal"
+	"syscall"
 
 	"momo-radio/internal/config"
 	database "momo-radio/internal/db"
 	"momo-radio/internal/radio"
 	"momo-radio/internal/storage"
 
+	"github.com/google/uuid"
 	"github.com/redis/go-redis/v9"
 )
 
 func main() {
 	simulate := flag.Bool("simulate", false, "Dry run")
+	orgFlag := flag.String("org", "", "Tenant to simulate (default: all)")
 	flag.Parse()
 
 	cfg := config.Load()
@@ -38,7 +43,22 @@ func main() {
 
 	engine := radio.New(cfg, store, db, rdb)
 
-	// Instead of a single Run(), we start the supervisor daemon
-	ctx := context.Background()
+	if *simulate {
+		var orgID uuid.UUID
+		if *orgFlag != "" {
+			parsed, err := uuid.Parse(*orgFlag)
+			if err != nil {
+				log.Fatalf("Invalid -org: %v", err)
+			}
+			orgID = parsed
+		}
+		engine.Simulate(orgID)
+		return
+	}
+
+	// Instead of a single Run(), we start the supervisor daemon.
+	// SIGTERM hands this node's tenants to the other engines before exiting.
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
 	engine.StartSupervisor(ctx)
 }
@@ -27,6 +27,9 @@ func main() {
 	// 1. Define Flags
 	repairMeta := flag.Bool("repair-metadata", false, "Run metadata enrichment on existing tracks")
 	repairAudio := flag.Bool("repair-audio", false, "Run Essentia analysis on tracks missing BPM/Key")
+	repairFeatures := flag.Bool("repair-features", false, "Rebuild similarity feature vectors from stored analysis")
+	repairLoudness := flag.Bool("repair-loudness", false, "Measure EBU R128 loudness of tracks and imaging missing it")
+	scanDuplicates := flag.Bool("scan-duplicates", false, "Fingerprint the library and queue likely duplicates for review")
 	repairCountry := flag.Bool("repair-country", false, "Run Discogs enrichment on existing tracks")
 	dryRun := flag.Bool("dry-run", false, "Do not save changes to DB (use with repair flags)")
 	repairProvider := flag.String("provider", "musicbrainz", "Metadata provider: 'musicbrainz' or 'discogs'")
@@ -92,7 +95,7 @@ func main() {
 	exportWorker := export.New(cfg, store, db, redisClient)
 
 	// 7. MODE SELECTION (CLI Maintenance)
-	if *repairMeta || *repairAudio || *repairCountry {
+	if *repairMeta || *repairAudio || *repairCountry || *repairFeatures || *repairLoudness || *scanDuplicates {
 		log.Println("MAINTENANCE MODE ACTIVE")
 		log.Printf("Storage Provider: %s", cfg.Storage.Provider)
 
@@ -101,6 +104,21 @@ func main() {
 			ingestWorker.RepairAudio()
 		}
 
+		if *repairFeatures {
+			log.Println("Starting Feature Vector Repair...")
+			ingestWorker.RepairFeatures()
+		}
+
+		if *repairLoudness {
+			log.Println("Starting Loudness Repair...")
+			ingestWorker.RepairLoudness()
+		}
+
+		if *scanDuplicates {
+			log.Println("Starting Duplicate Scan...")
+			ingestWorker.ScanDuplicates()
+		}
+
 		if *repairMeta {
 			log.Println("Starting Metadata Repair...")
 			ingestWorker.RepairMetadata()
@@ -152,6 +170,7 @@ func main() {
 	// 11. Wire the tasks to their respective handlers!
 	mux := asynq.NewServeMux()
 	mux.HandleFunc(ingest.TypeTrackProcess, ingestWorker.HandleProcessTask)
+	mux.HandleFunc(ingest.TypeImagingProcess, ingestWorker.HandleImagingTask)
 	mux.HandleFunc(ingest.TypeArtistEnrich, ingestWorker.HandleArtistEnrichTask)
 	mux.HandleFunc(ingest.TypeTrackEnrich, ingestWorker.HandleTrackEnrichTask)
 
@@ -0,0 +1,232 @@
+package handlers
+
+import (
+	"errors"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+
+	"momo-radio/internal/models"
+)
+
+// duplicateEntry is a review queue item with both tracks flattened for the library view
+type duplicateEntry struct {
+	ID          uint         `json:"id"`
+	Track       LibraryTrack `json:"track"`        // The later upload
+	DuplicateOf LibraryTrack `json:"duplicate_of"` // Already in the library
+	Similarity  float64      `json:"similarity"`
+	Reason      string       `json:"reason"`
+	Status      string       `json:"status"`
+	CreatedAt   time.Time    `json:"created_at"`
+	ResolvedAt  *time.Time   `json:"resolved_at"`
+}
+
+// GetDuplicates lists the duplicate review queue (status=pending by default)
+func (h *TrackHandler) GetDuplicates(c *gin.Context) {
+	orgID, ok := getOrgID(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Organization context missing"})
+		return
+	}
+
+	status := c.DefaultQuery("status", models.DuplicatePending)
+	limit, _ := strconv.Atoi(c.DefaultQuery("limit", "50"))
+	offset, _ := strconv.Atoi(c.DefaultQuery("offset", "0"))
+	if limit <= 0 || limit > 200 {
+		limit = 50
+	}
+
+	// Resolved entries still show the track that was removed
+	withDeleted := func(db *gorm.DB) *gorm.DB { return db.Unscoped() }
+	query := h.db.Model(&models.TrackDuplicate{}).Where("organization_id = ? AND status = ?", orgID, status)
+
+	var total int64
+	query.Count(&total)
+
+	var duplicates []models.TrackDuplicate
+	err := query.
+		Preload("Track", withDeleted).Preload("Track.Artists").Preload("Track.Album").
+		Preload("DuplicateOf", withDeleted).Preload("DuplicateOf.Artists").Preload("DuplicateOf.Album").
+		Order("id DESC").Limit(limit).Offset(offset).
+		Find(&duplicates).Error
+	if err != nil {
+		slog.Error("Failed to fetch duplicates", "error", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Database error"})
+		return
+	}
+
+	data := make([]duplicateEntry, 0, len(duplicates))
+	for _, d := range duplicates {
+		data = append(data, duplicateEntry{
+			ID:          d.ID,
+			Track:       h.toLibraryTrack(d.Track, orgID),
+			DuplicateOf: h.toLibraryTrack(d.DuplicateOf, orgID),
+			Similarity:  d.Similarity,
+			Reason:      d.Reason,
+			Status:      d.Status,
+			CreatedAt:   d.CreatedAt,
+			ResolvedAt:  d.ResolvedAt,
+		})
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"data": data,
+		"meta": gin.H{"total": total, "limit": limit, "offset": offset},
+	})
+}
+
+// GetDuplicateReport summarises the review queue, e.g. after the worker's -scan-duplicates
+func (h *TrackHandler) GetDuplicateReport(c *gin.Context) {
+	orgID, ok := getOrgID(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Organization context missing"})
+		return
+	}
+
+	var rows []struct {
+		Status string `json:"status"`
+		Reason string `json:"reason"`
+		Count  int64  `json:"count"`
+	}
+	if err := h.db.Model(&models.TrackDuplicate{}).
+		Select("status, reason, COUNT(*) AS count").
+		Where("organization_id = ?", orgID).
+		Group("status, reason").Order("status, reason").
+		Scan(&rows).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Database error"})
+		return
+	}
+
+	byStatus := make(map[string]int64)
+	for _, r := range rows {
+		byStatus[r.Status] += r.Count
+	}
+
+	// Tracks without a fingerprint are only compared by artist and title
+	var unfingerprinted int64
+	h.db.Model(&models.Track{}).
+		Where("organization_id = ? AND kind = ? AND fingerprint IS NULL", orgID, models.TrackKindMusic).
+		Count(&unfingerprinted)
+
+	c.JSON(http.StatusOK, gin.H{
+		"by_status":       byStatus,
+		"breakdown":       rows,
+		"unfingerprinted": unfingerprinted,
+	})
+}
+
+// ResolveDuplicate applies an editor's decision: "merge" folds the later upload into the original
+// (playlists, history and play count), "reject" removes it, "keep_both" dismisses the flag
+func (h *TrackHandler) ResolveDuplicate(c *gin.Context) {
+	orgID, ok := getOrgID(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Organization context missing"})
+		return
+	}
+
+	var input struct {
+		Action string `json:"action" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "action is required"})
+		return
+	}
+
+	status, valid := map[string]string{
+		"merge":     models.DuplicateMerged,
+		"reject":    models.DuplicateRejected,
+		"keep_both": models.DuplicateKept,
+	}[input.Action]
+	if !valid {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "action must be merge, reject or keep_both"})
+		return
+	}
+
+	var entry models.TrackDuplicate
+	if err := h.db.Where("id = ? AND organization_id = ?", c.Param("id"), orgID).First(&entry).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Duplicate not found"})
+		return
+	}
+	if entry.Status != models.DuplicatePending {
+		c.JSON(http.StatusConflict, gin.H{"error": "Duplicate was already resolved"})
+		return
+	}
+
+	now := time.Now()
+	remove, keep := entry.TrackID, entry.DuplicateOfID
+
+	err := h.db.Transaction(func(tx *gorm.DB) error {
+		if status != models.DuplicateKept {
+			if err := removeDuplicateTrack(tx, entry, status == models.DuplicateMerged); err != nil {
+				return err
+			}
+
+			// Other flags on the removed track are settled by the same decision
+			if err := tx.Model(&models.TrackDuplicate{}).
+				Where("organization_id = ? AND status = ? AND id <> ? AND (track_id = ? OR duplicate_of_id = ?)",
+					orgID, models.DuplicatePending, entry.ID, remove, remove).
+				Updates(map[string]any{"status": status, "resolved_at": now}).Error; err != nil {
+				return err
+			}
+		}
+
+		return tx.Model(&entry).Updates(map[string]any{"status": status, "resolved_at": now}).Error
+	})
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Track not found"})
+			return
+		}
+		slog.Error("Failed to resolve duplicate", "duplicate_id", entry.ID, "error", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to resolve duplicate"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Duplicate resolved", "status": status, "kept_track_id": keep})
+}
+
+// removeDuplicateTrack soft-deletes the later upload of a pair. When merging, whatever pointed at it
+// (playlists, queue, play history) points at the original instead.
+func removeDuplicateTrack(tx *gorm.DB, entry models.TrackDuplicate, merge bool) error {
+	orgID, remove, keep := entry.OrganizationID, entry.TrackID, entry.DuplicateOfID
+
+	var removed models.Track
+	if err := tx.Where("organization_id = ?", orgID).First(&removed, remove).Error; err != nil {
+		return err
+	}
+
+	if merge {
+		// A playlist holding both keeps the original's position
+		if err := tx.Exec("UPDATE playlist_tracks SET track_id = ? WHERE track_id = ? AND playlist_id NOT IN "+
+			"(SELECT playlist_id FROM playlist_tracks WHERE track_id = ?)", keep, remove, keep).Error; err != nil {
+			return err
+		}
+		if err := tx.Model(&models.QueueItem{}).Where("organization_id = ? AND track_id = ?", orgID, remove).
+			Update("track_id", keep).Error; err != nil {
+			return err
+		}
+		if err := tx.Model(&models.PlayHistory{}).Where("organization_id = ? AND track_id = ?", orgID, remove).
+			Update("track_id", keep).Error; err != nil {
+			return err
+		}
+		if err := tx.Model(&models.Track{}).Where("organization_id = ? AND id = ?", orgID, keep).Updates(map[string]any{
+			"play_count":  gorm.Expr("play_count + ?", removed.PlayCount),
+			"last_played": gorm.Expr("GREATEST(last_played, ?)", removed.LastPlayed),
+		}).Error; err != nil {
+			return err
+		}
+	} else {
+		if err := tx.Where("organization_id = ? AND track_id = ?", orgID, remove).Delete(&models.QueueItem{}).Error; err != nil {
+			return err
+		}
+	}
+
+	if err := tx.Where("track_id = ?", remove).Delete(&models.PlaylistTrack{}).Error; err != nil {
+		return err
+	}
+	return tx.Delete(&removed).Error
+}
@@ -0,0 +1,308 @@
+package handlers
+
+import (
+	"crypto/tls"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"momo-radio/internal/audio"
+	"momo-radio/internal/config"
+	database "momo-radio/internal/db"
+	"momo-radio/internal/models"
+	"momo-radio/internal/storage"
+
+	"github.com/gin-gonic/gin"
+	"github.com/hibiken/asynq"
+	"gorm.io/gorm"
+)
+
+// ImagingHandler manages station imaging (jingles, IDs, sweepers, promos) and the rules that insert it
+type ImagingHandler struct {
+	db      *gorm.DB
+	storage *storage.Client
+	config  *config.Config
+}
+
+func NewImagingHandler(db *gorm.DB, st *storage.Client, c *config.Config) *ImagingHandler {
+	return &ImagingHandler{db: db, storage: st, config: c}
+}
+
+// insertionRuleInput is shared by create and update; nil fields are left untouched on update
+type insertionRuleInput struct {
+	Name           *string `json:"name"`
+	Enabled        *bool   `json:"enabled"`
+	RuleSetID      *uint   `json:"ruleset_id"`
+	ScheduleSlotID *uint   `json:"schedule_slot_id"`
+	Kind           *string `json:"kind"`
+	Trigger        *string `json:"trigger"`
+	Value          *int    `json:"value"`
+	TalkOver       *bool   `json:"talk_over"`
+}
+
+// UploadImaging stores an imaging file and sends it through the lighter imaging ingest pipeline.
+// Listed afterwards with GET /tracks?kind=<kind>.
+func (h *ImagingHandler) UploadImaging(c *gin.Context) {
+	orgID, ok := getOrgID(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Organization context missing"})
+		return
+	}
+
+	kind := c.PostForm("kind")
+	if !models.IsImagingKind(kind) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "kind must be jingle, station_id, sweeper, promo or fallback"})
+		return
+	}
+
+	fileHeader, err := c.FormFile("file")
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "No file uploaded"})
+		return
+	}
+	if !audio.IsSupportedFormat(strings.ToLower(fileHeader.Filename)) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Unsupported audio format"})
+		return
+	}
+
+	file, err := fileHeader.Open()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "File open error"})
+		return
+	}
+	defer file.Close()
+
+	safeFilename := strings.ReplaceAll(filepath.Base(fileHeader.Filename), " ", "_")
+	b2Key := fmt.Sprintf("incoming/%s/%s/%d_%s", orgID.String(), kind, time.Now().Unix(), safeFilename)
+
+	if err := h.storage.UploadIngestFile(b2Key, file, fileHeader.Header.Get("Content-Type")); err != nil {
+		slog.Error("UploadIngestFile failed", "key", b2Key, "error", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": fmt.Sprintf("Storage upload failed: %v", err)})
+		return
+	}
+
+	title := strings.TrimSpace(c.PostForm("title"))
+	if title == "" {
+		title = strings.TrimSuffix(safeFilename, filepath.Ext(safeFilename))
+	}
+
+	asset := models.Track{
+		OrganizationID:   orgID,
+		Title:            title,
+		Kind:             kind,
+		Key:              b2Key,
+		MasterKey:        b2Key,
+		ProcessingStatus: "pending",
+	}
+	if err := h.db.Create(&asset).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Database insert failed"})
+		return
+	}
+	database.RefreshTrackSearch(h.db, asset.ID)
+
+	redisAddr := fmt.Sprintf("%s:%s", h.config.Redis.Host, h.config.Redis.Port)
+
+	var tlsConf *tls.Config
+	if h.config.Redis.TLS {
+		tlsConf = &tls.Config{MinVersion: tls.VersionTLS12}
+	}
+
+	asynqClient := asynq.NewClient(asynq.RedisClientOpt{
+		Addr:      redisAddr,
+		Password:  h.config.Redis.Password,
+		DB:        h.config.Redis.DB,
+		TLSConfig: tlsConf,
+	})
+	defer asynqClient.Close()
+
+	payloadBytes, _ := json.Marshal(map[string]any{
+		"track_id": asset.ID,
+		"file_key": b2Key,
+	})
+	if _, err := asynqClient.Enqueue(asynq.NewTask("imaging:process", payloadBytes)); err != nil {
+		slog.Error("Failed to queue imaging job", "error", err)
+		h.db.Model(&asset).Update("processing_status", "failed")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to queue processing job"})
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{
+		"status":   "queued",
+		"track_id": asset.ID,
+		"kind":     kind,
+	})
+}
+
+func (h *ImagingHandler) GetInsertionRules(c *gin.Context) {
+	orgID, ok := getOrgID(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Organization context missing"})
+		return
+	}
+
+	var rules []models.InsertionRule
+	if err := h.db.Where("organization_id = ?", orgID).Order("id ASC").Find(&rules).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch insertion rules"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"data": rules})
+}
+
+func (h *ImagingHandler) CreateInsertionRule(c *gin.Context) {
+	orgID, ok := getOrgID(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Organization context missing"})
+		return
+	}
+
+	var input insertionRuleInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	rule := models.InsertionRule{OrganizationID: orgID, Enabled: true}
+	if err := h.applyInsertionRuleInput(&rule, input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if err := h.db.Create(&rule).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create insertion rule"})
+		return
+	}
+
+	c.JSON(http.StatusCreated, rule)
+}
+
+func (h *ImagingHandler) UpdateInsertionRule(c *gin.Context) {
+	orgID, ok := getOrgID(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Organization context missing"})
+		return
+	}
+
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid insertion rule ID"})
+		return
+	}
+
+	var rule models.InsertionRule
+	if err := h.db.Where("organization_id = ?", orgID).First(&rule, id).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Insertion rule not found"})
+		return
+	}
+
+	var input insertionRuleInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if err := h.applyInsertionRuleInput(&rule, input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	// Save writes the zero values too (enabled=false, cleared scope)
+	if err := h.db.Save(&rule).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update insertion rule"})
+		return
+	}
+
+	c.JSON(http.StatusOK, rule)
+}
+
+func (h *ImagingHandler) DeleteInsertionRule(c *gin.Context) {
+	orgID, ok := getOrgID(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Organization context missing"})
+		return
+	}
+
+	result := h.db.Where("id = ? AND organization_id = ?", c.Param("id"), orgID).Delete(&models.InsertionRule{})
+	if result.Error != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete insertion rule"})
+		return
+	}
+	if result.RowsAffected == 0 {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Insertion rule not found"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Insertion rule deleted"})
+}
+
+// applyInsertionRuleInput validates the rule, including that its scope belongs to the tenant.
+// A scope id of 0 clears it (station-wide).
+func (h *ImagingHandler) applyInsertionRuleInput(r *models.InsertionRule, input insertionRuleInput) error {
+	if input.Name != nil {
+		r.Name = strings.TrimSpace(*input.Name)
+	}
+	if input.Enabled != nil {
+		r.Enabled = *input.Enabled
+	}
+	if input.RuleSetID != nil {
+		r.RuleSetID = input.RuleSetID
+		if *input.RuleSetID == 0 {
+			r.RuleSetID = nil
+		}
+	}
+	if input.ScheduleSlotID != nil {
+		r.ScheduleSlotID = input.ScheduleSlotID
+		if *input.ScheduleSlotID == 0 {
+			r.ScheduleSlotID = nil
+		}
+	}
+	if input.Kind != nil {
+		r.Kind = *input.Kind
+	}
+	if input.Trigger != nil {
+		r.Trigger = *input.Trigger
+	}
+	if input.Value != nil {
+		r.Value = *input.Value
+	}
+	if input.TalkOver != nil {
+		r.TalkOver = *input.TalkOver
+	}
+
+	if !models.IsImagingKind(r.Kind) || r.Kind == models.TrackKindFallback {
+		return fmt.Errorf("kind must be jingle, station_id, sweeper or promo")
+	}
+	switch r.Trigger {
+	case models.TriggerInterval, models.TriggerEveryN:
+		if r.Value < 1 {
+			return fmt.Errorf("value must be at least 1 for the %s trigger", r.Trigger)
+		}
+	case models.TriggerTopOfHour:
+		if r.Value < 0 || r.Value > 59 {
+			return fmt.Errorf("value (minutes late allowed) must be between 0 and 59")
+		}
+	default:
+		return fmt.Errorf("trigger must be interval, every_tracks or top_of_hour")
+	}
+
+	if r.RuleSetID != nil {
+		var count int64
+		h.db.Model(&models.RuleSet{}).Where("id = ? AND organization_id = ?", *r.RuleSetID, r.OrganizationID).Count(&count)
+		if count == 0 {
+			return fmt.Errorf("rule set not found")
+		}
+	}
+	if r.ScheduleSlotID != nil {
+		var count int64
+		h.db.Model(&models.ScheduleSlot{}).Where("id = ? AND organization_id = ?", *r.ScheduleSlotID, r.OrganizationID).Count(&count)
+		if count == 0 {
+			return fmt.Errorf("schedule slot not found")
+		}
+	}
+	return nil
+}
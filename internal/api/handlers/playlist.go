@@ -3,7 +3,10 @@ package handlers
 import (
 	"net/http"
 	"strconv"
+	"strings"
+	"time"
 
+	"momo-radio/internal/dj"
 	"momo-radio/internal/models"
 	"momo-radio/internal/storage"
 	"momo-radio/internal/utils"
@@ -296,3 +299,117 @@ func (h *PlaylistHandler) DeletePlaylist(c *gin.Context) {
 
 	c.JSON(http.StatusOK, gin.H{"message": "Playlist deleted successfully"})
 }
+
+// GeneratePlaylist builds a playlist from a seed track and/or a rule set. It returns a draft to review,
+// or saves it as a normal playlist when "save" is set.
+func (h *PlaylistHandler) GeneratePlaylist(c *gin.Context) {
+	orgID, ok := getOrgID(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Organization context missing"})
+		return
+	}
+
+	var input struct {
+		SeedTrackID     *uint  `json:"seed_track_id"`
+		RuleSetID       *uint  `json:"ruleset_id"`
+		DurationMinutes int    `json:"duration_minutes"`
+		Save            bool   `json:"save"`
+		Name            string `json:"name"`
+		Color           string `json:"color"`
+	}
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if input.SeedTrackID == nil && input.RuleSetID == nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "seed_track_id or ruleset_id is required"})
+		return
+	}
+	if input.DurationMinutes == 0 {
+		input.DurationMinutes = 60
+	}
+	if input.DurationMinutes < 1 || input.DurationMinutes > 24*60 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "duration_minutes must be between 1 and 1440"})
+		return
+	}
+	if input.Save && strings.TrimSpace(input.Name) == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "name is required to save the playlist"})
+		return
+	}
+
+	// ⚡️ Seed and rule set must both belong to the Tenant
+	opts := dj.GenerateOptions{Duration: time.Duration(input.DurationMinutes) * time.Minute}
+	if input.SeedTrackID != nil {
+		var seed models.Track
+		if err := h.db.Preload("Artists").Preload("Album").
+			Where("organization_id = ? AND kind = ?", orgID, models.TrackKindMusic).
+			First(&seed, *input.SeedTrackID).Error; err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Seed track not found"})
+			return
+		}
+		opts.Seed = &seed
+	}
+	if input.RuleSetID != nil {
+		var ruleSet models.RuleSet
+		if err := h.db.Where("organization_id = ?", orgID).First(&ruleSet, *input.RuleSetID).Error; err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Rule set not found"})
+			return
+		}
+		opts.Rules = &ruleSet
+	}
+
+	tracks, err := dj.GeneratePlaylist(h.db, orgID, opts)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate playlist"})
+		return
+	}
+	if len(tracks) == 0 {
+		c.JSON(http.StatusUnprocessableEntity, gin.H{"error": "No tracks match"})
+		return
+	}
+
+	trackIDs := make([]uint, len(tracks))
+	totalDuration := 0
+	for i := range tracks {
+		trackIDs[i] = tracks[i].ID
+		totalDuration += int(tracks[i].Duration)
+		if tracks[i].Album.ID != 0 && tracks[i].Album.CoverKey != "" {
+			tracks[i].Album.CoverURL = h.cdn.BuildAssetURL(tracks[i].Album.CoverKey, orgID.String())
+		}
+	}
+
+	if !input.Save {
+		c.JSON(http.StatusOK, gin.H{
+			"status":         "draft",
+			"track_ids":      trackIDs,
+			"tracks":         tracks,
+			"total_duration": totalDuration,
+		})
+		return
+	}
+
+	playlist := models.Playlist{
+		OrganizationID: orgID,
+		Name:           strings.TrimSpace(input.Name),
+		Color:          input.Color,
+		TotalDuration:  totalDuration,
+	}
+	err = h.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Omit("Tracks").Create(&playlist).Error; err != nil {
+			return err
+		}
+		for i, trackID := range trackIDs {
+			if err := tx.Create(&models.PlaylistTrack{PlaylistID: playlist.ID, TrackID: trackID, SortOrder: i}).Error; err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to save playlist"})
+		return
+	}
+
+	playlist.Tracks = tracks
+	c.JSON(http.StatusCreated, playlist)
+}
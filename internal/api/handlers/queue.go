@@ -0,0 +1,277 @@
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+
+	"momo-radio/internal/models"
+)
+
+// GetQueue lists the tracks lined up after the one on air
+func (h *BroadcastHandler) GetQueue(c *gin.Context) {
+	orgID, ok := getOrgID(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Organization context missing"})
+		return
+	}
+
+	items, err := h.loadQueue(h.db, orgID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch queue"})
+		return
+	}
+
+	for i := range items {
+		if items[i].Track.Album.CoverKey != "" {
+			items[i].Track.Album.CoverURL = h.cdn.BuildAssetURL(items[i].Track.Album.CoverKey, orgID.String())
+		}
+	}
+
+	c.JSON(http.StatusOK, gin.H{"data": items})
+}
+
+// AddToQueue inserts a track requested by an editor.
+// Without a position it goes after the other requests, ahead of the AutoDJ picks.
+func (h *BroadcastHandler) AddToQueue(c *gin.Context) {
+	orgID, ok := getOrgID(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Organization context missing"})
+		return
+	}
+
+	var input struct {
+		TrackID  uint `json:"track_id" binding:"required"`
+		Position *int `json:"position"` // 0 plays next
+		Pinned   bool `json:"pinned"`
+	}
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	// 1. Only playable tracks from the tenant's own library
+	var track models.Track
+	if err := h.db.Where("id = ? AND organization_id = ?", input.TrackID, orgID).First(&track).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Track not found"})
+		return
+	}
+	if track.ProcessingStatus == "failed" || track.Key == "" {
+		c.JSON(http.StatusConflict, gin.H{"error": "Track is not playable"})
+		return
+	}
+
+	item := models.QueueItem{
+		OrganizationID: orgID,
+		TrackID:        track.ID,
+		Source:         models.QueueSourceRequest,
+		Pinned:         input.Pinned,
+	}
+
+	// 2. Splice it in and renumber
+	err := h.db.Transaction(func(tx *gorm.DB) error {
+		items, err := h.loadQueue(tx, orgID)
+		if err != nil {
+			return err
+		}
+
+		index := 0
+		for i, it := range items {
+			if it.Source == models.QueueSourceRequest {
+				index = i + 1
+			}
+		}
+		if input.Position != nil {
+			index = max(0, min(*input.Position, len(items)))
+		}
+
+		if err := tx.Create(&item).Error; err != nil {
+			return err
+		}
+		items = append(items[:index], append([]models.QueueItem{item}, items[index:]...)...)
+		return renumberQueue(tx, orgID, items)
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update queue"})
+		return
+	}
+
+	h.notifyQueueChanged(c, orgID)
+	c.JSON(http.StatusCreated, item)
+}
+
+// ReorderQueue applies the order of the given item ids; items not listed keep their relative order after them
+func (h *BroadcastHandler) ReorderQueue(c *gin.Context) {
+	orgID, ok := getOrgID(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Organization context missing"})
+		return
+	}
+
+	var input struct {
+		Order []uint `json:"order" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	err := h.db.Transaction(func(tx *gorm.DB) error {
+		items, err := h.loadQueue(tx, orgID)
+		if err != nil {
+			return err
+		}
+
+		byID := make(map[uint]models.QueueItem, len(items))
+		for _, it := range items {
+			byID[it.ID] = it
+		}
+
+		ordered := make([]models.QueueItem, 0, len(items))
+		for _, id := range input.Order {
+			it, exists := byID[id]
+			if !exists {
+				// Already played or not ours
+				continue
+			}
+			ordered = append(ordered, it)
+			delete(byID, id)
+		}
+		for _, it := range items {
+			if _, rest := byID[it.ID]; rest {
+				ordered = append(ordered, it)
+			}
+		}
+		return renumberQueue(tx, orgID, ordered)
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to reorder queue"})
+		return
+	}
+
+	h.notifyQueueChanged(c, orgID)
+	h.GetQueue(c)
+}
+
+// UpdateQueueItem pins/unpins an item or moves it to a new position
+func (h *BroadcastHandler) UpdateQueueItem(c *gin.Context) {
+	orgID, ok := getOrgID(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Organization context missing"})
+		return
+	}
+
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid queue item ID"})
+		return
+	}
+
+	var input struct {
+		Pinned   *bool `json:"pinned"`
+		Position *int  `json:"position"`
+	}
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	err = h.db.Transaction(func(tx *gorm.DB) error {
+		items, err := h.loadQueue(tx, orgID)
+		if err != nil {
+			return err
+		}
+
+		index := -1
+		for i := range items {
+			if items[i].ID == uint(id) {
+				index = i
+			}
+		}
+		if index < 0 {
+			return gorm.ErrRecordNotFound
+		}
+
+		if input.Pinned != nil {
+			items[index].Pinned = *input.Pinned
+			if err := tx.Model(&models.QueueItem{}).Where("id = ?", id).Update("pinned", *input.Pinned).Error; err != nil {
+				return err
+			}
+		}
+
+		if input.Position != nil {
+			it := items[index]
+			items = append(items[:index], items[index+1:]...)
+			to := max(0, min(*input.Position, len(items)))
+			items = append(items[:to], append([]models.QueueItem{it}, items[to:]...)...)
+			return renumberQueue(tx, orgID, items)
+		}
+		return nil
+	})
+	if err == gorm.ErrRecordNotFound {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Queue item not found"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update queue item"})
+		return
+	}
+
+	h.notifyQueueChanged(c, orgID)
+	h.GetQueue(c)
+}
+
+// RemoveFromQueue drops an upcoming item; AutoDJ tops the queue back up on the next transition
+func (h *BroadcastHandler) RemoveFromQueue(c *gin.Context) {
+	orgID, ok := getOrgID(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Organization context missing"})
+		return
+	}
+
+	result := h.db.Where("id = ? AND organization_id = ?", c.Param("id"), orgID).Delete(&models.QueueItem{})
+	if result.Error != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to remove queue item"})
+		return
+	}
+	if result.RowsAffected == 0 {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Queue item not found"})
+		return
+	}
+
+	h.notifyQueueChanged(c, orgID)
+	c.JSON(http.StatusOK, gin.H{"message": "Removed from queue"})
+}
+
+func (h *BroadcastHandler) loadQueue(db *gorm.DB, orgID uuid.UUID) ([]models.QueueItem, error) {
+	var items []models.QueueItem
+	err := db.Preload("Track").Preload("Track.Artists").Preload("Track.Album").
+		Where("organization_id = ?", orgID).
+		Order("position ASC, id ASC").
+		Find(&items).Error
+	return items, err
+}
+
+// renumberQueue persists the slice order as positions 0..n-1
+func renumberQueue(tx *gorm.DB, orgID uuid.UUID, items []models.QueueItem) error {
+	for i := range items {
+		items[i].Position = i
+		err := tx.Model(&models.QueueItem{}).
+			Where("id = ? AND organization_id = ?", items[i].ID, orgID).
+			Update("position", i).Error
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// notifyQueueChanged lets the engine download newly queued tracks right away
+func (h *BroadcastHandler) notifyQueueChanged(c *gin.Context, orgID uuid.UUID) {
+	payload := fmt.Sprintf(`{"org_id": "%s", "action": "queue"}`, orgID)
+	h.rdb.Publish(c.Request.Context(), "radio.control", payload)
+}
@@ -0,0 +1,335 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"momo-radio/internal/audio"
+	"momo-radio/internal/dj"
+	"momo-radio/internal/models"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// RuleSetHandler manages the AutoDJ selection rules that schedule slots can point to
+type RuleSetHandler struct {
+	db *gorm.DB
+}
+
+func NewRuleSetHandler(db *gorm.DB) *RuleSetHandler {
+	return &RuleSetHandler{db: db}
+}
+
+// ruleSetInput is shared by create and update; nil fields are left untouched on update
+type ruleSetInput struct {
+	Name             *string  `json:"name"`
+	Mode             *string  `json:"mode"`
+	Genre            *string  `json:"genre"`
+	Styles           *string  `json:"styles"`
+	MinBPM           *float64 `json:"min_bpm"`
+	MaxBPM           *float64 `json:"max_bpm"`
+	MinYear          *int     `json:"min_year"`
+	MaxYear          *int     `json:"max_year"`
+	CrossfadeSeconds *float64 `json:"crossfade_seconds"`
+	CrossfadeCurve   *string  `json:"crossfade_curve"`
+
+	Filter *models.RuleFilter   `json:"filter"` // Replaces the whole filter expression
+	Curve  *[]models.CurvePoint `json:"curve"`  // Replaces the whole curve, [] removes it
+
+	// An object overrides the station's separation policy, null goes back to inheriting it
+	Separation json.RawMessage `json:"separation"`
+}
+
+func (h *RuleSetHandler) GetRuleSets(c *gin.Context) {
+	orgID, ok := getOrgID(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Organization context missing"})
+		return
+	}
+
+	var ruleSets []models.RuleSet
+	if err := h.db.Where("organization_id = ?", orgID).Order("name ASC").Find(&ruleSets).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch rule sets"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"data": ruleSets})
+}
+
+func (h *RuleSetHandler) GetRuleSet(c *gin.Context) {
+	orgID, ok := getOrgID(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Organization context missing"})
+		return
+	}
+
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid rule set ID"})
+		return
+	}
+
+	var ruleSet models.RuleSet
+	if err := h.db.Where("organization_id = ?", orgID).First(&ruleSet, id).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Rule set not found"})
+		return
+	}
+
+	c.JSON(http.StatusOK, ruleSet)
+}
+
+func (h *RuleSetHandler) CreateRuleSet(c *gin.Context) {
+	// ⚡️ 1. Extract Tenant ID
+	orgID, ok := getOrgID(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Organization context missing"})
+		return
+	}
+
+	var input ruleSetInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	// 2. Bind the new rule set to the Tenant
+	ruleSet := models.RuleSet{OrganizationID: orgID, Mode: "starvation"}
+	if err := applyRuleSetInput(&ruleSet, input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if h.nameTaken(&ruleSet) {
+		c.JSON(http.StatusConflict, gin.H{"error": "A rule set with this name already exists"})
+		return
+	}
+
+	if err := h.db.Create(&ruleSet).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create rule set"})
+		return
+	}
+
+	c.JSON(http.StatusCreated, ruleSet)
+}
+
+func (h *RuleSetHandler) UpdateRuleSet(c *gin.Context) {
+	orgID, ok := getOrgID(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Organization context missing"})
+		return
+	}
+
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid rule set ID"})
+		return
+	}
+
+	var ruleSet models.RuleSet
+	if err := h.db.Where("organization_id = ?", orgID).First(&ruleSet, id).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Rule set not found"})
+		return
+	}
+
+	var input ruleSetInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if err := applyRuleSetInput(&ruleSet, input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if h.nameTaken(&ruleSet) {
+		c.JSON(http.StatusConflict, gin.H{"error": "A rule set with this name already exists"})
+		return
+	}
+
+	if err := h.db.Save(&ruleSet).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update rule set"})
+		return
+	}
+
+	c.JSON(http.StatusOK, ruleSet)
+}
+
+// PreviewRuleSet counts the tracks a rule set would select. The body is a (possibly unsaved) rule set;
+// with ?id= it is applied on top of that saved rule set, so editors can try a change before saving it.
+func (h *RuleSetHandler) PreviewRuleSet(c *gin.Context) {
+	orgID, ok := getOrgID(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Organization context missing"})
+		return
+	}
+
+	ruleSet := models.RuleSet{OrganizationID: orgID, Name: "preview", Mode: "starvation"}
+	if id := c.Query("id"); id != "" {
+		if err := h.db.Where("organization_id = ?", orgID).First(&ruleSet, id).Error; err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Rule set not found"})
+			return
+		}
+	}
+
+	var input ruleSetInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	input.Name = nil // Not needed to preview
+	if err := applyRuleSetInput(&ruleSet, input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	var matches, library int64
+	if err := dj.MatchingTracks(h.db.Model(&models.Track{}), &ruleSet, orgID).Count(&matches).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to evaluate rule set"})
+		return
+	}
+	dj.MatchingTracks(h.db.Model(&models.Track{}), nil, orgID).Count(&library)
+
+	var sample []models.Track
+	dj.MatchingTracks(h.db.Model(&models.Track{}), &ruleSet, orgID).
+		Preload("Artists").Order("RANDOM()").Limit(5).Find(&sample)
+
+	c.JSON(http.StatusOK, gin.H{
+		"matches": matches,
+		"library": library,
+		"sample":  sample,
+	})
+}
+
+// DeleteRuleSet refuses to remove a rule set that schedule slots still point to
+func (h *RuleSetHandler) DeleteRuleSet(c *gin.Context) {
+	orgID, ok := getOrgID(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Organization context missing"})
+		return
+	}
+
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid ID format"})
+		return
+	}
+
+	var inUse int64
+	h.db.Model(&models.ScheduleSlot{}).Where("organization_id = ? AND rule_set_id = ?", orgID, id).Count(&inUse)
+	if inUse > 0 {
+		c.JSON(http.StatusConflict, gin.H{"error": fmt.Sprintf("Rule set is used by %d schedule slot(s)", inUse)})
+		return
+	}
+
+	result := h.db.Where("id = ? AND organization_id = ?", id, orgID).Delete(&models.RuleSet{})
+	if result.Error != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete rule set"})
+		return
+	}
+	if result.RowsAffected == 0 {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Rule set not found"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Rule set deleted", "id": id})
+}
+
+func (h *RuleSetHandler) nameTaken(ruleSet *models.RuleSet) bool {
+	var count int64
+	h.db.Model(&models.RuleSet{}).
+		Where("organization_id = ? AND name = ? AND id <> ?", ruleSet.OrganizationID, ruleSet.Name, ruleSet.ID).
+		Count(&count)
+	return count > 0
+}
+
+func applyRuleSetInput(r *models.RuleSet, input ruleSetInput) error {
+	if input.Name != nil {
+		r.Name = strings.TrimSpace(*input.Name)
+	}
+	if input.Mode != nil {
+		r.Mode = strings.ToLower(*input.Mode)
+	}
+	if input.Genre != nil {
+		r.Genre = *input.Genre
+	}
+	if input.Styles != nil {
+		r.Styles = *input.Styles
+	}
+	if input.MinBPM != nil {
+		r.MinBPM = *input.MinBPM
+	}
+	if input.MaxBPM != nil {
+		r.MaxBPM = *input.MaxBPM
+	}
+	if input.MinYear != nil {
+		r.MinYear = *input.MinYear
+	}
+	if input.MaxYear != nil {
+		r.MaxYear = *input.MaxYear
+	}
+	if input.CrossfadeSeconds != nil {
+		r.CrossfadeSeconds = input.CrossfadeSeconds
+		if *input.CrossfadeSeconds < 0 {
+			r.CrossfadeSeconds = nil // Negative clears the override
+		}
+	}
+	if input.CrossfadeCurve != nil {
+		r.CrossfadeCurve = *input.CrossfadeCurve
+	}
+	if input.Filter != nil {
+		r.Filter = *input.Filter
+	}
+	if input.Curve != nil {
+		r.Curve = *input.Curve
+	}
+	if len(input.Separation) > 0 {
+		r.Separation = nil
+		if string(input.Separation) != "null" {
+			var sep models.Separation
+			if err := json.Unmarshal(input.Separation, &sep); err != nil {
+				return fmt.Errorf("invalid separation: %v", err)
+			}
+			if err := dj.ValidateSeparation(sep); err != nil {
+				return err
+			}
+			r.Separation = &sep
+		}
+	}
+
+	if r.Name == "" {
+		return fmt.Errorf("name is required")
+	}
+	switch r.Mode {
+	case "starvation", "harmonic", "random":
+	case "curve":
+		if len(r.Curve) == 0 {
+			return fmt.Errorf("curve mode needs at least one curve point")
+		}
+	default:
+		return fmt.Errorf("mode must be starvation, harmonic, random or curve")
+	}
+	if err := dj.ValidateCurve(r.Curve); err != nil {
+		return err
+	}
+	if r.MinBPM < 0 || r.MaxBPM < 0 || (r.MaxBPM > 0 && r.MinBPM > r.MaxBPM) {
+		return fmt.Errorf("invalid BPM range")
+	}
+	if r.MinYear < 0 || r.MaxYear < 0 || (r.MaxYear > 0 && r.MinYear > r.MaxYear) {
+		return fmt.Errorf("invalid year range")
+	}
+	if err := dj.ValidateFilter(&r.Filter); err != nil {
+		return err
+	}
+	if r.CrossfadeSeconds != nil && *r.CrossfadeSeconds > 20 {
+		return fmt.Errorf("crossfade_seconds must be between 0 and 20")
+	}
+	if r.CrossfadeCurve != "" && !audio.IsValidCurve(r.CrossfadeCurve) {
+		return fmt.Errorf("crossfade_curve must be linear, equal_power or s_curve")
+	}
+	return nil
+}
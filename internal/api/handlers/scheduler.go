@@ -1,24 +1,48 @@
 package handlers
 
 import (
-	"log"
+	"fmt"
 	"momo-radio/internal/config"
 	"momo-radio/internal/models"
+	"momo-radio/internal/scheduler"
 	"net/http"
 	"strconv"
+	"strings"
 	"time"
 
 	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
 	"gorm.io/gorm"
 )
 
 type SchedulerHandler struct {
-	db  *gorm.DB
-	cfg *config.Config
+	db        *gorm.DB
+	cfg       *config.Config
+	timezones *scheduler.Timezones
 }
 
-func NewSchedulerHandler(db *gorm.DB, cfg *config.Config) *SchedulerHandler {
-	return &SchedulerHandler{db: db, cfg: cfg}
+func NewSchedulerHandler(db *gorm.DB, cfg *config.Config, tz *scheduler.Timezones) *SchedulerHandler {
+	return &SchedulerHandler{db: db, cfg: cfg, timezones: tz}
+}
+
+// scheduleSlotInput is shared by create and update; nil fields are left untouched on update.
+// start_time/end_time accept "HH:MM", or a full datetime which also sets the date.
+type scheduleSlotInput struct {
+	Name         *string `json:"name"`
+	PlaylistID   *uint   `json:"playlist_id"`
+	RuleSetID    *uint   `json:"ruleset_id"`
+	ScheduleType *string `json:"schedule_type"`
+	StartTime    *string `json:"start_time"`
+	EndTime      *string `json:"end_time"`
+	Date         *string `json:"date"`
+	Days         *string `json:"days"`
+	StartDate    *string `json:"start_date"`
+	EndDate      *string `json:"end_date"`
+	IsActive     *bool   `json:"is_active"`
+	IsReplay     *bool   `json:"is_replay"`
+	StartMode    *string `json:"start_mode"`
+	FitTolerance *int    `json:"fit_tolerance_seconds"`
+	PlaybackMode *string `json:"playback_mode"`
 }
 
 func (h *SchedulerHandler) CreateScheduleSlot(c *gin.Context) {
@@ -29,84 +53,259 @@ func (h *SchedulerHandler) CreateScheduleSlot(c *gin.Context) {
 		return
 	}
 
-	var input struct {
-		PlaylistID   uint   `json:"playlist_id" binding:"required"`
-		StartTime    string `json:"start_time" binding:"required"`
-		ScheduleType string `json:"schedule_type"`
+	var input scheduleSlotInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if input.StartTime == nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "start_time is required"})
+		return
+	}
+
+	slot := models.ScheduleSlot{
+		OrganizationID: orgID, // 2. Bind the Slot to the Tenant
+		ScheduleType:   scheduler.TypeOneTime,
+		IsActive:       true,
+	}
+
+	if status, err := h.applySlotInput(orgID, &slot, input); err != nil {
+		c.JSON(status, gin.H{"error": err.Error()})
+		return
+	}
+
+	// 3. Reject double bookings
+	if conflicts := h.findConflicts(orgID, &slot); len(conflicts) > 0 {
+		c.JSON(http.StatusConflict, gin.H{"error": "Slot overlaps existing schedule entries", "conflicts": conflicts})
+		return
+	}
+
+	if err := h.db.Create(&slot).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to save schedule"})
+		return
 	}
 
+	c.JSON(http.StatusCreated, slot)
+}
+
+func (h *SchedulerHandler) UpdateScheduleSlot(c *gin.Context) {
+	orgID, ok := getOrgID(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Organization context missing"})
+		return
+	}
+
+	var slot models.ScheduleSlot
+	if err := h.db.Where("id = ? AND organization_id = ?", c.Param("id"), orgID).First(&slot).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Slot not found or unauthorized"})
+		return
+	}
+
+	var input scheduleSlotInput
 	if err := c.ShouldBindJSON(&input); err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
 
-	if input.ScheduleType == "" {
-		input.ScheduleType = "one_time"
+	// Switching target type: the other one is cleared, except for play_once where the RuleSet follows the playlist
+	mode := slot.PlaybackMode
+	if input.PlaybackMode != nil {
+		mode = strings.ToLower(*input.PlaybackMode)
+	}
+	if mode != scheduler.PlaybackPlayOnce {
+		if input.PlaylistID != nil {
+			slot.RuleSetID = nil
+		} else if input.RuleSetID != nil {
+			slot.PlaylistID = nil
+		}
 	}
 
-	var playlist models.Playlist
-	// 2. Verify the Playlist actually belongs to this Tenant!
-	if err := h.db.Where("id = ? AND organization_id = ?", input.PlaylistID, orgID).First(&playlist).Error; err != nil {
-		c.JSON(http.StatusNotFound, gin.H{"error": "Playlist not found or unauthorized"})
+	if status, err := h.applySlotInput(orgID, &slot, input); err != nil {
+		c.JSON(status, gin.H{"error": err.Error()})
 		return
 	}
 
-	loc, err := time.LoadLocation(h.cfg.Server.Timezone)
-	if err != nil {
-		log.Printf("Could not load timezone '%s', falling back to Local: %v", h.cfg.Server.Timezone, err)
-		loc = time.Local
+	if conflicts := h.findConflicts(orgID, &slot); len(conflicts) > 0 {
+		c.JSON(http.StatusConflict, gin.H{"error": "Slot overlaps existing schedule entries", "conflicts": conflicts})
+		return
 	}
 
-	var localTime time.Time
+	slot.Playlist = nil
+	slot.RuleSet = nil
+	if err := h.db.Save(&slot).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update schedule"})
+		return
+	}
 
-	// Scenario A: Frontend sends strict UTC/Offset string (e.g., "2026-04-23T13:45:00Z")
-	if len(input.StartTime) > 19 && (input.StartTime[len(input.StartTime)-1] == 'Z' || input.StartTime[len(input.StartTime)-6] == '+') {
-		parsedTime, err := time.Parse(time.RFC3339, input.StartTime)
-		if err != nil {
-			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid RFC3339 time format"})
-			return
+	c.JSON(http.StatusOK, slot)
+}
+
+// applySlotInput merges the request into slot and validates the result, returning the HTTP status to use on error
+func (h *SchedulerHandler) applySlotInput(orgID uuid.UUID, slot *models.ScheduleSlot, input scheduleSlotInput) (int, error) {
+	// Slot times are wall-clock in the station's own timezone
+	loc := h.timezones.Location(orgID)
+
+	if input.Name != nil {
+		slot.Name = strings.TrimSpace(*input.Name)
+	}
+	if input.ScheduleType != nil {
+		slot.ScheduleType = *input.ScheduleType
+	}
+	if input.IsActive != nil {
+		slot.IsActive = *input.IsActive
+	}
+	if input.IsReplay != nil {
+		slot.IsReplay = *input.IsReplay
+	}
+	if input.Days != nil {
+		slot.Days = *input.Days
+	}
+	if input.StartDate != nil {
+		slot.StartDate = *input.StartDate
+	}
+	if input.EndDate != nil {
+		slot.EndDate = *input.EndDate
+	}
+	if input.Date != nil {
+		slot.Date = *input.Date
+	}
+	if input.StartMode != nil {
+		slot.StartMode = strings.ToLower(*input.StartMode)
+	}
+	if input.FitTolerance != nil {
+		slot.FitToleranceSeconds = *input.FitTolerance
+	}
+	if input.PlaybackMode != nil {
+		slot.PlaybackMode = strings.ToLower(*input.PlaybackMode)
+	}
+
+	// 1. Verify the target actually belongs to this Tenant! An id of 0 removes it.
+	var playlist models.Playlist
+	if input.PlaylistID != nil && *input.PlaylistID == 0 {
+		slot.PlaylistID = nil
+	} else if input.PlaylistID != nil {
+		if err := h.db.Where("id = ? AND organization_id = ?", *input.PlaylistID, orgID).First(&playlist).Error; err != nil {
+			return http.StatusNotFound, fmt.Errorf("Playlist not found or unauthorized")
+		}
+		slot.PlaylistID = input.PlaylistID
+	}
+	if input.RuleSetID != nil && *input.RuleSetID == 0 {
+		slot.RuleSetID = nil
+	} else if input.RuleSetID != nil {
+		var count int64
+		h.db.Model(&models.RuleSet{}).Where("id = ? AND organization_id = ?", *input.RuleSetID, orgID).Count(&count)
+		if count == 0 {
+			return http.StatusNotFound, fmt.Errorf("RuleSet not found or unauthorized")
 		}
-		localTime = parsedTime.In(loc)
+		slot.RuleSetID = input.RuleSetID
+	}
 
-		// Scenario B: Frontend sends exact local wall-clock (e.g., "2026-04-23T15:45")
-	} else {
-		layout := "2006-01-02T15:04"
-		if len(input.StartTime) == 19 {
-			layout = "2006-01-02T15:04:05" // If seconds are included
+	// 2. Start: either a wall-clock "HH:MM" or a datetime that also pins the date/weekday
+	var startAt time.Time
+	if input.StartTime != nil {
+		if clock, ok := parseClock(*input.StartTime); ok {
+			slot.StartTime = clock
+		} else {
+			localTime, err := parseSlotDateTime(*input.StartTime, loc)
+			if err != nil {
+				return http.StatusBadRequest, err
+			}
+			startAt = localTime
+			slot.StartTime = localTime.Format(scheduler.ClockLayout)
+			if input.Date == nil {
+				slot.Date = localTime.Format(scheduler.DateLayout)
+			}
+			if input.Days == nil && (slot.ID == 0 || slot.ScheduleType == scheduler.TypeOneTime) {
+				slot.Days = localTime.Weekday().String()[0:3]
+			}
 		}
+	}
 
-		parsedTime, err := time.ParseInLocation(layout, input.StartTime[:len(layout)], loc)
-		if err != nil {
-			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid local time format"})
-			return
+	// 3. End: explicit, or derived from the playlist length for backwards compatibility
+	if input.EndTime != nil {
+		if clock, ok := parseClock(*input.EndTime); ok {
+			slot.EndTime = clock
+		} else {
+			localTime, err := parseSlotDateTime(*input.EndTime, loc)
+			if err != nil {
+				return http.StatusBadRequest, err
+			}
+			slot.EndTime = localTime.Format(scheduler.ClockLayout)
 		}
-		localTime = parsedTime
+	} else if slot.EndTime == "" {
+		if input.PlaylistID == nil || slot.PlaylistID == nil {
+			return http.StatusBadRequest, fmt.Errorf("end_time is required for RuleSet slots")
+		}
+		if startAt.IsZero() {
+			startAt, _ = time.ParseInLocation(scheduler.ClockLayout, slot.StartTime, loc)
+		}
+		slot.EndTime = startAt.Add(time.Duration(playlist.TotalDuration) * time.Second).Format(scheduler.ClockLayout)
 	}
 
-	exactDate := localTime.Format("2006-01-02")
-	dayOfWeek := localTime.Weekday().String()[0:3]
-	startTimeStr := localTime.Format("15:04")
+	if slot.ScheduleType == scheduler.TypeOneTime && slot.Date != "" {
+		if d, err := time.Parse(scheduler.DateLayout, strings.Split(slot.Date, "T")[0]); err == nil {
+			slot.Days = d.Weekday().String()[0:3]
+		}
+	}
 
-	parsedEnd := localTime.Add(time.Duration(playlist.TotalDuration) * time.Second)
-	endTimeStr := parsedEnd.Format("15:04")
+	if err := scheduler.ValidateSlot(slot); err != nil {
+		return http.StatusBadRequest, err
+	}
+	return http.StatusOK, nil
+}
 
-	slot := models.ScheduleSlot{
-		OrganizationID: orgID, // 3. Bind the Slot to the Tenant
-		PlaylistID:     &input.PlaylistID,
-		ScheduleType:   input.ScheduleType,
-		Date:           exactDate,
-		Days:           dayOfWeek,
-		StartTime:      startTimeStr,
-		EndTime:        endTimeStr,
-		IsActive:       true,
+// findConflicts lists the active slots of the tenant that would be on air at the same time as slot
+func (h *SchedulerHandler) findConflicts(orgID uuid.UUID, slot *models.ScheduleSlot) []models.ScheduleSlot {
+	if !slot.IsActive {
+		return nil
 	}
 
-	if err := h.db.Create(&slot).Error; err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to save schedule"})
-		return
+	var existing []models.ScheduleSlot
+	h.db.Where("organization_id = ? AND is_active = ? AND id <> ?", orgID, true, slot.ID).Find(&existing)
+
+	var conflicts []models.ScheduleSlot
+	for i := range existing {
+		if scheduler.SlotsOverlap(slot, &existing[i]) {
+			conflicts = append(conflicts, existing[i])
+		}
 	}
+	return conflicts
+}
 
-	c.JSON(http.StatusCreated, slot)
+// parseClock accepts "HH:MM" (or "HH:MM:SS") wall-clock times
+func parseClock(value string) (string, bool) {
+	for _, layout := range []string{"15:04", "15:04:05"} {
+		if t, err := time.Parse(layout, value); err == nil {
+			return t.Format(scheduler.ClockLayout), true
+		}
+	}
+	return "", false
+}
+
+func parseSlotDateTime(value string, loc *time.Location) (time.Time, error) {
+	// Scenario A: Frontend sends strict UTC/Offset string (e.g., "2026-04-23T13:45:00Z")
+	if len(value) > 19 && (value[len(value)-1] == 'Z' || value[len(value)-6] == '+' || value[len(value)-6] == '-') {
+		parsedTime, err := time.Parse(time.RFC3339, value)
+		if err != nil {
+			return time.Time{}, fmt.Errorf("Invalid RFC3339 time format")
+		}
+		return parsedTime.In(loc), nil
+	}
+
+	// Scenario B: Frontend sends exact local wall-clock (e.g., "2026-04-23T15:45")
+	layout := "2006-01-02T15:04"
+	if len(value) == 19 {
+		layout = "2006-01-02T15:04:05" // If seconds are included
+	}
+	if len(value) < len(layout) {
+		return time.Time{}, fmt.Errorf("Invalid local time format")
+	}
+
+	parsedTime, err := time.ParseInLocation(layout, value[:len(layout)], loc)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("Invalid local time format")
+	}
+	return parsedTime, nil
 }
 
 func (h *SchedulerHandler) GetSchedule(c *gin.Context) {
@@ -119,7 +318,7 @@ func (h *SchedulerHandler) GetSchedule(c *gin.Context) {
 
 	var slots []models.ScheduleSlot
 	// ⚡️ Scope to Tenant
-	if err := h.db.Preload("Playlist").Where("organization_id = ? AND is_active = ?", orgID, true).Find(&slots).Error; err != nil {
+	if err := h.db.Preload("Playlist").Preload("RuleSet").Where("organization_id = ? AND is_active = ?", orgID, true).Find(&slots).Error; err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch schedule"})
 		return
 	}
@@ -1,24 +1,41 @@
 package handlers
 
 import (
+	"fmt"
 	"net/http"
+	"slices"
+	"strings"
 	"time"
 
 	"github.com/gin-gonic/gin"
+	"github.com/redis/go-redis/v9"
 	"gorm.io/gorm"
 
+	"momo-radio/internal/audio"
+	"momo-radio/internal/dj"
 	"momo-radio/internal/models"
+	"momo-radio/internal/scheduler"
 )
 
+// allowedSampleRates are the output rates every stream codec supports
+var allowedSampleRates = []string{"22050", "32000", "44100", "48000"}
+
+// allowedBitrates are the stream bitrates offered to mounts that have none of their own
+var allowedBitrates = []string{"32k", "48k", "64k", "96k", "128k", "160k", "192k", "256k", "320k"}
+
 // SettingsHandler handles tenant-specific workspace and broadcast settings
 type SettingsHandler struct {
-	db *gorm.DB
+	db        *gorm.DB
+	rdb       *redis.Client
+	timezones *scheduler.Timezones
 }
 
 // NewSettingsHandler creates a new instance of the handler
-func NewSettingsHandler(db *gorm.DB) *SettingsHandler {
+func NewSettingsHandler(db *gorm.DB, rdb *redis.Client, tz *scheduler.Timezones) *SettingsHandler {
 	return &SettingsHandler{
-		db: db,
+		db:        db,
+		rdb:       rdb,
+		timezones: tz,
 	}
 }
 
@@ -51,6 +68,36 @@ func (h *SettingsHandler) UpdateOrgSettings(c *gin.Context) {
 		return
 	}
 
+	// The scheduler resolves show times with it, reject names the server cannot load.
+	// Empty is kept: it follows the server's timezone.
+	if _, err := time.LoadLocation(req.Timezone); req.Timezone != "" && err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Unknown timezone, expected an IANA name like 'Europe/Paris'"})
+		return
+	}
+
+	// Passed to the stream encoders, which take any value they are given
+	if req.FFmpegBitrate == "" {
+		req.FFmpegBitrate = "128k"
+	}
+	if !slices.Contains(allowedBitrates, req.FFmpegBitrate) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "ffmpeg_bitrate must be one of " + strings.Join(allowedBitrates, ", ")})
+		return
+	}
+	if req.FFmpegSampleRate == "" {
+		req.FFmpegSampleRate = "44100"
+	}
+	if !slices.Contains(allowedSampleRates, req.FFmpegSampleRate) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "ffmpeg_sample_rate must be one of " + strings.Join(allowedSampleRates, ", ")})
+		return
+	}
+
+	if req.Separation != nil {
+		if err := dj.ValidateSeparation(*req.Separation); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+	}
+
 	// Force the organization ID to match the authenticated user's token
 	req.OrganizationID = orgID
 	req.UpdatedAt = time.Now()
@@ -60,6 +107,16 @@ func (h *SettingsHandler) UpdateOrgSettings(c *gin.Context) {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to save settings"})
 		return
 	}
+	h.timezones.Invalidate(orgID)
+
+	// The radio engine keeps its own copy
+	payload := fmt.Sprintf(`{"org_id": "%s", "action": "settings"}`, orgID)
+	h.rdb.Publish(c.Request.Context(), "radio.control", payload)
 
 	c.JSON(http.StatusOK, req)
 }
+
+// GetProcessingPresets lists the station sounds a mount point's processing chain can start from
+func (h *SettingsHandler) GetProcessingPresets(c *gin.Context) {
+	c.JSON(http.StatusOK, gin.H{"data": audio.ProcessingPresets()})
+}
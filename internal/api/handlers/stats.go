@@ -1,11 +1,15 @@
 package handlers
 
 import (
+	"fmt"
 	"net/http"
+	"sort"
+	"strconv"
 	"strings"
 	"time"
 
 	"momo-radio/internal/models"
+	"momo-radio/internal/scheduler"
 
 	"github.com/gin-gonic/gin"
 	"github.com/google/uuid"
@@ -13,11 +17,12 @@ import (
 )
 
 type StatsHandler struct {
-	db *gorm.DB
+	db        *gorm.DB
+	timezones *scheduler.Timezones
 }
 
-func NewStatsHandler(db *gorm.DB) *StatsHandler {
-	return &StatsHandler{db: db}
+func NewStatsHandler(db *gorm.DB, tz *scheduler.Timezones) *StatsHandler {
+	return &StatsHandler{db: db, timezones: tz}
 }
 
 func (h *StatsHandler) GetStats(c *gin.Context) {
@@ -43,19 +48,21 @@ func (h *StatsHandler) GetStats(c *gin.Context) {
 	h.db.Model(&models.Playlist{}).Where("organization_id = ?", orgID).Count(&totalPlaylists)
 	h.db.Model(&models.Track{}).Where("organization_id = ?", orgID).Select("COALESCE(SUM(file_size), 0)").Scan(&storageUsed)
 
-	// 3. Determine Active Schedule (The "Show")
-	now := time.Now()
-	currentTimeStr := now.Format("15:04")
-	currentWeekday := now.Weekday().String()[0:3]
+	// 3. Determine Active Schedule (The "Show") on the station's own clock
+	now := h.timezones.Now(orgID)
 
-	var schedules []models.Schedule
-	h.db.Preload("Playlist").Preload("RuleSet").Where("organization_id = ? AND is_active = ?", orgID, true).Find(&schedules)
+	var slots []models.ScheduleSlot
+	h.db.Preload("Playlist").Preload("RuleSet").Where("organization_id = ? AND is_active = ?", orgID, true).Find(&slots)
 
 	activeShowName := "General Rotation"
-	for _, slot := range schedules {
-		if strings.Contains(slot.Days, currentWeekday) && isTimeMatch(slot.StartTime, slot.EndTime, currentTimeStr) {
-			activeShowName = slot.Name
-			break
+	for i := range slots {
+		slot := &slots[i]
+		if !scheduler.SlotActiveAt(slot, now) {
+			continue
+		}
+		activeShowName = slotDisplayName(slot)
+		if slot.ScheduleType == scheduler.TypeOneTime {
+			break // One-time events pre-empt recurring shows
 		}
 	}
 
@@ -97,13 +104,31 @@ func (h *StatsHandler) GetStats(c *gin.Context) {
 		Limit(5).
 		Find(&recentTracks)
 
-	// 6. Build Response
+	// 6. Live audience from the most recent listener sample
+	var currentListeners int64
+	h.db.Model(&models.ListenerSample{}).
+		Select("COALESCE(SUM(listeners), 0)").
+		Where("organization_id = ? AND bucket_start = (?)", orgID,
+			h.db.Model(&models.ListenerSample{}).Select("MAX(bucket_start)").
+				Where("organization_id = ? AND bucket_start > ?", orgID, now.Add(-3*time.Minute))).
+		Scan(&currentListeners)
+
+	// 7. Uptime over the last day: the share of it without an open pipeline incident on any mount
+	var incidents []models.PipelineIncident
+	dayAgo := now.Add(-24 * time.Hour)
+	h.db.Select("created_at", "resolved_at").
+		Where("organization_id = ? AND created_at < ? AND (resolved_at IS NULL OR resolved_at > ?)", orgID, now, dayAgo).
+		Find(&incidents)
+	uptime := 1 - incidentDowntime(incidents, dayAgo, now).Seconds()/(24*time.Hour).Seconds()
+
+	// 8. Build Response
 	c.JSON(http.StatusOK, gin.H{
 		"stats": gin.H{
 			"total_tracks":       totalTracks,
 			"total_playlists":    totalPlaylists,
 			"storage_used_bytes": storageUsed,
-			"uptime":             "99.9%",
+			"uptime":             fmt.Sprintf("%.1f%%", uptime*100),
+			"current_listeners":  currentListeners,
 		},
 		"now_playing": gin.H{
 			"title":         currentTrack.Title,
@@ -116,13 +141,313 @@ func (h *StatsHandler) GetStats(c *gin.Context) {
 	})
 }
 
-// Internal helper for time matching (Standard vs Midnight Crossover)
-func isTimeMatch(start, end, current string) bool {
-	if start == "" || end == "" {
-		return false
+// incidentDowntime is the time within [from, to) covered by at least one incident; open ones last until `to`
+func incidentDowntime(incidents []models.PipelineIncident, from, to time.Time) time.Duration {
+	type span struct{ start, end time.Time }
+	var spans []span
+	for _, inc := range incidents {
+		s, e := inc.CreatedAt, to
+		if inc.ResolvedAt != nil && inc.ResolvedAt.Before(to) {
+			e = *inc.ResolvedAt
+		}
+		if s.Before(from) {
+			s = from
+		}
+		if e.After(s) {
+			spans = append(spans, span{s, e})
+		}
+	}
+	sort.Slice(spans, func(i, j int) bool { return spans[i].start.Before(spans[j].start) })
+
+	// Overlapping incidents (several mounts, or a stage failing during dead air) count once
+	var down time.Duration
+	var until time.Time
+	for _, sp := range spans {
+		if sp.start.Before(until) {
+			sp.start = until
+		}
+		if sp.end.After(sp.start) {
+			down += sp.end.Sub(sp.start)
+			until = sp.end
+		}
+	}
+	return down
+}
+
+func slotDisplayName(slot *models.ScheduleSlot) string {
+	switch {
+	case slot.Name != "":
+		return slot.Name
+	case slot.Playlist != nil:
+		return slot.Playlist.Name
+	case slot.RuleSet != nil:
+		return slot.RuleSet.Name
+	}
+	return "General Rotation"
+}
+
+// GetListenerStats returns the live audience plus session totals for ?from=&to= (RFC3339, default last 24h)
+func (h *StatsHandler) GetListenerStats(c *gin.Context) {
+	orgID, ok := getOrgID(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Organization context missing."})
+		return
+	}
+
+	from, to, err := parseStatsRange(c, 24*time.Hour)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	// 1. Current audience: the latest sample, if it is recent enough to still be true
+	type mountCount struct {
+		Mount     string `json:"mount"`
+		Listeners int    `json:"listeners"`
+	}
+	var current []mountCount
+	h.db.Model(&models.ListenerSample{}).
+		Select("mount, SUM(listeners) AS listeners").
+		Where("organization_id = ? AND bucket_start = (?)", orgID,
+			h.db.Model(&models.ListenerSample{}).Select("MAX(bucket_start)").
+				Where("organization_id = ? AND bucket_start > ?", orgID, time.Now().Add(-3*time.Minute))).
+		Group("mount").
+		Scan(&current)
+
+	currentTotal := 0
+	for _, m := range current {
+		currentTotal += m.Listeners
+	}
+
+	// 2. Time-series totals over the range
+	var totals struct {
+		Peak             int
+		ListeningSeconds float64
+	}
+	h.db.Raw(`SELECT COALESCE(MAX(listeners), 0) AS peak, COALESCE(SUM(seconds), 0) AS listening_seconds
+		FROM (SELECT SUM(listeners) AS listeners, SUM(listening_seconds) AS seconds
+			FROM listener_samples
+			WHERE organization_id = ? AND bucket_start >= ? AND bucket_start < ?
+			GROUP BY bucket_start) s`, orgID, from, to).
+		Scan(&totals)
+
+	// 3. Sessions started in the range
+	var sessions struct {
+		Sessions        int64
+		UniqueListeners int64
+		AvgSeconds      float64
+	}
+	h.db.Model(&models.ListenerSession{}).
+		Select(`COUNT(*) AS sessions, COUNT(DISTINCT listener_hash) AS unique_listeners,
+			COALESCE(AVG(EXTRACT(EPOCH FROM COALESCE(ended_at, last_seen_at) - started_at)), 0) AS avg_seconds`).
+		Where("organization_id = ? AND started_at >= ? AND started_at < ?", orgID, from, to).
+		Scan(&sessions)
+
+	type bucketCount struct {
+		Key             string  `json:"key"`
+		Sessions        int64   `json:"sessions"`
+		UniqueListeners int64   `json:"unique_listeners"`
+		ListeningHours  float64 `json:"listening_hours"`
+	}
+	breakdown := func(column string) []bucketCount {
+		var rows []bucketCount
+		h.db.Model(&models.ListenerSession{}).
+			Select(column+` AS key, COUNT(*) AS sessions, COUNT(DISTINCT listener_hash) AS unique_listeners,
+				COALESCE(SUM(EXTRACT(EPOCH FROM COALESCE(ended_at, last_seen_at) - started_at)), 0) / 3600 AS listening_hours`).
+			Where("organization_id = ? AND started_at >= ? AND started_at < ?", orgID, from, to).
+			Group(column).
+			Order("sessions DESC").
+			Limit(50).
+			Scan(&rows)
+		return rows
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"from":                from,
+		"to":                  to,
+		"current_listeners":   currentTotal,
+		"current_by_mount":    current,
+		"peak_listeners":      totals.Peak,
+		"listening_hours":     totals.ListeningSeconds / 3600,
+		"sessions":            sessions.Sessions,
+		"unique_listeners":    sessions.UniqueListeners,
+		"avg_session_seconds": sessions.AvgSeconds,
+		"by_mount":            breakdown("mount"),
+		"by_country":          breakdown("country"),
+		"by_source":           breakdown("source"),
+	})
+}
+
+// GetListenerTimeseries returns concurrent listeners and listening hours per ?interval= (minute, hour, day)
+func (h *StatsHandler) GetListenerTimeseries(c *gin.Context) {
+	orgID, ok := getOrgID(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Organization context missing."})
+		return
 	}
-	if start <= end {
-		return current >= start && current < end
+
+	from, to, err := parseStatsRange(c, 24*time.Hour)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
 	}
-	return current >= start || current < end
+
+	interval := c.DefaultQuery("interval", "hour")
+	if interval != "minute" && interval != "hour" && interval != "day" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "interval must be minute, hour or day"})
+		return
+	}
+
+	// Hours and days are cut on the station's wall clock, not UTC
+	tz := h.timezones.Location(orgID).String()
+
+	mountFilter := ""
+	args := []interface{}{interval, tz, tz, orgID, from, to}
+	if mount := c.Query("mount"); mount != "" {
+		mountFilter = " AND mount = ?"
+		args = append(args, mount)
+	}
+
+	type point struct {
+		Bucket         time.Time `json:"bucket"`
+		PeakListeners  int       `json:"peak_listeners"`
+		AvgListeners   float64   `json:"avg_listeners"`
+		ListeningHours float64   `json:"listening_hours"`
+	}
+	var series []point
+
+	// Samples are summed per bucket first so several mounts/engine instances add up
+	err = h.db.Raw(`SELECT date_trunc(?, bucket_start AT TIME ZONE ?) AT TIME ZONE ? AS bucket,
+			MAX(listeners) AS peak_listeners,
+			AVG(listeners) AS avg_listeners,
+			SUM(seconds) / 3600 AS listening_hours
+		FROM (SELECT bucket_start, SUM(listeners) AS listeners, SUM(listening_seconds) AS seconds
+			FROM listener_samples
+			WHERE organization_id = ? AND bucket_start >= ? AND bucket_start < ?`+mountFilter+`
+			GROUP BY bucket_start) s
+		GROUP BY 1
+		ORDER BY 1`, args...).
+		Scan(&series).Error
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load listener series"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"from":     from,
+		"to":       to,
+		"interval": interval,
+		"timezone": tz,
+		"series":   series,
+	})
+}
+
+// parseStatsRange reads ?from=&to= as RFC3339, defaulting to the last `span`
+func parseStatsRange(c *gin.Context, span time.Duration) (time.Time, time.Time, error) {
+	to := time.Now()
+	if v := c.Query("to"); v != "" {
+		t, err := time.Parse(time.RFC3339, v)
+		if err != nil {
+			return time.Time{}, time.Time{}, fmt.Errorf("invalid 'to' timestamp, expected RFC3339")
+		}
+		to = t
+	}
+
+	from := to.Add(-span)
+	if v := c.Query("from"); v != "" {
+		t, err := time.Parse(time.RFC3339, v)
+		if err != nil {
+			return time.Time{}, time.Time{}, fmt.Errorf("invalid 'from' timestamp, expected RFC3339")
+		}
+		from = t
+	}
+
+	if !from.Before(to) {
+		return time.Time{}, time.Time{}, fmt.Errorf("'from' must be before 'to'")
+	}
+	return from, to, nil
+}
+
+// GetRetention ranks tracks, artists or RuleSets by how well they hold the audience.
+// Query: group_by=track|artist|ruleset, from/to (RFC3339, default last 30 days), min_plays, order=worst|best, limit
+func (h *StatsHandler) GetRetention(c *gin.Context) {
+	orgID, ok := getOrgID(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Organization context missing."})
+		return
+	}
+
+	from, to, err := parseStatsRange(c, 30*24*time.Hour)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	// 1. Pick the grouping; every variant exposes id + name
+	var selectKey, joins string
+	switch c.DefaultQuery("group_by", "track") {
+	case "track":
+		selectKey = "tracks.id AS id, tracks.title AS name"
+		joins = "JOIN tracks ON tracks.id = play_histories.track_id"
+	case "artist":
+		selectKey = "artists.id AS id, artists.name AS name"
+		joins = "JOIN track_artists ON track_artists.track_id = play_histories.track_id JOIN artists ON artists.id = track_artists.artist_id"
+	case "ruleset":
+		selectKey = "rule_sets.id AS id, rule_sets.name AS name"
+		joins = "JOIN rule_sets ON rule_sets.id = play_histories.rule_set_id"
+	default:
+		c.JSON(http.StatusBadRequest, gin.H{"error": "group_by must be track, artist or ruleset"})
+		return
+	}
+
+	minPlays, _ := strconv.Atoi(c.DefaultQuery("min_plays", "3"))
+	limit, _ := strconv.Atoi(c.DefaultQuery("limit", "50"))
+	if limit <= 0 || limit > 500 {
+		limit = 50
+	}
+
+	// 2. Worst first by default: the point is finding what loses listeners
+	order := "tune_out_rate DESC NULLS LAST, plays DESC"
+	if c.Query("order") == "best" {
+		order = "tune_out_rate ASC NULLS LAST, plays DESC"
+	}
+
+	type retentionRow struct {
+		ID             uint     `json:"id"`
+		Name           string   `json:"name"`
+		Plays          int64    `json:"plays"`
+		AvgListeners   float64  `json:"avg_listeners"`
+		TuneOuts       int64    `json:"tune_outs"`
+		TuneOutRate    *float64 `json:"tune_out_rate"`   // Tune-outs per listener present at start
+		RetentionRatio *float64 `json:"retention_ratio"` // Listeners at end / listeners at start
+	}
+	var rows []retentionRow
+
+	err = h.db.Table("play_histories").
+		Select(selectKey+`,
+			COUNT(*) AS plays,
+			AVG(play_histories.listeners_start) AS avg_listeners,
+			SUM(play_histories.tune_outs) AS tune_outs,
+			SUM(play_histories.tune_outs)::float / NULLIF(SUM(play_histories.listeners_start), 0) AS tune_out_rate,
+			SUM(play_histories.listeners_end)::float / NULLIF(SUM(play_histories.listeners_start), 0) AS retention_ratio`).
+		Joins(joins).
+		Where("play_histories.organization_id = ? AND play_histories.deleted_at IS NULL", orgID).
+		Where("play_histories.played_at >= ? AND play_histories.played_at < ?", from, to).
+		Where("play_histories.listeners_start IS NOT NULL").
+		Group("1, 2").
+		Having("COUNT(*) >= ?", minPlays).
+		Order(order).
+		Limit(limit).
+		Scan(&rows).Error
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to compute retention"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"from":     from,
+		"to":       to,
+		"group_by": c.DefaultQuery("group_by", "track"),
+		"results":  rows,
+	})
 }
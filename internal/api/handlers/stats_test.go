@@ -0,0 +1,28 @@
+package handlers
+
+import (
+	"testing"
+	"time"
+
+	"momo-radio/internal/models"
+)
+
+func TestIncidentDowntime(t *testing.T) {
+	from := time.Date(2026, 3, 1, 0, 0, 0, 0, time.UTC)
+	to := from.Add(24 * time.Hour)
+	at := func(h, m int) time.Time { return from.Add(time.Duration(h)*time.Hour + time.Duration(m)*time.Minute) }
+	resolved := func(t time.Time) *time.Time { return &t }
+
+	incidents := []models.PipelineIncident{
+		{CreatedAt: from.Add(-time.Hour), ResolvedAt: resolved(at(0, 10))}, // Started before the window
+		{CreatedAt: at(5, 0), ResolvedAt: resolved(at(5, 30))},
+		{CreatedAt: at(5, 20), ResolvedAt: resolved(at(5, 40))}, // Overlaps the one above
+		{CreatedAt: at(23, 50)}, // Still open
+	}
+	if got, want := incidentDowntime(incidents, from, to), 60*time.Minute; got != want {
+		t.Errorf("incidentDowntime = %v, want %v", got, want)
+	}
+	if got := incidentDowntime(nil, from, to); got != 0 {
+		t.Errorf("no incidents should mean no downtime, got %v", got)
+	}
+}
@@ -0,0 +1,301 @@
+package handlers
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+	"gorm.io/gorm"
+
+	"momo-radio/internal/dj"
+	"momo-radio/internal/models"
+)
+
+// facetLimit is how many values each facet reports, most frequent first
+const facetLimit = 20
+
+// libraryFilter is the faceted search of the library view, read from the query string
+type libraryFilter struct {
+	Kind     string
+	AlbumID  string
+	Search   string
+	Genres   []string
+	Styles   []string
+	Moods    []string
+	Labels   []string
+	Statuses []string
+	Key      string
+	Scale    string
+
+	MinBPM, MaxBPM       *float64
+	MinEnergy, MaxEnergy *float64
+	MinYear, MaxYear     *int
+	MinPlays, MaxPlays   *int
+
+	PlayedBefore, PlayedAfter *time.Time
+	NeverPlayed               bool
+}
+
+// parseLibraryFilter reads the filters; lists are comma separated and match any of their values
+func parseLibraryFilter(c *gin.Context) (libraryFilter, error) {
+	f := libraryFilter{
+		Kind:     c.DefaultQuery("kind", models.TrackKindMusic), // Station imaging is listed separately
+		AlbumID:  c.Query("album_id"),
+		Search:   strings.TrimSpace(c.DefaultQuery("search", c.Query("q"))),
+		Genres:   csvQuery(c, "genre"),
+		Styles:   csvQuery(c, "style"),
+		Moods:    csvQuery(c, "mood"),
+		Labels:   csvQuery(c, "label"),
+		Statuses: csvQuery(c, "status"),
+		Key:      c.Query("key"),
+		Scale:    strings.ToLower(c.Query("scale")),
+	}
+	f.NeverPlayed = c.Query("never_played") == "true"
+
+	for name, dst := range map[string]**float64{
+		"min_bpm": &f.MinBPM, "max_bpm": &f.MaxBPM, "min_energy": &f.MinEnergy, "max_energy": &f.MaxEnergy,
+	} {
+		if v := c.Query(name); v != "" {
+			n, perr := strconv.ParseFloat(v, 64)
+			if perr != nil {
+				return f, fmt.Errorf("%s must be a number", name)
+			}
+			*dst = &n
+		}
+	}
+	for name, dst := range map[string]**int{
+		"min_year": &f.MinYear, "max_year": &f.MaxYear, "min_plays": &f.MinPlays, "max_plays": &f.MaxPlays,
+	} {
+		if v := c.Query(name); v != "" {
+			n, perr := strconv.Atoi(v)
+			if perr != nil {
+				return f, fmt.Errorf("%s must be a whole number", name)
+			}
+			*dst = &n
+		}
+	}
+	for name, dst := range map[string]**time.Time{"played_before": &f.PlayedBefore, "played_after": &f.PlayedAfter} {
+		if v := c.Query(name); v != "" {
+			t, perr := time.Parse(time.DateOnly, v)
+			if perr != nil {
+				return f, fmt.Errorf("%s must be a date (YYYY-MM-DD)", name)
+			}
+			*dst = &t
+		}
+	}
+	return f, nil
+}
+
+func csvQuery(c *gin.Context, name string) []string {
+	var out []string
+	for _, v := range strings.Split(c.Query(name), ",") {
+		if v = strings.TrimSpace(v); v != "" {
+			out = append(out, strings.ToLower(v))
+		}
+	}
+	return out
+}
+
+// apply narrows a tracks query to the tenant's tracks matching every filter
+func (f libraryFilter) apply(db *gorm.DB, orgID uuid.UUID) *gorm.DB {
+	// ⚡️ THE LOCK: Always restrict to the specific organization first!
+	db = db.Where("tracks.organization_id = ? AND tracks.kind = ?", orgID, f.Kind)
+
+	if f.AlbumID != "" {
+		db = db.Where("tracks.album_id = ?", f.AlbumID)
+	}
+	if q := prefixTSQuery(f.Search); q != "" {
+		db = db.Where("tracks.search_vector @@ to_tsquery('simple', ?)", q)
+	}
+
+	// Genre and style are ", " separated lists
+	if len(f.Genres) > 0 {
+		db = db.Where("string_to_array(LOWER(COALESCE(tracks.genre, '')), ', ') && ?", pq.Array(f.Genres))
+	}
+	if len(f.Styles) > 0 {
+		db = db.Where("string_to_array(LOWER(COALESCE(tracks.style, '')), ', ') && ?", pq.Array(f.Styles))
+	}
+	if len(f.Moods) > 0 {
+		db = db.Where("LOWER(tracks.mood) = ANY(?) OR EXISTS (SELECT 1 FROM unnest(tracks.ml_moods) AS tag WHERE LOWER(tag) = ANY(?))",
+			pq.Array(f.Moods), pq.Array(f.Moods))
+	}
+	if len(f.Labels) > 0 {
+		db = db.Where("EXISTS (SELECT 1 FROM albums WHERE albums.id = tracks.album_id AND LOWER(albums.publisher) = ANY(?))", pq.Array(f.Labels))
+	}
+	if len(f.Statuses) > 0 {
+		db = db.Where("tracks.processing_status IN ?", f.Statuses)
+	}
+	if f.Key != "" {
+		db = db.Where("tracks.musical_key = ?", f.Key)
+	}
+	if f.Scale != "" {
+		db = db.Where("LOWER(tracks.scale) = ?", f.Scale)
+	}
+
+	if f.MinBPM != nil {
+		db = db.Where("tracks.bpm >= ?", *f.MinBPM)
+	}
+	if f.MaxBPM != nil {
+		db = db.Where("tracks.bpm <= ?", *f.MaxBPM)
+	}
+	if f.MinEnergy != nil {
+		db = db.Where("tracks.energy >= ?", *f.MinEnergy)
+	}
+	if f.MaxEnergy != nil {
+		db = db.Where("tracks.energy <= ?", *f.MaxEnergy)
+	}
+	if f.MinYear != nil {
+		db = db.Where("tracks.album_id IN (SELECT id FROM albums WHERE "+dj.AlbumYearSQL+" >= ?)", *f.MinYear)
+	}
+	if f.MaxYear != nil {
+		db = db.Where("tracks.album_id IN (SELECT id FROM albums WHERE "+dj.AlbumYearSQL+" <= ?)", *f.MaxYear)
+	}
+
+	// Play statistics
+	if f.MinPlays != nil {
+		db = db.Where("tracks.play_count >= ?", *f.MinPlays)
+	}
+	if f.MaxPlays != nil {
+		db = db.Where("tracks.play_count <= ?", *f.MaxPlays)
+	}
+	if f.PlayedBefore != nil {
+		db = db.Where("tracks.last_played < ?", *f.PlayedBefore)
+	}
+	if f.PlayedAfter != nil {
+		db = db.Where("tracks.last_played >= ?", *f.PlayedAfter)
+	}
+	if f.NeverPlayed {
+		db = db.Where("tracks.last_played IS NULL")
+	}
+	return db
+}
+
+// prefixTSQuery turns free text into a tsquery where every word must match as a prefix,
+// so "pink flo" finds "Pink Floyd" while typing. Punctuation is dropped; "" means no search.
+func prefixTSQuery(search string) string {
+	words := strings.FieldsFunc(strings.ToLower(search), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+	if len(words) > 8 {
+		words = words[:8]
+	}
+	for i, w := range words {
+		words[i] = w + ":*"
+	}
+	return strings.Join(words, " & ")
+}
+
+// librarySort is a keyset-paginated ordering: column then id, both in the same direction
+type librarySort struct {
+	column string // "" orders by id alone
+	desc   bool
+	value  func(models.Track) any
+}
+
+var librarySorts = map[string]librarySort{
+	"newest":       {desc: true},
+	"oldest":       {},
+	"alphabetical": {column: "tracks.title", value: func(t models.Track) any { return t.Title }},
+	"duration":     {column: "tracks.duration", desc: true, value: func(t models.Track) any { return t.Duration }},
+	"bpm":          {column: "tracks.bpm", value: func(t models.Track) any { return t.BPM }},
+	"energy":       {column: "tracks.energy", desc: true, value: func(t models.Track) any { return t.Energy }},
+	"plays":        {column: "tracks.play_count", desc: true, value: func(t models.Track) any { return t.PlayCount }},
+}
+
+func (s librarySort) order(db *gorm.DB) *gorm.DB {
+	dir := "ASC"
+	if s.desc {
+		dir = "DESC"
+	}
+	if s.column != "" {
+		db = db.Order(s.column + " " + dir)
+	}
+	return db.Order("tracks.id " + dir)
+}
+
+// after continues the listing past the cursor's track
+func (s librarySort) after(db *gorm.DB, cur libraryCursor) *gorm.DB {
+	op := ">"
+	if s.desc {
+		op = "<"
+	}
+	if s.column == "" {
+		return db.Where("tracks.id "+op+" ?", cur.ID)
+	}
+	return db.Where("("+s.column+", tracks.id) "+op+" (?, ?)", cur.Value, cur.ID)
+}
+
+func (s librarySort) cursorFor(t models.Track) libraryCursor {
+	cur := libraryCursor{ID: t.ID}
+	if s.value != nil {
+		cur.Value = s.value(t)
+	}
+	return cur
+}
+
+// libraryCursor is the position after the last track of a page, opaque to clients
+type libraryCursor struct {
+	Value any  `json:"v,omitempty"`
+	ID    uint `json:"id"`
+}
+
+func (cur libraryCursor) encode() string {
+	raw, _ := json.Marshal(cur)
+	return base64.RawURLEncoding.EncodeToString(raw)
+}
+
+func decodeLibraryCursor(s string) (libraryCursor, error) {
+	var cur libraryCursor
+	raw, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return cur, err
+	}
+	err = json.Unmarshal(raw, &cur)
+	return cur, err
+}
+
+// facetColumns are the values each facet counts; list columns count every entry
+var facetColumns = map[string]string{
+	"genre":  "unnest(string_to_array(NULLIF(tracks.genre, ''), ', '))",
+	"style":  "unnest(string_to_array(NULLIF(tracks.style, ''), ', '))",
+	"mood":   "unnest(tracks.ml_moods)",
+	"key":    "NULLIF(TRIM(tracks.musical_key || ' ' || tracks.scale), '')",
+	"status": "tracks.processing_status",
+	"label":  "(SELECT NULLIF(albums.publisher, '') FROM albums WHERE albums.id = tracks.album_id)",
+	"year":   "(SELECT " + dj.AlbumYearSQL + "::text FROM albums WHERE albums.id = tracks.album_id)",
+}
+
+type facetCount struct {
+	Value string `json:"value"`
+	Count int64  `json:"count"`
+}
+
+// countFacets counts the requested facets over the filtered tracks
+func countFacets(db *gorm.DB, filtered func() *gorm.DB, names []string) (map[string][]facetCount, error) {
+	facets := make(map[string][]facetCount)
+	for _, name := range names {
+		column, ok := facetColumns[name]
+		if !ok {
+			continue
+		}
+
+		rows := []facetCount{}
+		err := db.Table("(?) AS facet", filtered().Select(column+" AS value")).
+			Select("value, COUNT(*) AS count").
+			Where("value IS NOT NULL AND value <> ''").
+			Group("value").Order("count DESC, value ASC").Limit(facetLimit).
+			Scan(&rows).Error
+		if err != nil {
+			return nil, err
+		}
+		facets[name] = rows
+	}
+	return facets, nil
+}
@@ -0,0 +1,68 @@
+package handlers
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/google/uuid"
+
+	database "momo-radio/internal/db"
+	"momo-radio/internal/models"
+)
+
+func TestPrefixTSQuery(t *testing.T) {
+	tests := map[string]string{
+		"Pink flo":          "pink:* & flo:*",
+		"  AC/DC!  ":        "ac:* & dc:*",
+		"Sigur Rós":         "sigur:* & rós:*",
+		"&|!():*":           "",
+		"a b c d e f g h i": "a:* & b:* & c:* & d:* & e:* & f:* & g:* & h:*",
+	}
+	for in, want := range tests {
+		if got := prefixTSQuery(in); got != want {
+			t.Errorf("prefixTSQuery(%q) = %q, want %q", in, got, want)
+		}
+	}
+}
+
+func TestLibraryCursorRoundTrip(t *testing.T) {
+	track := models.Track{ID: 42, Title: "Windowlicker"}
+	encoded := librarySorts["alphabetical"].cursorFor(track).encode()
+
+	cur, err := decodeLibraryCursor(encoded)
+	if err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	if cur.ID != 42 || cur.Value != "Windowlicker" {
+		t.Errorf("round trip = %+v", cur)
+	}
+	if _, err := decodeLibraryCursor("not a cursor"); err == nil {
+		t.Error("garbage cursor should not decode")
+	}
+}
+
+func TestLibraryFilterSQL(t *testing.T) {
+	db, err := database.DryRun()
+	if err != nil {
+		t.Fatalf("open: %v", err)
+	}
+
+	minBPM, maxPlays := 120.0, 3
+	f := libraryFilter{Kind: models.TrackKindMusic, Search: "floyd", Moods: []string{"happy"}, MinBPM: &minBPM, MaxPlays: &maxPlays}
+	query := f.apply(db.Model(&models.Track{}), uuid.New())
+	query = librarySorts["duration"].after(query, libraryCursor{Value: 240.0, ID: 7})
+
+	sql := query.Find(&[]models.Track{}).Statement.SQL.String()
+	for _, want := range []string{
+		"tracks.organization_id = $1 AND tracks.kind = $2",
+		"tracks.search_vector @@ to_tsquery('simple', $3)",
+		"(LOWER(tracks.mood) = ANY($4) OR EXISTS", // OR stays inside its own condition
+		"tracks.bpm >= $6",
+		"tracks.play_count <= $7",
+		"(tracks.duration, tracks.id) < ($8, $9)",
+	} {
+		if !strings.Contains(sql, want) {
+			t.Errorf("SQL missing %q:\n%s", want, sql)
+		}
+	}
+}
@@ -16,7 +16,10 @@ import (
 	"strings"
 	"time"
 
+	"momo-radio/internal/audio"
 	"momo-radio/internal/config"
+	database "momo-radio/internal/db"
+	"momo-radio/internal/dj"
 	"momo-radio/internal/metadata"
 	"momo-radio/internal/models"
 	"momo-radio/internal/storage"
@@ -24,6 +27,7 @@ import (
 
 	"github.com/dhowden/tag"
 	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
 	"github.com/hibiken/asynq"
 	"github.com/lib/pq"
 	"github.com/redis/go-redis/v9"
@@ -70,7 +74,9 @@ type LibraryTrack struct {
 	MLCharacteristics pq.StringArray `json:"ml_characteristics"`
 }
 
-// GetTracks returns a paginated, lightweight list of tracks scoped by Tenant
+// GetTracks returns a page of the tenant's library, narrowed by the faceted filters (see libraryFilter),
+// with optional facet counts (facets=genre,style,mood,key,status,label,year). Pass meta.next_cursor
+// back as cursor for the next page; offset still works but gets slow deep into a large library.
 func (h *TrackHandler) GetTracks(c *gin.Context) {
 	orgID, ok := getOrgID(c)
 	if !ok {
@@ -80,110 +86,195 @@ func (h *TrackHandler) GetTracks(c *gin.Context) {
 
 	limit, _ := strconv.Atoi(c.DefaultQuery("limit", "100"))
 	offset, _ := strconv.Atoi(c.DefaultQuery("offset", "0"))
-	search := c.Query("search")
-	sortBy := c.DefaultQuery("sort", "newest")
-
-	// 1. Extract album_id from the query parameters
-	albumID := c.Query("album_id")
 
+	if limit <= 0 {
+		limit = 100
+	}
 	if limit > 200 {
 		limit = 200
 	}
 
-	// 2. Build the base query, PRELOAD, and SCOPE TO TENANT
-	query := h.db.Model(&models.Track{}).
-		Preload("Artists").
-		Preload("Album").
-		Where("tracks.organization_id = ?", orgID)
+	// 1. Parse the filters
+	filter, err := parseLibraryFilter(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	filtered := func() *gorm.DB { return filter.apply(h.db.Model(&models.Track{}), orgID) }
 
-	// 3. Apply the Album Filter (Must be done BEFORE counting)
-	if albumID != "" {
-		query = query.Where("tracks.album_id = ?", albumID)
+	// 2. Get Total Count (reflects every filter)
+	var total int64
+	if err := filtered().Count(&total).Error; err != nil {
+		slog.Error("Failed to count tracks", "error", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Database error"})
+		return
 	}
 
-	// 4. Apply Search
-	if search != "" {
-		searchTerm := "%" + search + "%"
-		query = query.Where(
-			"tracks.title ILIKE ? OR EXISTS (SELECT 1 FROM track_artists ta JOIN artists a ON a.id = ta.artist_id WHERE ta.track_id = tracks.id AND a.name ILIKE ?)",
-			searchTerm, searchTerm,
-		)
+	// 3. Apply Sorting: searches rank by relevance unless asked otherwise
+	defaultSort := "newest"
+	if filter.Search != "" {
+		defaultSort = "relevance"
 	}
+	sortBy := c.DefaultQuery("sort", defaultSort)
 
-	// 5. Get Total Count (Now accurately reflects the album filter)
-	var total int64
-	query.Count(&total)
+	query := filtered().Preload("Artists").Preload("Album")
+	keyset, isKeyset := librarySorts[sortBy]
+	if tsq := prefixTSQuery(filter.Search); sortBy == "relevance" && tsq != "" {
+		query = query.Order(gorm.Expr("ts_rank(tracks.search_vector, to_tsquery('simple', ?)) DESC, tracks.id DESC", tsq))
+	} else {
+		if !isKeyset {
+			keyset, isKeyset = librarySorts["newest"], true
+		}
+		query = keyset.order(query)
+	}
 
-	// 6. Apply Sorting
-	switch sortBy {
-	case "alphabetical":
-		query = query.Order("tracks.title ASC")
-	case "duration":
-		query = query.Order("tracks.duration DESC")
-	default:
-		query = query.Order("tracks.id DESC")
+	// 4. Page: after the cursor when given, else by offset
+	if cursor := c.Query("cursor"); cursor != "" {
+		if !isKeyset {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "cursor is not supported with relevance sorting, use offset"})
+			return
+		}
+		cur, err := decodeLibraryCursor(cursor)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid cursor"})
+			return
+		}
+		query = keyset.after(query, cur)
+		offset = 0
 	}
 
-	// 7. Fetch Models
+	// 5. Fetch Models, one extra to know whether another page follows
 	var tracks []models.Track
-	result := query.Limit(limit).Offset(offset).Find(&tracks)
-
-	if result.Error != nil {
-		slog.Error("Failed to fetch tracks", "error", result.Error)
+	if err := query.Limit(limit + 1).Offset(offset).Find(&tracks).Error; err != nil {
+		slog.Error("Failed to fetch tracks", "error", err)
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Database error"})
 		return
 	}
 
-	var libraryTracks []LibraryTrack
-	for _, t := range tracks {
-		var artistNames []string
-		for _, a := range t.Artists {
-			artistNames = append(artistNames, a.Name)
-		}
-		artistStr := "Unknown Artist"
-		if len(artistNames) > 0 {
-			artistStr = strings.Join(artistNames, ", ")
+	var nextCursor string
+	if len(tracks) > limit {
+		tracks = tracks[:limit]
+		if isKeyset {
+			nextCursor = keyset.cursorFor(tracks[len(tracks)-1]).encode()
 		}
+	}
 
-		var coverURL string
-		if t.Album.ID != 0 && t.Album.CoverKey != "" {
-			coverURL = h.cdn.BuildAssetURL(t.Album.CoverKey, orgID.String())
-		}
+	libraryTracks := make([]LibraryTrack, 0, len(tracks))
+	for _, t := range tracks {
+		libraryTracks = append(libraryTracks, h.toLibraryTrack(t, orgID))
+	}
 
-		libraryTracks = append(libraryTracks, LibraryTrack{
-			ID:                t.ID,
-			Title:             t.Title,
-			Artist:            artistStr,
-			Album:             t.Album.Title,
-			Duration:          t.Duration,
-			CoverURL:          coverURL,
-			BPM:               t.BPM,
-			MusicalKey:        t.MusicalKey,
-			Scale:             t.Scale,
-			Style:             t.Style,
-			Status:            t.ProcessingStatus,
-			Genre:             t.Genre,
-			Energy:            t.Energy,
-			MLMoods:           t.MLMoods,
-			MLGenres:          t.MLGenres,
-			MLCharacteristics: t.MLCharacteristics,
-		})
+	meta := gin.H{
+		"total":       total,
+		"limit":       limit,
+		"offset":      offset,
+		"next_cursor": nextCursor,
 	}
 
-	if libraryTracks == nil {
-		libraryTracks = []LibraryTrack{}
+	// 6. Facet counts, only when asked: each is a GROUP BY over the filtered library
+	if names := csvQuery(c, "facets"); len(names) > 0 {
+		facets, err := countFacets(h.db, filtered, names)
+		if err != nil {
+			slog.Error("Failed to count facets", "error", err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Database error"})
+			return
+		}
+		meta["facets"] = facets
 	}
 
 	c.JSON(http.StatusOK, gin.H{
 		"data": libraryTracks,
-		"meta": gin.H{
-			"total":  total,
-			"limit":  limit,
-			"offset": offset,
-		},
+		"meta": meta,
 	})
 }
 
+// toLibraryTrack flattens a track (with Artists and Album preloaded) for library listings
+func (h *TrackHandler) toLibraryTrack(t models.Track, orgID uuid.UUID) LibraryTrack {
+	var artistNames []string
+	for _, a := range t.Artists {
+		artistNames = append(artistNames, a.Name)
+	}
+	artistStr := "Unknown Artist"
+	if len(artistNames) > 0 {
+		artistStr = strings.Join(artistNames, ", ")
+	}
+
+	var coverURL string
+	if t.Album.ID != 0 && t.Album.CoverKey != "" {
+		coverURL = h.cdn.BuildAssetURL(t.Album.CoverKey, orgID.String())
+	}
+
+	return LibraryTrack{
+		ID:                t.ID,
+		Title:             t.Title,
+		Artist:            artistStr,
+		Album:             t.Album.Title,
+		Duration:          t.Duration,
+		CoverURL:          coverURL,
+		BPM:               t.BPM,
+		MusicalKey:        t.MusicalKey,
+		Scale:             t.Scale,
+		Style:             t.Style,
+		Status:            t.ProcessingStatus,
+		Genre:             t.Genre,
+		Energy:            t.Energy,
+		MLMoods:           t.MLMoods,
+		MLGenres:          t.MLGenres,
+		MLCharacteristics: t.MLCharacteristics,
+	}
+}
+
+// similarTrack is a library entry with its distance to the seed (0 = identical)
+type similarTrack struct {
+	LibraryTrack
+	Distance float64 `json:"distance"`
+}
+
+// GetSimilarTracks returns the tenant's tracks that sound closest to the given one
+func (h *TrackHandler) GetSimilarTracks(c *gin.Context) {
+	orgID, ok := getOrgID(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Organization context missing"})
+		return
+	}
+
+	limit, _ := strconv.Atoi(c.DefaultQuery("limit", "20"))
+	if limit < 1 || limit > 100 {
+		limit = 20
+	}
+
+	var seed models.Track
+	if err := h.db.Where("organization_id = ?", orgID).First(&seed, c.Param("id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Track not found"})
+		return
+	}
+
+	// Tracks analysed before feature vectors existed get theirs on first use
+	features := []float64(seed.Features)
+	if len(features) == 0 {
+		features = audio.FeatureVector(seed)
+		if len(features) == 0 {
+			c.JSON(http.StatusUnprocessableEntity, gin.H{"error": "Track has not been analysed yet"})
+			return
+		}
+		h.db.Model(&seed).Update("features", pq.Float64Array(features))
+	}
+
+	neighbours, err := dj.SimilarTracks(h.db, orgID, features, limit, seed.ID)
+	if err != nil {
+		slog.Error("Similarity search failed", "track_id", seed.ID, "error", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Database error"})
+		return
+	}
+
+	data := make([]similarTrack, 0, len(neighbours))
+	for _, n := range neighbours {
+		data = append(data, similarTrack{LibraryTrack: h.toLibraryTrack(n.Track, orgID), Distance: n.Distance})
+	}
+
+	c.JSON(http.StatusOK, gin.H{"data": data})
+}
+
 // GetTrack returns the FULL metadata for a single track
 func (h *TrackHandler) GetTrack(c *gin.Context) {
 	orgID, ok := getOrgID(c)
@@ -227,6 +318,7 @@ func (h *TrackHandler) UpdateTrack(c *gin.Context) {
 	delete(updateData, "duration")
 	delete(updateData, "file_size")
 	delete(updateData, "organization_id")
+	delete(updateData, "features")
 
 	result := h.db.Model(&models.Track{}).Where("id = ? AND organization_id = ?", id, orgID).Updates(updateData)
 	if result.Error != nil {
@@ -239,6 +331,13 @@ func (h *TrackHandler) UpdateTrack(c *gin.Context) {
 		return
 	}
 
+	// Corrected BPM, key or tags move the track in similarity space; a new title changes its search document
+	var track models.Track
+	if err := h.db.Where("organization_id = ?", orgID).First(&track, id).Error; err == nil {
+		h.db.Model(&track).Update("features", pq.Float64Array(audio.FeatureVector(track)))
+		database.RefreshTrackSearch(h.db, track.ID)
+	}
+
 	c.JSON(http.StatusOK, gin.H{"message": "Track updated successfully"})
 }
 
@@ -434,6 +533,9 @@ func (h *TrackHandler) UploadTrack(c *gin.Context) {
 
 		if len(albumUpdates) > 0 {
 			h.db.Model(&album).Updates(albumUpdates)
+			if _, labelled := albumUpdates["Publisher"]; labelled {
+				database.RefreshAlbumSearch(h.db, album.ID)
+			}
 		}
 		albumIDPtr = &album.ID
 	}
@@ -455,6 +557,7 @@ func (h *TrackHandler) UploadTrack(c *gin.Context) {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Database insert failed"})
 		return
 	}
+	database.RefreshTrackSearch(h.db, newTrack.ID) // Findable while it is still processing
 
 	redisAddr := fmt.Sprintf("%s:%s", h.config.Redis.Host, h.config.Redis.Port)
 
@@ -670,7 +773,11 @@ func (h *TrackHandler) Analysis(c *gin.Context) {
 		"is_retry": true,
 	}
 	payloadBytes, _ := json.Marshal(payloadData)
-	task := asynq.NewTask("track:process", payloadBytes)
+	taskType := "track:process"
+	if models.IsImagingKind(track.Kind) {
+		taskType = "imaging:process"
+	}
+	task := asynq.NewTask(taskType, payloadBytes)
 
 	_, err := asynqClient.Enqueue(task)
 	if err != nil {
@@ -17,6 +17,7 @@ import (
 	"momo-radio/internal/api/middleware"
 	"momo-radio/internal/config"
 	database "momo-radio/internal/db"
+	"momo-radio/internal/scheduler"
 	"momo-radio/internal/storage"
 	"momo-radio/internal/utils"
 )
@@ -75,17 +76,23 @@ func (s *Server) setupMiddleware() {
 func (s *Server) setupRoutes() {
 	cdn := utils.NewCDNBuilder(s.cfg, s.storage)
 
+	// Shared so a settings change reaches the API's scheduler and stats right away;
+	// the radio engine is told through radio.control
+	timezones := scheduler.NewTimezones(s.db.DB, s.cfg.Server.Timezone)
+
 	authHandler := handlers.NewAuthHandler(s.db.DB)
-	statsHandler := handlers.NewStatsHandler(s.db.DB)
+	statsHandler := handlers.NewStatsHandler(s.db.DB, timezones)
 	trackHandler := handlers.NewTrackHandler(s.db.DB, s.storage, s.cfg, s.redis, cdn)
 	playlistHandler := handlers.NewPlaylistHandler(s.db.DB, s.storage, cdn)
-	schedulerHandler := handlers.NewSchedulerHandler(s.db.DB, s.cfg)
+	schedulerHandler := handlers.NewSchedulerHandler(s.db.DB, s.cfg, timezones)
+	ruleSetHandler := handlers.NewRuleSetHandler(s.db.DB)
+	imagingHandler := handlers.NewImagingHandler(s.db.DB, s.storage, s.cfg)
 	artistHandler := handlers.NewArtistHandler(s.db.DB, s.storage, cdn)
 	albumHandler := handlers.NewAlbumHandler(s.db.DB, s.storage, cdn)
 	exportHandler := handlers.NewExportHandler(s.asynqClient)
 	broadcastHandler := handlers.NewBroadcastHandler(s.db.DB, s.redis, cdn)
 	pageHandler := handlers.NewPublicPageHandler(s.db.DB, s.storage, s.cfg)
-	settingsHandler := handlers.NewSettingsHandler(s.db.DB)
+	settingsHandler := handlers.NewSettingsHandler(s.db.DB, s.redis, timezones)
 	profileHandler := handlers.NewProfileHandler(s.db.DB)
 	membersHandler := handlers.NewMembersHandler(s.db.DB)
 
@@ -100,7 +107,8 @@ func (s *Server) setupRoutes() {
 	// ==========================================
 	internal := s.router.Group("/api/internal")
 	{
-		internal.POST("/auth-publish", handlers.AuthStreamPublish(s.db.DB))
+		internal.POST("/auth-publish", handlers.AuthStreamPublish(s.db.DB, s.redis))
+		internal.POST("/auth-unpublish", handlers.AuthStreamUnpublish(s.db.DB, s.redis))
 	}
 
 	// ==========================================
@@ -125,6 +133,9 @@ func (s *Server) setupRoutes() {
 		{
 			// --- STATS ---
 			protected.GET("/stats", middleware.RequireSupabaseAuth(s.db.DB, s.cfg.Supabase.JWTPublicKey, "owner", "admin", "editor", "viewer"), statsHandler.GetStats)
+			protected.GET("/stats/listeners", middleware.RequireSupabaseAuth(s.db.DB, s.cfg.Supabase.JWTPublicKey, "owner", "admin", "editor", "viewer"), statsHandler.GetListenerStats)
+			protected.GET("/stats/listeners/timeseries", middleware.RequireSupabaseAuth(s.db.DB, s.cfg.Supabase.JWTPublicKey, "owner", "admin", "editor", "viewer"), statsHandler.GetListenerTimeseries)
+			protected.GET("/stats/retention", middleware.RequireSupabaseAuth(s.db.DB, s.cfg.Supabase.JWTPublicKey, "owner", "admin", "editor", "viewer"), statsHandler.GetRetention)
 
 			// --- BILLING ---
 			protected.POST("/billing/checkout", middleware.RequireSupabaseAuth(s.db.DB, s.cfg.Supabase.JWTPublicKey, "owner", "admin"), billingHandler.CreateCheckout)
@@ -133,6 +144,10 @@ func (s *Server) setupRoutes() {
 			// --- TRACKS ---
 			protected.GET("/tracks", middleware.RequireSupabaseAuth(s.db.DB, s.cfg.Supabase.JWTPublicKey, "owner", "admin", "editor", "viewer"), trackHandler.GetTracks)
 			protected.GET("/tracks/:id", middleware.RequireSupabaseAuth(s.db.DB, s.cfg.Supabase.JWTPublicKey, "owner", "admin", "editor", "viewer"), trackHandler.GetTrack)
+			protected.GET("/tracks/:id/similar", middleware.RequireSupabaseAuth(s.db.DB, s.cfg.Supabase.JWTPublicKey, "owner", "admin", "editor", "viewer"), trackHandler.GetSimilarTracks)
+			protected.GET("/duplicates", middleware.RequireSupabaseAuth(s.db.DB, s.cfg.Supabase.JWTPublicKey, "owner", "admin", "editor"), trackHandler.GetDuplicates)
+			protected.GET("/duplicates/report", middleware.RequireSupabaseAuth(s.db.DB, s.cfg.Supabase.JWTPublicKey, "owner", "admin", "editor"), trackHandler.GetDuplicateReport)
+			protected.POST("/duplicates/:id/resolve", middleware.RequireSupabaseAuth(s.db.DB, s.cfg.Supabase.JWTPublicKey, "owner", "admin", "editor"), trackHandler.ResolveDuplicate)
 			protected.GET("/tracks/:id/stream", middleware.RequireSupabaseAuth(s.db.DB, s.cfg.Supabase.JWTPublicKey, "owner", "admin", "editor", "viewer"), trackHandler.StreamTrack)
 			protected.GET("/tracks/:id/status-stream", middleware.RequireSupabaseAuth(s.db.DB, s.cfg.Supabase.JWTPublicKey, "owner", "admin", "editor", "viewer"), trackHandler.TrackStatusStream)
 			protected.PUT("/tracks/:id", middleware.RequireSupabaseAuth(s.db.DB, s.cfg.Supabase.JWTPublicKey, "owner", "admin", "editor"), trackHandler.UpdateTrack)
@@ -153,6 +168,7 @@ func (s *Server) setupRoutes() {
 			protected.GET("/playlists", middleware.RequireSupabaseAuth(s.db.DB, s.cfg.Supabase.JWTPublicKey, "owner", "admin", "editor", "viewer"), playlistHandler.GetPlaylists)
 			protected.GET("/playlists/:id", middleware.RequireSupabaseAuth(s.db.DB, s.cfg.Supabase.JWTPublicKey, "owner", "admin", "editor", "viewer"), playlistHandler.GetPlaylist)
 			protected.POST("/playlists", middleware.RequireSupabaseAuth(s.db.DB, s.cfg.Supabase.JWTPublicKey, "owner", "admin", "editor"), playlistHandler.CreatePlaylist)
+			protected.POST("/playlists/generate", middleware.RequireSupabaseAuth(s.db.DB, s.cfg.Supabase.JWTPublicKey, "owner", "admin", "editor"), playlistHandler.GeneratePlaylist)
 			protected.DELETE("/playlists/:id", middleware.RequireSupabaseAuth(s.db.DB, s.cfg.Supabase.JWTPublicKey, "owner", "admin", "editor"), playlistHandler.DeletePlaylist)
 			protected.PUT("/playlists/:id", middleware.RequireSupabaseAuth(s.db.DB, s.cfg.Supabase.JWTPublicKey, "owner", "admin", "editor"), playlistHandler.UpdatePlaylist)
 			protected.PUT("/playlists/:id/tracks", middleware.RequireSupabaseAuth(s.db.DB, s.cfg.Supabase.JWTPublicKey, "owner", "admin", "editor"), playlistHandler.UpdatePlaylistTracks)
@@ -161,12 +177,36 @@ func (s *Server) setupRoutes() {
 			// --- SCHEDULING ---
 			protected.GET("/schedules", middleware.RequireSupabaseAuth(s.db.DB, s.cfg.Supabase.JWTPublicKey, "owner", "admin", "editor", "viewer"), schedulerHandler.GetSchedule)
 			protected.POST("/schedules", middleware.RequireSupabaseAuth(s.db.DB, s.cfg.Supabase.JWTPublicKey, "owner", "admin"), schedulerHandler.CreateScheduleSlot)
+			protected.PUT("/schedules/:id", middleware.RequireSupabaseAuth(s.db.DB, s.cfg.Supabase.JWTPublicKey, "owner", "admin"), schedulerHandler.UpdateScheduleSlot)
 			protected.DELETE("/schedules/:id", middleware.RequireSupabaseAuth(s.db.DB, s.cfg.Supabase.JWTPublicKey, "owner", "admin"), schedulerHandler.DeleteScheduleSlot)
 
+			// --- RULE SETS ---
+			protected.GET("/rulesets", middleware.RequireSupabaseAuth(s.db.DB, s.cfg.Supabase.JWTPublicKey, "owner", "admin", "editor", "viewer"), ruleSetHandler.GetRuleSets)
+			protected.GET("/rulesets/:id", middleware.RequireSupabaseAuth(s.db.DB, s.cfg.Supabase.JWTPublicKey, "owner", "admin", "editor", "viewer"), ruleSetHandler.GetRuleSet)
+			protected.POST("/rulesets", middleware.RequireSupabaseAuth(s.db.DB, s.cfg.Supabase.JWTPublicKey, "owner", "admin", "editor"), ruleSetHandler.CreateRuleSet)
+			protected.POST("/rulesets/preview", middleware.RequireSupabaseAuth(s.db.DB, s.cfg.Supabase.JWTPublicKey, "owner", "admin", "editor"), ruleSetHandler.PreviewRuleSet)
+			protected.PUT("/rulesets/:id", middleware.RequireSupabaseAuth(s.db.DB, s.cfg.Supabase.JWTPublicKey, "owner", "admin", "editor"), ruleSetHandler.UpdateRuleSet)
+			protected.DELETE("/rulesets/:id", middleware.RequireSupabaseAuth(s.db.DB, s.cfg.Supabase.JWTPublicKey, "owner", "admin"), ruleSetHandler.DeleteRuleSet)
+
+			// --- STATION IMAGING (jingles, IDs, sweepers, promos) ---
+			protected.POST("/imaging/upload", middleware.RequireSupabaseAuth(s.db.DB, s.cfg.Supabase.JWTPublicKey, "owner", "admin", "editor"), imagingHandler.UploadImaging)
+			protected.GET("/imaging/rules", middleware.RequireSupabaseAuth(s.db.DB, s.cfg.Supabase.JWTPublicKey, "owner", "admin", "editor", "viewer"), imagingHandler.GetInsertionRules)
+			protected.POST("/imaging/rules", middleware.RequireSupabaseAuth(s.db.DB, s.cfg.Supabase.JWTPublicKey, "owner", "admin", "editor"), imagingHandler.CreateInsertionRule)
+			protected.PUT("/imaging/rules/:id", middleware.RequireSupabaseAuth(s.db.DB, s.cfg.Supabase.JWTPublicKey, "owner", "admin", "editor"), imagingHandler.UpdateInsertionRule)
+			protected.DELETE("/imaging/rules/:id", middleware.RequireSupabaseAuth(s.db.DB, s.cfg.Supabase.JWTPublicKey, "owner", "admin", "editor"), imagingHandler.DeleteInsertionRule)
+
 			// --- BROADCAST & MOUNT POINTS ---
 			protected.GET("/mounts", middleware.RequireSupabaseAuth(s.db.DB, s.cfg.Supabase.JWTPublicKey, "owner", "admin", "editor", "dj", "viewer"), handlers.GetMountPoints(s.db.DB, cdn))
+			protected.POST("/mounts", middleware.RequireSupabaseAuth(s.db.DB, s.cfg.Supabase.JWTPublicKey, "owner", "admin"), handlers.CreateMountPoint(s.db.DB, cdn))
+			protected.PUT("/mounts/:id", middleware.RequireSupabaseAuth(s.db.DB, s.cfg.Supabase.JWTPublicKey, "owner", "admin"), handlers.UpdateMountPoint(s.db.DB, cdn))
 			protected.GET("/broadcast/state", middleware.RequireSupabaseAuth(s.db.DB, s.cfg.Supabase.JWTPublicKey, "owner", "admin", "editor", "viewer"), broadcastHandler.GetStreamState)
 			protected.POST("/broadcast/toggle", middleware.RequireSupabaseAuth(s.db.DB, s.cfg.Supabase.JWTPublicKey, "owner", "admin", "editor"), broadcastHandler.ToggleStream)
+			protected.GET("/broadcast/incidents", middleware.RequireSupabaseAuth(s.db.DB, s.cfg.Supabase.JWTPublicKey, "owner", "admin", "editor", "viewer"), broadcastHandler.GetIncidents)
+			protected.GET("/broadcast/queue", middleware.RequireSupabaseAuth(s.db.DB, s.cfg.Supabase.JWTPublicKey, "owner", "admin", "editor", "dj", "viewer"), broadcastHandler.GetQueue)
+			protected.POST("/broadcast/queue", middleware.RequireSupabaseAuth(s.db.DB, s.cfg.Supabase.JWTPublicKey, "owner", "admin", "editor", "dj"), broadcastHandler.AddToQueue)
+			protected.PUT("/broadcast/queue", middleware.RequireSupabaseAuth(s.db.DB, s.cfg.Supabase.JWTPublicKey, "owner", "admin", "editor"), broadcastHandler.ReorderQueue)
+			protected.PUT("/broadcast/queue/:id", middleware.RequireSupabaseAuth(s.db.DB, s.cfg.Supabase.JWTPublicKey, "owner", "admin", "editor"), broadcastHandler.UpdateQueueItem)
+			protected.DELETE("/broadcast/queue/:id", middleware.RequireSupabaseAuth(s.db.DB, s.cfg.Supabase.JWTPublicKey, "owner", "admin", "editor"), broadcastHandler.RemoveFromQueue)
 
 			// --- PUBLIC PAGE ---
 			protected.GET("/public-page", middleware.RequireSupabaseAuth(s.db.DB, s.cfg.Supabase.JWTPublicKey, "owner", "admin", "editor", "viewer"), pageHandler.GetSettings)
@@ -176,6 +216,7 @@ func (s *Server) setupRoutes() {
 			// --- SETTINGS ---
 			protected.GET("/settings", middleware.RequireSupabaseAuth(s.db.DB, s.cfg.Supabase.JWTPublicKey, "owner", "admin", "editor", "viewer"), settingsHandler.GetOrgSettings)
 			protected.PUT("/settings", middleware.RequireSupabaseAuth(s.db.DB, s.cfg.Supabase.JWTPublicKey, "owner", "admin"), settingsHandler.UpdateOrgSettings)
+			protected.GET("/settings/processing-presets", middleware.RequireSupabaseAuth(s.db.DB, s.cfg.Supabase.JWTPublicKey, "owner", "admin", "editor", "viewer"), settingsHandler.GetProcessingPresets)
 
 			// TEAM MANAGEMENT
 			protected.GET("/settings/members", middleware.RequireSupabaseAuth(s.db.DB, s.cfg.Supabase.JWTPublicKey, "owner", "admin", "editor", "viewer"), membersHandler.GetMembers)
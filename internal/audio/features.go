@@ -0,0 +1,102 @@
+package audio
+
+import (
+	"math"
+	"sort"
+	"strings"
+
+	"momo-radio/internal/models"
+)
+
+// Feature vector weights: how much each aspect counts in the distance between two tracks
+const (
+	featureTempoWeight = 1.0
+	featureKeyWeight   = 0.5
+	featureLevelWeight = 0.75 // energy, danceability, loudness
+	featureTagWeight   = 0.35
+)
+
+// featureTags is the ML tag vocabulary, one dimension each. Changing MLTagTranslations changes the
+// vector layout: rebuild stored vectors with the worker's -repair-features.
+var featureTags = func() []string {
+	seen := make(map[string]bool)
+	var tags []string
+	for _, mapping := range MLTagTranslations {
+		for _, v := range mapping.Values {
+			if key := strings.ToLower(v); !seen[key] {
+				seen[key] = true
+				tags = append(tags, key)
+			}
+		}
+	}
+	sort.Strings(tags)
+	return tags
+}()
+
+// FeatureVector turns a track's analysis into a fixed-length vector; similar-sounding tracks are close
+// in Euclidean distance. Returns nil for tracks that were never analysed.
+func FeatureVector(t models.Track) []float64 {
+	if t.BPM <= 0 && t.Energy <= 0 && len(t.MLMoods)+len(t.MLGenres)+len(t.MLCharacteristics) == 0 {
+		return nil
+	}
+
+	v := make([]float64, 0, 7+len(featureTags))
+
+	// 1. Tempo on a log scale, so 120 vs 126 is as far apart as 60 vs 63
+	tempo := 0.0
+	if t.BPM > 0 {
+		tempo = clamp01(math.Log2(t.BPM/60) / 2) // 60 -> 0, 240 -> 1
+	}
+	v = append(v, tempo*featureTempoWeight)
+
+	// 2. Key as a position on the Camelot wheel, plus major/minor: neighbours on the wheel are close
+	var keyX, keyY, mode float64
+	if c, ok := toCamelot(t.MusicalKey, t.Scale); ok {
+		angle := float64(c.Num) / 12 * 2 * math.Pi
+		keyX, keyY = (math.Cos(angle)+1)/2, (math.Sin(angle)+1)/2
+		if c.Letter == "B" {
+			mode = 1
+		}
+	}
+	v = append(v, keyX*featureKeyWeight, keyY*featureKeyWeight, mode*featureKeyWeight/2)
+
+	// 3. Levels
+	v = append(v,
+		clamp01(t.Energy)*featureLevelWeight,
+		clamp01(t.Danceability/3)*featureLevelWeight, // Essentia danceability is 0..3
+		clamp01(t.Loudness)*featureLevelWeight,       // Essentia average_loudness is 0..1
+	)
+
+	// 4. ML tags, one-hot
+	tags := make(map[string]bool)
+	for _, list := range [][]string{t.MLMoods, t.MLGenres, t.MLCharacteristics} {
+		for _, tag := range list {
+			tags[strings.ToLower(tag)] = true
+		}
+	}
+	for _, tag := range featureTags {
+		if tags[tag] {
+			v = append(v, featureTagWeight)
+		} else {
+			v = append(v, 0)
+		}
+	}
+	return v
+}
+
+func clamp01(x float64) float64 {
+	return math.Min(math.Max(x, 0), 1)
+}
+
+// FeatureDistance is the Euclidean distance between two feature vectors, or -1 when they cannot be compared
+func FeatureDistance(a, b []float64) float64 {
+	if len(a) == 0 || len(a) != len(b) {
+		return -1
+	}
+	sum := 0.0
+	for i := range a {
+		d := a[i] - b[i]
+		sum += d * d
+	}
+	return math.Sqrt(sum)
+}
@@ -0,0 +1,42 @@
+package audio
+
+import (
+	"testing"
+
+	"momo-radio/internal/models"
+)
+
+func TestFeatureVector(t *testing.T) {
+	seed := models.Track{BPM: 124, MusicalKey: "A", Scale: "minor", Energy: 0.7, Danceability: 1.8, Loudness: 0.8, MLGenres: []string{"House"}}
+	near := models.Track{BPM: 126, MusicalKey: "E", Scale: "minor", Energy: 0.65, Danceability: 1.7, Loudness: 0.75, MLGenres: []string{"house"}}
+	far := models.Track{BPM: 70, MusicalKey: "F#", Scale: "major", Energy: 0.1, Danceability: 0.4, Loudness: 0.2, MLMoods: []string{"Sad"}}
+
+	a, b, c := FeatureVector(seed), FeatureVector(near), FeatureVector(far)
+	if len(a) == 0 || len(a) != len(b) || len(a) != len(c) {
+		t.Fatalf("vectors must share a fixed length, got %d, %d, %d", len(a), len(b), len(c))
+	}
+
+	if d := FeatureDistance(a, a); d != 0 {
+		t.Errorf("distance to itself = %v, want 0", d)
+	}
+	if dn, df := FeatureDistance(a, b), FeatureDistance(a, c); dn >= df {
+		t.Errorf("near track (%.3f) should be closer than far track (%.3f)", dn, df)
+	}
+
+	// Loudness alone must move a track
+	quiet := seed
+	quiet.Loudness = 0.3
+	if d := FeatureDistance(a, FeatureVector(quiet)); d <= 0 {
+		t.Errorf("loudness 0.8 vs 0.3 should differ, got distance %v", d)
+	}
+
+	if v := FeatureVector(models.Track{Title: "Never analysed"}); v != nil {
+		t.Errorf("unanalysed track should have no vector, got %d dims", len(v))
+	}
+	if d := FeatureDistance(a, a[:3]); d != -1 {
+		t.Errorf("mismatched lengths = %v, want -1", d)
+	}
+	if d := FeatureDistance(nil, nil); d != -1 {
+		t.Errorf("empty vectors = %v, want -1", d)
+	}
+}
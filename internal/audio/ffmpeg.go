@@ -106,3 +106,17 @@ func Validate(path string) error {
 
 	return nil
 }
+
+// ProbeDuration returns the length of the audio file in seconds.
+// Unlike Validate it accepts short files such as jingles and station IDs.
+func ProbeDuration(path string) (float64, error) {
+	out, err := exec.Command("ffprobe", "-v", "error", "-show_entries", "format=duration", "-of", "default=noprint_wrappers=1:nokey=1", path).Output()
+	if err != nil {
+		return 0, fmt.Errorf("ffprobe failed: %w", err)
+	}
+	duration, err := strconv.ParseFloat(strings.TrimSpace(string(out)), 64)
+	if err != nil || duration <= 0 {
+		return 0, fmt.Errorf("no audio duration")
+	}
+	return duration, nil
+}
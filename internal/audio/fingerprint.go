@@ -3,12 +3,77 @@ package audio
 import (
 	"encoding/json"
 	"fmt"
+	"math/bits"
 	"net/http"
 	"net/url"
 	"os/exec"
 	"time"
 )
 
+const (
+	// fingerprintMaxOffset is how far (in fingerprint items, ~0.124s each) two fingerprints are slid
+	// against each other, covering different lead-in silence or a trimmed intro
+	fingerprintMaxOffset = 80
+	// fingerprintMinOverlap is the fewest items two fingerprints must share to be compared at all
+	fingerprintMinOverlap = 50
+)
+
+// RawFingerprint is the uncompressed Chromaprint of a file's first two minutes
+type RawFingerprint struct {
+	Duration float64
+	Items    []uint32
+}
+
+// Fingerprint runs fpcalc for the raw Chromaprint, which (unlike the compressed one AcoustID takes)
+// can be compared bit by bit
+func Fingerprint(filePath string) (*RawFingerprint, error) {
+	out, err := exec.Command("fpcalc", "-raw", "-json", filePath).Output()
+	if err != nil {
+		return nil, fmt.Errorf("fpcalc failed (is it installed?): %w", err)
+	}
+
+	var result struct {
+		Duration    float64 `json:"duration"`
+		Fingerprint []int64 `json:"fingerprint"` // Signed or unsigned depending on the fpcalc version
+	}
+	if err := json.Unmarshal(out, &result); err != nil {
+		return nil, fmt.Errorf("failed to parse fpcalc output: %w", err)
+	}
+	if len(result.Fingerprint) == 0 {
+		return nil, fmt.Errorf("fpcalc returned an empty fingerprint")
+	}
+
+	fp := &RawFingerprint{Duration: result.Duration, Items: make([]uint32, len(result.Fingerprint))}
+	for i, v := range result.Fingerprint {
+		fp.Items[i] = uint32(v)
+	}
+	return fp, nil
+}
+
+// FingerprintSimilarity is the share of matching bits (0..1) between two raw fingerprints at their
+// best alignment. Unrelated audio scores around 0.5, the same recording in another encoding 0.9+.
+func FingerprintSimilarity(a, b []uint32) float64 {
+	best := 0.0
+	for offset := -fingerprintMaxOffset; offset <= fingerprintMaxOffset; offset++ {
+		matching, overlap := 0, 0
+		for i := range a {
+			j := i + offset
+			if j < 0 || j >= len(b) {
+				continue
+			}
+			matching += 32 - bits.OnesCount32(a[i]^b[j])
+			overlap++
+		}
+		if overlap < fingerprintMinOverlap {
+			continue
+		}
+		if score := float64(matching) / float64(overlap*32); score > best {
+			best = score
+		}
+	}
+	return best
+}
+
 // GetMusicBrainzID generates an audio fingerprint and queries AcoustID for the exact MBID.
 func GetMusicBrainzID(filePath string, apiKey string) (string, error) {
 	if apiKey == "" {
@@ -0,0 +1,38 @@
+package audio
+
+import (
+	"math/rand"
+	"testing"
+)
+
+func TestFingerprintSimilarity(t *testing.T) {
+	rng := rand.New(rand.NewSource(1))
+	original := make([]uint32, 900)
+	for i := range original {
+		original[i] = rng.Uint32()
+	}
+
+	// Same recording, 3 seconds more lead-in and a few flipped bits from re-encoding
+	reencoded := make([]uint32, 24, 924)
+	for _, v := range original {
+		reencoded = append(reencoded, v^(1<<uint(rng.Intn(32))))
+	}
+
+	other := make([]uint32, 900)
+	for i := range other {
+		other[i] = rng.Uint32()
+	}
+
+	if s := FingerprintSimilarity(original, original); s != 1 {
+		t.Errorf("identical = %.3f, want 1", s)
+	}
+	if s := FingerprintSimilarity(original, reencoded); s < 0.95 {
+		t.Errorf("shifted re-encode = %.3f, want >= 0.95", s)
+	}
+	if s := FingerprintSimilarity(original, other); s > 0.6 {
+		t.Errorf("unrelated = %.3f, want about 0.5", s)
+	}
+	if s := FingerprintSimilarity(original, original[:10]); s != 0 {
+		t.Errorf("too short to compare = %.3f, want 0", s)
+	}
+}
@@ -0,0 +1,83 @@
+package audio
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"math"
+	"os/exec"
+	"strconv"
+	"strings"
+)
+
+// Playout loudness compensation
+const (
+	DefaultTargetLUFS = -14.0
+	// truePeakCeiling is the true peak a boosted track may reach
+	truePeakCeiling = -1.0
+	// maxLoudnessGain bounds the correction either way, so a mis-measured file cannot blast or vanish
+	maxLoudnessGain = 12.0
+)
+
+// Loudness is an EBU R128 measurement of a whole file
+type Loudness struct {
+	Integrated float64 // LUFS
+	TruePeak   float64 // dBTP
+}
+
+// MeasureLoudness runs ffmpeg's EBU R128 meter over the file
+func MeasureLoudness(path string) (Loudness, error) {
+	cmd := exec.Command("ffmpeg", "-hide_banner", "-nostats", "-i", path,
+		"-vn", "-map", "0:a:0",
+		"-af", "ebur128=peak=true:framelog=verbose", // Summary only, not one line per 100ms
+		"-f", "null", "-")
+
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		return Loudness{}, fmt.Errorf("loudness measurement failed: %w", err)
+	}
+	return parseEBUR128(stderr.String())
+}
+
+// parseEBUR128 reads the integrated loudness and true peak from the ebur128 filter's summary
+func parseEBUR128(output string) (Loudness, error) {
+	start := strings.LastIndex(output, "Summary:")
+	if start < 0 {
+		return Loudness{}, errors.New("no loudness summary in ffmpeg output")
+	}
+
+	var l Loudness
+	var haveI, havePeak bool
+	for _, line := range strings.Split(output[start:], "\n") {
+		fields := strings.Fields(line)
+		if len(fields) < 2 {
+			continue
+		}
+		v, err := strconv.ParseFloat(fields[1], 64)
+		if err != nil {
+			continue
+		}
+		switch fields[0] {
+		case "I:":
+			l.Integrated, haveI = v, true
+		case "Peak:":
+			l.TruePeak, havePeak = max(v, SilenceFloorDBFS), true // -inf on digital silence
+		}
+	}
+
+	if !haveI || !havePeak || math.IsInf(l.Integrated, 0) {
+		return Loudness{}, errors.New("incomplete loudness summary")
+	}
+	return l, nil
+}
+
+// LoudnessGain is the gain in dB that brings a file measured at l to target LUFS. A boost stops
+// where the true peak would pass -1 dBTP; the result stays within ±12 dB.
+func LoudnessGain(l Loudness, target float64) float64 {
+	gain := target - l.Integrated
+	if gain > 0 {
+		gain = min(gain, max(0, truePeakCeiling-l.TruePeak))
+	}
+	return math.Min(math.Max(gain, -maxLoudnessGain), maxLoudnessGain)
+}
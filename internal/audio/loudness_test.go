@@ -0,0 +1,55 @@
+package audio
+
+import (
+	"math"
+	"testing"
+)
+
+const ebur128Output = `[Parsed_ebur128_0 @ 0x55d5c1a3c0c0] Summary:
+
+  Integrated loudness:
+    I:         -19.3 LUFS
+    Threshold: -29.6 LUFS
+
+  Loudness range:
+    LRA:         6.1 LU
+    Threshold: -39.7 LUFS
+    LRA low:   -23.4 LUFS
+    LRA high:  -17.3 LUFS
+
+  True peak:
+    Peak:       -4.2 dBFS
+`
+
+func TestParseEBUR128(t *testing.T) {
+	l, err := parseEBUR128(ebur128Output)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if l.Integrated != -19.3 || l.TruePeak != -4.2 {
+		t.Errorf("got %+v, want I=-19.3 TP=-4.2", l)
+	}
+
+	if _, err := parseEBUR128("Input #0, mp3, from 'x.mp3':"); err == nil {
+		t.Error("output without a summary must fail")
+	}
+}
+
+func TestLoudnessGain(t *testing.T) {
+	tests := []struct {
+		name string
+		l    Loudness
+		want float64
+	}{
+		{"quiet with headroom", Loudness{Integrated: -19, TruePeak: -8}, 5},
+		{"boost held back by the true peak", Loudness{Integrated: -19.3, TruePeak: -4.2}, 3.2},
+		{"loud track is turned down", Loudness{Integrated: -8, TruePeak: 0.5}, -6},
+		{"no headroom at all", Loudness{Integrated: -20, TruePeak: -0.5}, 0},
+		{"near silent file is bounded", Loudness{Integrated: -60, TruePeak: -50}, 12},
+	}
+	for _, tt := range tests {
+		if got := LoudnessGain(tt.l, DefaultTargetLUFS); math.Abs(got-tt.want) > 1e-9 {
+			t.Errorf("%s: got %.2f dB, want %.2f", tt.name, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,110 @@
+package audio
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"momo-radio/internal/config"
+)
+
+// MasterPlaylistName is uploaded next to the per-mount rendition folders.
+const MasterPlaylistName = "master.m3u8"
+
+// MasterMount is the mount name players ask /listen for to get the master playlist, so no mount may take it.
+const MasterMount = "master"
+
+// mpegtsOverhead approximates the MPEG-TS/PES framing added on top of the audio bitrate.
+const mpegtsOverhead = 1.1
+
+// Rendition is one variant stream referenced by the master playlist.
+type Rendition struct {
+	Name    string
+	URI     string // Relative to the master playlist, e.g. "radio/stream.m3u8"
+	Bitrate int    // kbps
+}
+
+// StreamCodec returns the ffmpeg encoder used for the live HLS renditions.
+func StreamCodec(cfg *config.Config) string {
+	return fallbackStr(cfg.Radio.AudioCodec, "libmp3lame")
+}
+
+// BuildMasterPlaylist renders an HLS master playlist listing every rendition, highest bitrate first.
+func BuildMasterPlaylist(renditions []Rendition, codec string) []byte {
+	sorted := append([]Rendition(nil), renditions...)
+	sort.SliceStable(sorted, func(i, j int) bool { return sorted[i].Bitrate > sorted[j].Bitrate })
+
+	var b strings.Builder
+	b.WriteString("#EXTM3U\n")
+	b.WriteString("#EXT-X-VERSION:3\n")
+	b.WriteString("#EXT-X-INDEPENDENT-SEGMENTS\n")
+
+	for _, r := range sorted {
+		avg := r.Bitrate * 1000
+		peak := int(float64(avg) * mpegtsOverhead)
+		fmt.Fprintf(&b, "#EXT-X-STREAM-INF:BANDWIDTH=%d,AVERAGE-BANDWIDTH=%d,CODECS=\"%s\",NAME=\"%s\"\n",
+			peak, avg, hlsCodecTag(codec), strings.ReplaceAll(r.Name, "\"", "'"))
+		b.WriteString(r.URI + "\n")
+	}
+
+	return []byte(b.String())
+}
+
+// AddDiscontinuities tags each segment of a media playlist whose sequence number is in breaks with
+// #EXT-X-DISCONTINUITY, so players re-sync timestamps after an encoder restart. The breaks that already
+// slid out of the window are counted in #EXT-X-DISCONTINUITY-SEQUENCE.
+func AddDiscontinuities(playlist []byte, breaks []int64) []byte {
+	if len(breaks) == 0 || strings.Contains(string(playlist), "#EXT-X-DISCONTINUITY-SEQUENCE") {
+		return playlist
+	}
+
+	isBreak := make(map[int64]bool, len(breaks))
+	for _, b := range breaks {
+		isBreak[b] = true
+	}
+
+	lines := strings.Split(string(playlist), "\n")
+	out := make([]string, 0, len(lines)+len(breaks)+1)
+	seq := int64(-1) // Sequence number of the next segment, -1 until #EXT-X-MEDIA-SEQUENCE is read
+
+	for _, line := range lines {
+		switch {
+		case strings.HasPrefix(line, "#EXT-X-MEDIA-SEQUENCE:"):
+			first, err := strconv.ParseInt(strings.TrimSpace(strings.TrimPrefix(line, "#EXT-X-MEDIA-SEQUENCE:")), 10, 64)
+			if err != nil {
+				return playlist
+			}
+			gone := 0
+			for b := range isBreak {
+				if b < first {
+					gone++
+				}
+			}
+			seq = first
+			out = append(out, line, fmt.Sprintf("#EXT-X-DISCONTINUITY-SEQUENCE:%d", gone))
+			continue
+		case strings.HasPrefix(line, "#EXTINF:"):
+			if seq >= 0 && isBreak[seq] && (len(out) == 0 || out[len(out)-1] != "#EXT-X-DISCONTINUITY") {
+				out = append(out, "#EXT-X-DISCONTINUITY")
+			}
+		case seq >= 0 && line != "" && !strings.HasPrefix(line, "#"):
+			seq++ // A segment URI
+		}
+		out = append(out, line)
+	}
+
+	return []byte(strings.Join(out, "\n"))
+}
+
+// hlsCodecTag maps an ffmpeg encoder name to its RFC 6381 codec string.
+func hlsCodecTag(codec string) string {
+	switch strings.ToLower(codec) {
+	case "libmp3lame", "mp3":
+		return "mp4a.40.34"
+	case "libfdk_aac", "aac":
+		return "mp4a.40.2"
+	default:
+		return "mp4a.40.2"
+	}
+}
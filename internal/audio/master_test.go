@@ -0,0 +1,49 @@
+package audio
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestBuildMasterPlaylist(t *testing.T) {
+	playlist := string(BuildMasterPlaylist([]Rendition{
+		{Name: "Mobile", URI: "mobile/stream.m3u8", Bitrate: 64},
+		{Name: "HQ", URI: "radio/stream.m3u8", Bitrate: 320},
+	}, "aac"))
+
+	if !strings.HasPrefix(playlist, "#EXTM3U\n") {
+		t.Fatalf("playlist must start with #EXTM3U, got:\n%s", playlist)
+	}
+
+	// Highest bitrate is listed first
+	hq := strings.Index(playlist, "radio/stream.m3u8")
+	mobile := strings.Index(playlist, "mobile/stream.m3u8")
+	if hq == -1 || mobile == -1 || hq > mobile {
+		t.Errorf("expected HQ rendition before mobile, got:\n%s", playlist)
+	}
+
+	if !strings.Contains(playlist, `AVERAGE-BANDWIDTH=320000,CODECS="mp4a.40.2"`) {
+		t.Errorf("missing bandwidth/codec attributes for HQ rendition, got:\n%s", playlist)
+	}
+	if !strings.Contains(playlist, "BANDWIDTH=70400,") {
+		t.Errorf("expected peak bandwidth with TS overhead for mobile rendition, got:\n%s", playlist)
+	}
+}
+
+func TestAddDiscontinuities(t *testing.T) {
+	playlist := "#EXTM3U\n#EXT-X-VERSION:3\n#EXT-X-TARGETDURATION:4\n#EXT-X-MEDIA-SEQUENCE:10\n" +
+		"#EXTINF:4.000000,\nstream_1_010.ts\n#EXTINF:4.000000,\nstream_1_011.ts\n#EXTINF:4.000000,\nstream_1_012.ts\n"
+
+	got := string(AddDiscontinuities([]byte(playlist), []int64{5, 11}))
+
+	// The restart at 5 is out of the window, the one at 11 is tagged
+	want := "#EXTM3U\n#EXT-X-VERSION:3\n#EXT-X-TARGETDURATION:4\n#EXT-X-MEDIA-SEQUENCE:10\n#EXT-X-DISCONTINUITY-SEQUENCE:1\n" +
+		"#EXTINF:4.000000,\nstream_1_010.ts\n#EXT-X-DISCONTINUITY\n#EXTINF:4.000000,\nstream_1_011.ts\n#EXTINF:4.000000,\nstream_1_012.ts\n"
+	if got != want {
+		t.Errorf("unexpected playlist:\n%s\nwant:\n%s", got, want)
+	}
+
+	if out := AddDiscontinuities([]byte(playlist), nil); string(out) != playlist {
+		t.Errorf("playlist without breaks must be unchanged, got:\n%s", out)
+	}
+}
@@ -0,0 +1,426 @@
+package audio
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"os/exec"
+	"strings"
+	"time"
+)
+
+// Raw PCM format shared by the track decoder and the stream encoder.
+const (
+	PCMSampleRate = 44100
+	PCMChannels   = 2
+	pcmFrameSize  = PCMChannels * 2 // s16le
+)
+
+// Crossfade curves supported by the Mixer.
+const (
+	CurveLinear     = "linear"
+	CurveEqualPower = "equal_power"
+	CurveSCurve     = "s_curve"
+)
+
+// silenceFilter trims leading silence; the tail is cut by silentTailReader, since ffmpeg can only
+// tell a trailing silence from a pause in the music by buffering the whole track.
+const silenceFilter = "silenceremove=start_periods=1:start_threshold=-50dB:start_silence=0.1"
+
+// Tail trimming: samples under -50dBFS count as silent. A silence longer than maxHeldSilence
+// is taken for a deliberate gap (a hidden track follows) and airs in full rather than held in memory.
+const (
+	silenceThreshold = 104 // -50dBFS on the int16 scale
+	maxHeldSilence   = 60 * time.Second
+)
+
+// Talk-over defaults: music sits about 10dB under the voice, with short ramps either side.
+const (
+	DefaultDuckGain = 0.3
+	duckRamp        = 250 * time.Millisecond
+	maxTalkOver     = 60 * time.Second
+)
+
+// MixOptions controls how a track is joined to the one before it.
+type MixOptions struct {
+	Crossfade   time.Duration
+	Curve       string
+	TrimSilence bool
+
+	// GainDB brings the track to the mount's target loudness, see LoudnessGain
+	GainDB float64
+
+	// TalkOver is a file (station ID, jingle) voiced over the start of the track
+	// while the music is ducked to DuckGain underneath it.
+	TalkOver       string
+	TalkOverGainDB float64 // Brings the voice to the target loudness, like GainDB
+	DuckGain       float64
+}
+
+// IsValidCurve reports whether name is a crossfade curve the Mixer understands.
+func IsValidCurve(name string) bool {
+	switch strings.ToLower(name) {
+	case CurveLinear, CurveEqualPower, CurveSCurve:
+		return true
+	}
+	return false
+}
+
+// Mixer decodes consecutive tracks to PCM and writes them to a single output,
+// overlapping the tail of each track with the head of the next one.
+type Mixer struct {
+	out  io.Writer
+	tail []byte // End of the previous track, held back for the next crossfade
+}
+
+func NewMixer(out io.Writer) *Mixer {
+	return &Mixer{out: out}
+}
+
+// Play decodes the file at path and streams it to the mixer output.
+// It blocks until the track has been written, minus the tail kept for the next transition.
+// Cancelling ctx stops the track early; the held-back tail then fades into whatever plays next.
+func (m *Mixer) Play(ctx context.Context, path string, opts MixOptions) error {
+	return m.decode(ctx, []string{"-i", path}, opts)
+}
+
+// PlayLive pulls a live feed (RTMP/SRT URL) through the mixer until the publisher disconnects.
+func (m *Mixer) PlayLive(ctx context.Context, url string, opts MixOptions) error {
+	// A live feed has no tail to trim, and holding its pauses back would put the air behind the presenter
+	opts.TrimSilence = false
+	// Give up on a stalled connection instead of holding the air silent forever
+	return m.decode(ctx, []string{"-rw_timeout", "5000000", "-i", url}, opts)
+}
+
+func (m *Mixer) decode(ctx context.Context, input []string, opts MixOptions) error {
+	args := append([]string{"-hide_banner", "-loglevel", "error"}, input...)
+	args = append(args, "-vn", "-map", "0:a:0")
+	var filters []string
+	if opts.TrimSilence {
+		filters = append(filters, silenceFilter)
+	}
+	if opts.GainDB != 0 {
+		filters = append(filters, fmt.Sprintf("volume=%.2fdB", opts.GainDB))
+	}
+	if len(filters) > 0 {
+		args = append(args, "-af", strings.Join(filters, ","))
+	}
+	args = append(args,
+		"-f", "s16le",
+		"-acodec", "pcm_s16le",
+		"-ar", fmt.Sprint(PCMSampleRate),
+		"-ac", fmt.Sprint(PCMChannels),
+		"pipe:1",
+	)
+
+	cmd := exec.CommandContext(ctx, "ffmpeg", args...)
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return err
+	}
+	if err := cmd.Start(); err != nil {
+		return fmt.Errorf("decoder failed to start: %w", err)
+	}
+
+	var src io.Reader = stdout
+	if opts.TrimSilence {
+		src = &silentTailReader{src: src, limit: crossfadeBytes(maxHeldSilence)}
+	}
+	if opts.TalkOver != "" {
+		// A broken voice-over must not take the song down with it
+		if voice, err := decodePCM(ctx, opts.TalkOver, maxTalkOver, opts.TalkOverGainDB); err == nil && len(voice) > 0 {
+			gain := opts.DuckGain
+			if gain <= 0 || gain > 1 {
+				gain = DefaultDuckGain
+			}
+			src = &duckReader{src: src, voice: voice, gain: gain, ramp: int(duckRamp.Seconds() * PCMSampleRate)}
+		}
+	}
+
+	playErr := m.playPCM(src, crossfadeBytes(opts.Crossfade), opts.Curve)
+	if playErr != nil {
+		// Unblock the decoder if we stopped reading early
+		io.Copy(io.Discard, stdout)
+	}
+
+	waitErr := cmd.Wait()
+	if ctx.Err() != nil {
+		return ctx.Err()
+	}
+	if playErr != nil {
+		return playErr
+	}
+	if waitErr != nil {
+		return fmt.Errorf("decoder exited: %w", waitErr)
+	}
+	return nil
+}
+
+// decodePCM decodes at most limit of the file at path into memory, gainDB louder.
+func decodePCM(ctx context.Context, path string, limit time.Duration, gainDB float64) ([]byte, error) {
+	args := []string{"-hide_banner", "-loglevel", "error",
+		"-i", path, "-vn", "-map", "0:a:0", "-t", fmt.Sprint(limit.Seconds())}
+	if gainDB != 0 {
+		args = append(args, "-af", fmt.Sprintf("volume=%.2fdB", gainDB))
+	}
+	args = append(args,
+		"-f", "s16le", "-acodec", "pcm_s16le",
+		"-ar", fmt.Sprint(PCMSampleRate), "-ac", fmt.Sprint(PCMChannels),
+		"pipe:1",
+	)
+	out, err := exec.CommandContext(ctx, "ffmpeg", args...).Output()
+	if err != nil {
+		return nil, fmt.Errorf("talk-over decode failed: %w", err)
+	}
+	return out[:len(out)-len(out)%pcmFrameSize], nil
+}
+
+// duckReader mixes voice over the head of the music read from src, lowering
+// the music to gain (with `ramp` frames of fade either side) while the voice plays.
+type duckReader struct {
+	src   io.Reader
+	voice []byte
+	gain  float64
+	ramp  int // Frames
+	frame int // Music frames already returned
+}
+
+func (d *duckReader) Read(p []byte) (int, error) {
+	if d.frame >= len(d.voice)/pcmFrameSize+d.ramp {
+		return d.src.Read(p)
+	}
+
+	// Work on whole frames so the gain envelope stays aligned
+	aligned := len(p) - len(p)%pcmFrameSize
+	if aligned == 0 {
+		return d.src.Read(p)
+	}
+	n, err := io.ReadFull(d.src, p[:aligned])
+	if errors.Is(err, io.ErrUnexpectedEOF) {
+		err = nil // Short final read; the next one reports EOF
+	}
+
+	whole := n - n%pcmFrameSize
+	duckMix(p[:whole], d.voice, d.frame, d.gain, d.ramp)
+	d.frame += whole / pcmFrameSize
+	return n, err
+}
+
+// duckMix lowers the music in buf (starting at music frame `start`) under voice and adds the voice on top.
+func duckMix(buf, voice []byte, start int, gain float64, ramp int) {
+	voiceFrames := len(voice) / pcmFrameSize
+	frames := len(buf) / pcmFrameSize
+
+	for f := 0; f < frames; f++ {
+		i := start + f
+		g := 1.0
+		switch {
+		case i < ramp && i < voiceFrames:
+			g = 1 - (1-gain)*float64(i)/float64(ramp)
+		case i < voiceFrames:
+			g = gain
+		case i < voiceFrames+ramp:
+			g = gain + (1-gain)*float64(i-voiceFrames)/float64(ramp)
+		}
+
+		for ch := 0; ch < PCMChannels; ch++ {
+			b := f*pcmFrameSize + ch*2
+			v := float64(int16(binary.LittleEndian.Uint16(buf[b:]))) * g
+			if i < voiceFrames {
+				v += float64(int16(binary.LittleEndian.Uint16(voice[i*pcmFrameSize+ch*2:])))
+			}
+			binary.LittleEndian.PutUint16(buf[b:], uint16(clampInt16(v)))
+		}
+	}
+}
+
+// silentTailReader passes src through but holds silent frames back until audio follows them,
+// so a silence that runs into the end of the track is dropped instead of aired.
+type silentTailReader struct {
+	src     io.Reader
+	limit   int    // Held silence released anyway past this many bytes
+	pending []byte // Silent frames, then at most one partial frame
+	silent  int    // Bytes of pending already known to be silent
+	gap     bool   // The current silence outgrew limit and airs until the end
+	ready   []byte // Audio to return
+	chunk   []byte
+	err     error
+}
+
+func (r *silentTailReader) Read(p []byte) (int, error) {
+	for len(r.ready) == 0 {
+		if r.err != nil {
+			// At EOF whatever is still held is the silent tail
+			return 0, r.err
+		}
+		if r.chunk == nil {
+			r.chunk = make([]byte, 32*1024)
+		}
+		n, err := r.src.Read(r.chunk)
+		r.pending = append(r.pending, r.chunk[:n]...)
+		r.err = err
+
+		// Only frames read since the last call need scanning, the ones before are silent
+		whole := len(r.pending) - len(r.pending)%pcmFrameSize
+		cut := 0
+		if last := lastAudibleFrame(r.pending[r.silent:whole]); last >= 0 {
+			cut = r.silent + (last+1)*pcmFrameSize
+			r.gap = false
+		}
+		if r.gap || whole-cut > r.limit {
+			cut, r.gap = whole, true
+		}
+		r.ready = append(r.ready, r.pending[:cut]...)
+		r.pending = append(r.pending[:0], r.pending[cut:]...)
+		r.silent = whole - cut
+	}
+
+	n := copy(p, r.ready)
+	r.ready = append(r.ready[:0], r.ready[n:]...)
+	return n, nil
+}
+
+// lastAudibleFrame returns the index of the last frame of buf with a sample above the silence threshold, or -1
+func lastAudibleFrame(buf []byte) int {
+	for f := len(buf)/pcmFrameSize - 1; f >= 0; f-- {
+		for ch := 0; ch < PCMChannels; ch++ {
+			v := int16(binary.LittleEndian.Uint16(buf[f*pcmFrameSize+ch*2:]))
+			if v > silenceThreshold || v < -silenceThreshold {
+				return f
+			}
+		}
+	}
+	return -1
+}
+
+// Flush writes out any audio still held back for a crossfade.
+func (m *Mixer) Flush() error {
+	if len(m.tail) == 0 {
+		return nil
+	}
+	_, err := m.out.Write(m.tail)
+	m.tail = nil
+	return err
+}
+
+// playPCM mixes the held tail into the head of src, then streams src to the
+// output while keeping its last `window` bytes back for the next track.
+func (m *Mixer) playPCM(src io.Reader, window int, curve string) error {
+	// 1. Transition: blend the previous tail with the new head
+	if len(m.tail) > 0 {
+		overlap := min(len(m.tail), window)
+		head := make([]byte, overlap)
+		n, err := io.ReadFull(src, head)
+		if err != nil && !errors.Is(err, io.ErrUnexpectedEOF) && !errors.Is(err, io.EOF) {
+			return err
+		}
+		head = head[:n-n%pcmFrameSize]
+		overlap = len(head)
+
+		// Anything from the old tail that does not overlap plays as-is
+		if _, err := m.out.Write(m.tail[:len(m.tail)-overlap]); err != nil {
+			return err
+		}
+		mixed := crossfade(m.tail[len(m.tail)-overlap:], head, curve)
+		m.tail = nil
+		if _, err := m.out.Write(mixed); err != nil {
+			return err
+		}
+	}
+
+	// 2. Body: stream everything except the last `window` bytes
+	buf := make([]byte, 0, window+64*1024)
+	chunk := make([]byte, 32*1024)
+	for {
+		n, err := src.Read(chunk)
+		buf = append(buf, chunk[:n]...)
+
+		if excess := len(buf) - window; excess > 0 {
+			excess -= excess % pcmFrameSize
+			if _, werr := m.out.Write(buf[:excess]); werr != nil {
+				return werr
+			}
+			buf = append(buf[:0], buf[excess:]...)
+		}
+
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return err
+		}
+	}
+
+	// 3. Keep the tail for the next track
+	if len(buf) > 0 {
+		m.tail = append([]byte(nil), buf...)
+	}
+	return nil
+}
+
+// crossfade blends two equally sized s16le buffers, fading `out` down and `in` up.
+func crossfade(out, in []byte, curve string) []byte {
+	mixed := make([]byte, len(in))
+	frames := len(in) / pcmFrameSize
+
+	for f := 0; f < frames; f++ {
+		gOut, gIn := fadeGains(float64(f)/float64(frames), curve)
+		for ch := 0; ch < PCMChannels; ch++ {
+			i := f*pcmFrameSize + ch*2
+			a := float64(int16(binary.LittleEndian.Uint16(out[i:])))
+			b := float64(int16(binary.LittleEndian.Uint16(in[i:])))
+			binary.LittleEndian.PutUint16(mixed[i:], uint16(clampInt16(a*gOut+b*gIn)))
+		}
+	}
+	return mixed
+}
+
+// fadeGains returns the (outgoing, incoming) gains at position t in [0, 1].
+func fadeGains(t float64, curve string) (float64, float64) {
+	switch strings.ToLower(curve) {
+	case CurveLinear:
+		return 1 - t, t
+	case CurveSCurve:
+		in := t * t * (3 - 2*t)
+		return 1 - in, in
+	default:
+		return math.Cos(t * math.Pi / 2), math.Sin(t * math.Pi / 2)
+	}
+}
+
+func crossfadeBytes(d time.Duration) int {
+	if d <= 0 {
+		return 0
+	}
+	frames := int(d.Seconds() * PCMSampleRate)
+	return frames * pcmFrameSize
+}
+
+func clampInt16(v float64) int16 {
+	if v > math.MaxInt16 {
+		return math.MaxInt16
+	}
+	if v < math.MinInt16 {
+		return math.MinInt16
+	}
+	return int16(v)
+}
+
+// SilenceFloorDBFS is the level PeakDBFS reports for digital silence.
+const SilenceFloorDBFS = -96.0
+
+// PeakDBFS returns the peak level of an s16le buffer in dBFS (0 is full scale).
+func PeakDBFS(buf []byte) float64 {
+	peak := 0
+	for i := 0; i+1 < len(buf); i += 2 {
+		v := int(int16(binary.LittleEndian.Uint16(buf[i:])))
+		peak = max(peak, v, -v)
+	}
+	if peak == 0 {
+		return SilenceFloorDBFS
+	}
+	return max(20*math.Log10(float64(peak)/math.MaxInt16), SilenceFloorDBFS)
+}
@@ -0,0 +1,176 @@
+package audio
+
+import (
+	"bytes"
+	"encoding/binary"
+	"io"
+	"math"
+	"testing"
+	"testing/iotest"
+)
+
+// pcm builds an interleaved stereo s16le buffer holding `frames` frames of a constant value.
+func pcm(frames int, value int16) []byte {
+	buf := make([]byte, frames*pcmFrameSize)
+	for i := 0; i < len(buf); i += 2 {
+		binary.LittleEndian.PutUint16(buf[i:], uint16(value))
+	}
+	return buf
+}
+
+func TestFadeGains(t *testing.T) {
+	for _, curve := range []string{CurveLinear, CurveEqualPower, CurveSCurve} {
+		out, in := fadeGains(0, curve)
+		if out != 1 || in != 0 {
+			t.Errorf("%s: at t=0 got (%f, %f), want (1, 0)", curve, out, in)
+		}
+		out, in = fadeGains(1, curve)
+		if math.Abs(out) > 1e-9 || math.Abs(in-1) > 1e-9 {
+			t.Errorf("%s: at t=1 got (%f, %f), want (0, 1)", curve, out, in)
+		}
+	}
+
+	// Equal power keeps the summed energy constant through the fade
+	out, in := fadeGains(0.5, CurveEqualPower)
+	if math.Abs(out*out+in*in-1) > 1e-9 {
+		t.Errorf("equal_power: energy at midpoint = %f, want 1", out*out+in*in)
+	}
+}
+
+func TestMixerCrossfade(t *testing.T) {
+	var out bytes.Buffer
+	m := NewMixer(&out)
+
+	const window = 10 * pcmFrameSize
+
+	// First track: nothing to blend with, the last window is held back
+	if err := m.playPCM(bytes.NewReader(pcm(100, 1000)), window, CurveLinear); err != nil {
+		t.Fatal(err)
+	}
+	if out.Len() != 90*pcmFrameSize {
+		t.Fatalf("after first track wrote %d bytes, want %d", out.Len(), 90*pcmFrameSize)
+	}
+
+	// Second track overlaps the held tail: total length shrinks by one window
+	if err := m.playPCM(bytes.NewReader(pcm(100, 3000)), window, CurveLinear); err != nil {
+		t.Fatal(err)
+	}
+	if err := m.Flush(); err != nil {
+		t.Fatal(err)
+	}
+	if out.Len() != 190*pcmFrameSize {
+		t.Fatalf("total output %d bytes, want %d", out.Len(), 190*pcmFrameSize)
+	}
+
+	// Mid-fade sample sits between the two levels
+	mid := int16(binary.LittleEndian.Uint16(out.Bytes()[95*pcmFrameSize:]))
+	if mid <= 1000 || mid >= 3000 {
+		t.Errorf("mid-fade sample = %d, want between 1000 and 3000", mid)
+	}
+}
+
+func TestMixerNoCrossfade(t *testing.T) {
+	var out bytes.Buffer
+	m := NewMixer(&out)
+
+	for i := 0; i < 2; i++ {
+		if err := m.playPCM(bytes.NewReader(pcm(50, 1000)), 0, CurveEqualPower); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if out.Len() != 100*pcmFrameSize {
+		t.Fatalf("gapless output %d bytes, want %d", out.Len(), 100*pcmFrameSize)
+	}
+}
+
+func TestDuckMix(t *testing.T) {
+	const ramp = 10
+	music := pcm(100, 1000)
+	voice := pcm(50, 500)
+
+	duckMix(music, voice, 0, 0.5, ramp)
+
+	sample := func(frame int) int16 {
+		return int16(binary.LittleEndian.Uint16(music[frame*pcmFrameSize:]))
+	}
+
+	// Fully ducked under the voice: 1000*0.5 + 500
+	if got := sample(30); got != 1000 {
+		t.Errorf("under voice = %d, want 1000", got)
+	}
+	// Ramping back up once the voice has ended
+	if got := sample(55); got <= 500 || got >= 1000 {
+		t.Errorf("recovery ramp = %d, want between 500 and 1000", got)
+	}
+	// Untouched after the ramp
+	if got := sample(80); got != 1000 {
+		t.Errorf("after ramp = %d, want 1000", got)
+	}
+}
+
+func TestDuckReaderSplitReads(t *testing.T) {
+	voice := pcm(20, 100)
+	r := &duckReader{src: bytes.NewReader(pcm(200, 1000)), voice: voice, gain: 0.5, ramp: 5}
+
+	// Odd-sized reads must not misalign the envelope
+	var out []byte
+	buf := make([]byte, 7)
+	for {
+		n, err := r.Read(buf)
+		out = append(out, buf[:n]...)
+		if err != nil {
+			break
+		}
+	}
+
+	if len(out) != 200*pcmFrameSize {
+		t.Fatalf("read %d bytes, want %d", len(out), 200*pcmFrameSize)
+	}
+	if got := int16(binary.LittleEndian.Uint16(out[10*pcmFrameSize:])); got != 600 {
+		t.Errorf("ducked sample = %d, want 600", got)
+	}
+	if got := int16(binary.LittleEndian.Uint16(out[150*pcmFrameSize:])); got != 1000 {
+		t.Errorf("sample after talk-over = %d, want 1000", got)
+	}
+}
+
+func TestSilentTailReader(t *testing.T) {
+	// Music, a pause, music again, then a silent tail
+	var track []byte
+	for _, part := range [][]byte{pcm(100, 1000), pcm(50, 0), pcm(100, -1000), pcm(80, 20)} {
+		track = append(track, part...)
+	}
+	r := &silentTailReader{src: iotest.OneByteReader(bytes.NewReader(track)), limit: 1000 * pcmFrameSize}
+
+	out, err := io.ReadAll(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := track[:250*pcmFrameSize]; !bytes.Equal(out, want) {
+		t.Errorf("read %d frames, want the %d before the tail with the pause kept", len(out)/pcmFrameSize, len(want)/pcmFrameSize)
+	}
+
+	// A silence longer than the limit is a gap rather than a tail and airs in full, however it is read
+	for _, src := range []io.Reader{
+		bytes.NewReader(append(pcm(10, 1000), pcm(5000, 0)...)),
+		iotest.HalfReader(bytes.NewReader(append(pcm(10, 1000), pcm(5000, 0)...))),
+	} {
+		r = &silentTailReader{src: src, limit: 1000 * pcmFrameSize}
+		if out, _ := io.ReadAll(r); len(out) != 5010*pcmFrameSize {
+			t.Errorf("read %d frames of a long silence, want 5010", len(out)/pcmFrameSize)
+		}
+	}
+}
+
+func TestPeakDBFS(t *testing.T) {
+	if got := PeakDBFS(pcm(100, 0)); got != SilenceFloorDBFS {
+		t.Errorf("silence: got %.1f dBFS", got)
+	}
+	if got := PeakDBFS(pcm(100, math.MaxInt16)); math.Abs(got) > 0.01 {
+		t.Errorf("full scale: got %.2f dBFS, want 0", got)
+	}
+	// Half scale is about -6dB, negative samples count too
+	if got := PeakDBFS(pcm(100, -16384)); math.Abs(got+6.02) > 0.05 {
+		t.Errorf("half scale: got %.2f dBFS, want -6.02", got)
+	}
+}
@@ -0,0 +1,228 @@
+package audio
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+
+	"momo-radio/internal/models"
+)
+
+// Processing is a fully resolved processing chain: a preset with the mount's overrides applied
+type Processing struct {
+	Compression   string  `json:"compression"`
+	Limiter       bool    `json:"limiter"`
+	LimiterDB     float64 `json:"limiter_db"`
+	BassDB        float64 `json:"bass_db"`
+	TrebleDB      float64 `json:"treble_db"`
+	StereoWidth   float64 `json:"stereo_width"`
+	MonoBelowKbps int     `json:"mono_below_kbps"`
+}
+
+// ProcessingPreset is a named station sound offered to the settings UI
+type ProcessingPreset struct {
+	Name        string     `json:"name"`
+	Description string     `json:"description"`
+	Processing  Processing `json:"processing"`
+}
+
+const (
+	ProcessingNone = "none"
+
+	minLimiterDB, maxLimiterDB = -6.0, 0.0
+	maxShelfDB                 = 12.0
+	maxStereoWidth             = 2.0
+	maxMonoBelowKbps           = 320
+)
+
+var processingPresets = map[string]ProcessingPreset{
+	ProcessingNone: {
+		Description: "The playout as mixed, no processing",
+		Processing:  Processing{Compression: "off", StereoWidth: 1},
+	},
+	"broadcast": {
+		Description: "Balanced multiband compression and a peak limiter, mono on very low bitrates",
+		Processing:  Processing{Compression: "medium", Limiter: true, LimiterDB: -1, StereoWidth: 1, MonoBelowKbps: 48},
+	},
+	"loud": {
+		Description: "Dense, punchy and wide, for dance and pop stations",
+		Processing:  Processing{Compression: "heavy", Limiter: true, LimiterDB: -1, BassDB: 2, TrebleDB: 1.5, StereoWidth: 1.2, MonoBelowKbps: 48},
+	},
+	"speech": {
+		Description: "Even, intelligible voice for talk and news",
+		Processing:  Processing{Compression: "medium", Limiter: true, LimiterDB: -1, BassDB: -3, TrebleDB: 2, StereoWidth: 1, MonoBelowKbps: 64},
+	},
+	"warm": {
+		Description: "Gentle compression with a fuller low end, for jazz and acoustic music",
+		Processing:  Processing{Compression: "light", Limiter: true, LimiterDB: -1, BassDB: 3, TrebleDB: -1.5, StereoWidth: 1},
+	},
+}
+
+// compressionBand is one band of the multiband compressor
+type compressionBand struct {
+	attack, decay float64 // Seconds
+	crossover     int     // Upper edge of the band, Hz
+}
+
+var compressionBands = []compressionBand{
+	{attack: 0.02, decay: 0.25, crossover: 200},
+	{attack: 0.01, decay: 0.15, crossover: 1200},
+	{attack: 0.005, decay: 0.1, crossover: 6000},
+	{attack: 0.002, decay: 0.05, crossover: 22000},
+}
+
+// compressionStrength is the transfer curve shared by every band
+type compressionStrength struct {
+	threshold, ratio, makeup float64 // dB, n:1, dB
+}
+
+var compressionStrengths = map[string]compressionStrength{
+	"light":  {threshold: -24, ratio: 1.5, makeup: 2},
+	"medium": {threshold: -30, ratio: 2.5, makeup: 4},
+	"heavy":  {threshold: -36, ratio: 4, makeup: 6},
+}
+
+// ProcessingPresets lists the presets in a stable order for the settings UI
+func ProcessingPresets() []ProcessingPreset {
+	var presets []ProcessingPreset
+	for name, p := range processingPresets {
+		p.Name = name
+		presets = append(presets, p)
+	}
+	sort.Slice(presets, func(i, j int) bool { return presets[i].Name < presets[j].Name })
+	return presets
+}
+
+// ValidateProcessing normalizes the chain and rejects anything outside the safe list
+func ValidateProcessing(p *models.ProcessingChain) error {
+	p.Preset = strings.ToLower(strings.TrimSpace(p.Preset))
+	if p.Preset == "" {
+		p.Preset = ProcessingNone
+	}
+	if _, ok := processingPresets[p.Preset]; !ok {
+		return fmt.Errorf("preset must be one of %s", strings.Join(presetNames(), ", "))
+	}
+
+	p.Compression = strings.ToLower(strings.TrimSpace(p.Compression))
+	if _, ok := compressionStrengths[p.Compression]; !ok && p.Compression != "" && p.Compression != "off" {
+		return fmt.Errorf("compression must be one of off, light, medium, heavy")
+	}
+
+	if p.LimiterDB != nil && (*p.LimiterDB < minLimiterDB || *p.LimiterDB > maxLimiterDB) {
+		return fmt.Errorf("limiter_db must be between %.0f and %.0f", minLimiterDB, maxLimiterDB)
+	}
+	for _, shelf := range []*float64{p.BassDB, p.TrebleDB} {
+		if shelf != nil && math.Abs(*shelf) > maxShelfDB {
+			return fmt.Errorf("bass_db and treble_db must be between -%.0f and %.0f", maxShelfDB, maxShelfDB)
+		}
+	}
+	if p.StereoWidth != nil && (*p.StereoWidth < 0 || *p.StereoWidth > maxStereoWidth) {
+		return fmt.Errorf("stereo_width must be between 0 and %.0f", maxStereoWidth)
+	}
+	if p.MonoBelowKbps != nil && (*p.MonoBelowKbps < 0 || *p.MonoBelowKbps > maxMonoBelowKbps) {
+		return fmt.Errorf("mono_below_kbps must be between 0 and %d", maxMonoBelowKbps)
+	}
+	return nil
+}
+
+func presetNames() []string {
+	var names []string
+	for _, p := range ProcessingPresets() {
+		names = append(names, p.Name)
+	}
+	return names
+}
+
+// ResolveProcessing applies the chain's overrides to its preset; nil or unknown presets resolve to none
+func ResolveProcessing(p *models.ProcessingChain) Processing {
+	if p == nil {
+		return processingPresets[ProcessingNone].Processing
+	}
+	preset, ok := processingPresets[strings.ToLower(p.Preset)]
+	if !ok {
+		preset = processingPresets[ProcessingNone]
+	}
+
+	r := preset.Processing
+	if p.Compression != "" {
+		r.Compression = strings.ToLower(p.Compression)
+	}
+	if p.LimiterDB != nil {
+		r.Limiter, r.LimiterDB = true, *p.LimiterDB
+	}
+	if p.BassDB != nil {
+		r.BassDB = *p.BassDB
+	}
+	if p.TrebleDB != nil {
+		r.TrebleDB = *p.TrebleDB
+	}
+	if p.StereoWidth != nil {
+		r.StereoWidth = *p.StereoWidth
+	}
+	if p.MonoBelowKbps != nil {
+		r.MonoBelowKbps = *p.MonoBelowKbps
+	}
+	return r
+}
+
+// ProcessingFilter builds the ffmpeg filter graph of a mount: loudness trim, EQ, multiband compression,
+// stereo image and peak limiter, in that order. bitrate is the mount's (kbps); "" when there is nothing to do.
+func ProcessingFilter(p *models.ProcessingChain, bitrate int, trimDB float64) string {
+	r := ResolveProcessing(p)
+	var filters []string
+
+	// 1. Loudness trim toward the mount's target
+	if math.Abs(trimDB) >= 0.05 {
+		filters = append(filters, fmt.Sprintf("volume=%.1fdB", trimDB))
+	}
+
+	// 2. EQ
+	if math.Abs(r.BassDB) >= 0.05 {
+		filters = append(filters, fmt.Sprintf("bass=g=%.1f:f=100", r.BassDB))
+	}
+	if math.Abs(r.TrebleDB) >= 0.05 {
+		filters = append(filters, fmt.Sprintf("treble=g=%.1f:f=8000", r.TrebleDB))
+	}
+
+	// 3. Multiband compression
+	if s, ok := compressionStrengths[r.Compression]; ok {
+		filters = append(filters, mcompandFilter(s))
+	}
+
+	// 4. Stereo image: low bitrates fold to mono, otherwise widen or narrow
+	if r.MonoBelowKbps > 0 && bitrate > 0 && bitrate < r.MonoBelowKbps {
+		filters = append(filters, "pan=mono|c0=0.5*c0+0.5*c1")
+	} else if math.Abs(r.StereoWidth-1) >= 0.01 {
+		filters = append(filters, fmt.Sprintf("extrastereo=m=%.2f", r.StereoWidth))
+	}
+
+	// 5. Peak limiter; a boosted trim is limited even when the chain has none
+	switch {
+	case r.Limiter:
+		filters = append(filters, limiterFilter(r.LimiterDB))
+	case trimDB >= 0.05 || r.BassDB > 0 || r.TrebleDB > 0:
+		filters = append(filters, limiterFilter(truePeakCeiling))
+	}
+
+	return strings.Join(filters, ",")
+}
+
+// mcompandFilter spreads one transfer curve over the compressor bands. The bands are quoted
+// because mcompand separates attack and decay with commas, which also separate filters.
+func mcompandFilter(s compressionStrength) string {
+	// Unity below the threshold, ratio:1 above it, everything lifted by the makeup gain
+	points := fmt.Sprintf("-90/%.1f,%.1f/%.1f,0/%.1f",
+		-90+s.makeup, s.threshold, s.threshold+s.makeup, s.threshold-s.threshold/s.ratio+s.makeup)
+
+	bands := make([]string, len(compressionBands))
+	for i, b := range compressionBands {
+		bands[i] = fmt.Sprintf("%g,%g 6 %s %d", b.attack, b.decay, points, b.crossover)
+	}
+	return "mcompand='" + strings.Join(bands, " | ") + "'"
+}
+
+// limiterFilter holds peaks under ceilingDB without alimiter's auto-level, which would lift them back to full scale
+func limiterFilter(ceilingDB float64) string {
+	return fmt.Sprintf("alimiter=limit=%.3f:level=false", math.Pow(10, ceilingDB/20))
+}
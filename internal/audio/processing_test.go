@@ -0,0 +1,60 @@
+package audio
+
+import (
+	"strings"
+	"testing"
+
+	"momo-radio/internal/models"
+)
+
+func TestProcessingFilterTrimOnly(t *testing.T) {
+	if f := ProcessingFilter(nil, 128, 0); f != "" {
+		t.Errorf("no processing expected, got %q", f)
+	}
+	if f := ProcessingFilter(nil, 128, -3); f != "volume=-3.0dB" {
+		t.Errorf("unexpected cut filter %q", f)
+	}
+	if f := ProcessingFilter(nil, 128, 2); f != "volume=2.0dB,alimiter=limit=0.891:level=false" {
+		t.Errorf("a boost must be limited, got %q", f)
+	}
+}
+
+func TestProcessingFilterPreset(t *testing.T) {
+	chain := &models.ProcessingChain{Preset: "broadcast"}
+
+	f := ProcessingFilter(chain, 128, 0)
+	if !strings.HasPrefix(f, "mcompand='") || !strings.HasSuffix(f, ",alimiter=limit=0.891:level=false") {
+		t.Errorf("unexpected broadcast chain %q", f)
+	}
+	if strings.Contains(f, "pan=") {
+		t.Errorf("128 kbps must stay stereo, got %q", f)
+	}
+	if f := ProcessingFilter(chain, 32, 0); !strings.Contains(f, "pan=mono") {
+		t.Errorf("32 kbps must fold to mono, got %q", f)
+	}
+
+	// Overrides win over the preset
+	width, off := 1.5, 0
+	chain = &models.ProcessingChain{Preset: "broadcast", Compression: "off", StereoWidth: &width, MonoBelowKbps: &off}
+	if f := ProcessingFilter(chain, 32, 0); f != "extrastereo=m=1.50,alimiter=limit=0.891:level=false" {
+		t.Errorf("overrides not applied, got %q", f)
+	}
+}
+
+func TestValidateProcessing(t *testing.T) {
+	if err := ValidateProcessing(&models.ProcessingChain{Preset: " Loud ", Compression: "LIGHT"}); err != nil {
+		t.Errorf("valid chain rejected: %v", err)
+	}
+
+	loud, wide := 3.0, 5.0
+	for _, bad := range []models.ProcessingChain{
+		{Preset: "volume=20dB"},
+		{Preset: "broadcast", Compression: "insane"},
+		{Preset: "broadcast", LimiterDB: &loud},
+		{Preset: "broadcast", StereoWidth: &wide},
+	} {
+		if ValidateProcessing(&bad) == nil {
+			t.Errorf("invalid chain accepted: %+v", bad)
+		}
+	}
+}
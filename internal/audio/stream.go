@@ -1,17 +1,26 @@
 package audio
 
 import (
+	"context"
 	"fmt"
 	"io"
 	"log"
 	"os/exec"
 	"path/filepath"
 	"strconv"
+	"time"
 
 	"momo-radio/internal/config"
 )
 
-func StartStreamProcess(input io.Reader, cfg *config.Config, runID int64, startSequence int64, segmentDir string, targetBitrate int) {
+// EncoderOptions are the per-rendition encoder settings on top of the server config
+type EncoderOptions struct {
+	Filter     string // ffmpeg audio filter graph for this rendition only (see ProcessingFilter)
+	SampleRate string // Output sample rate, "" keeps the configured one
+}
+
+// StartStreamProcess encodes the PCM playout into HLS segments until ctx ends or ffmpeg exits.
+func StartStreamProcess(ctx context.Context, input io.Reader, cfg *config.Config, runID int64, startSequence int64, segmentDir string, targetBitrate int, opts EncoderOptions) error {
 
 	startNum := strconv.FormatInt(startSequence, 10)
 
@@ -29,16 +38,27 @@ func StartStreamProcess(input io.Reader, cfg *config.Config, runID int64, startS
 	}
 
 	// Pull remaining FFmpeg parameters dynamically from your Viper Config
-	codec := fallbackStr(cfg.Radio.AudioCodec, "libmp3lame")
-	sampleRate := fallbackStr(cfg.Radio.SampleRate, "44100")
+	codec := StreamCodec(cfg)
+	sampleRate := fallbackStr(opts.SampleRate, fallbackStr(cfg.Radio.SampleRate, "44100"))
 
 	hlsTime := fallbackInt(cfg.Radio.SegmentTime, 10)
 	hlsListSize := fallbackInt(cfg.Radio.ListSize, 6)
 
 	args := []string{
 		"-re",
+
+		// Input is raw PCM produced by the Mixer
+		"-f", "s16le",
+		"-ar", strconv.Itoa(PCMSampleRate),
+		"-ac", strconv.Itoa(PCMChannels),
 		"-i", "pipe:0",
+	}
 
+	if opts.Filter != "" {
+		args = append(args, "-af", opts.Filter)
+	}
+
+	args = append(args,
 		// Audio Configuration
 		"-c:a", codec,
 		"-b:a", bitrate,
@@ -55,21 +75,25 @@ func StartStreamProcess(input io.Reader, cfg *config.Config, runID int64, startS
 		"-hls_segment_filename", segmentPattern,
 		"-start_number", startNum,
 		playlistPath,
-	}
+	)
 
-	cmd := exec.Command("ffmpeg", args...)
+	cmd := exec.CommandContext(ctx, "ffmpeg", args...)
 	cmd.Stdin = input
+	// Killed or crashed while the playout is silent: do not wait on the stdin copy forever
+	cmd.WaitDelay = 5 * time.Second
 
 	if err := cmd.Start(); err != nil {
 		log.Printf("FFmpeg failed to start: %v", err)
-		return
+		return err
 	}
 
 	log.Printf("FFmpeg started (RunID: %d | Seq: %s | Dir: %s | Bitrate: %s)", runID, startNum, segmentDir, bitrate)
 
-	if err := cmd.Wait(); err != nil {
+	err := cmd.Wait()
+	if err != nil {
 		log.Printf("FFmpeg exited: %v", err)
 	}
+	return err
 }
 
 // --- Helper functions for safe config reading ---
@@ -87,3 +111,45 @@ func fallbackInt(val, fallback int) int {
 	}
 	return val
 }
+
+// StartDirectStreamProcess encodes the PCM playout to a continuous MP3 byte stream
+// for Icecast-style listeners. It blocks until the input closes or ffmpeg exits.
+func StartDirectStreamProcess(input io.Reader, output io.Writer, targetBitrate int, opts EncoderOptions) error {
+	bitrate := "128k"
+	if targetBitrate > 0 {
+		bitrate = fmt.Sprintf("%dk", targetBitrate)
+	}
+
+	args := []string{
+		"-hide_banner", "-loglevel", "error",
+
+		"-f", "s16le",
+		"-ar", strconv.Itoa(PCMSampleRate),
+		"-ac", strconv.Itoa(PCMChannels),
+		"-i", "pipe:0",
+	}
+
+	if opts.Filter != "" {
+		args = append(args, "-af", opts.Filter)
+	}
+	if opts.SampleRate != "" {
+		args = append(args, "-ar", opts.SampleRate)
+	}
+
+	args = append(args,
+		// ICY clients only understand plain MP3 frames
+		"-c:a", "libmp3lame",
+		"-b:a", bitrate,
+		"-write_xing", "0",
+		"-id3v2_version", "0",
+		"-f", "mp3",
+		"pipe:1",
+	)
+
+	cmd := exec.Command("ffmpeg", args...)
+	cmd.Stdin = input
+	cmd.Stdout = output
+
+	log.Printf("Direct MP3 encoder started (Bitrate: %s)", bitrate)
+	return cmd.Run()
+}
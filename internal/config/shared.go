@@ -51,6 +51,24 @@ type Config struct {
 		PrefetchCount int    `mapstructure:"prefetch_count"`
 		DryRun        bool   `mapstructure:"dry_run"`
 		Provider      string `mapstructure:"provider"`
+		LiveIngestURL string `mapstructure:"live_ingest_url"`
+		// IcecastEnabled serves every mount as a continuous ICY/MP3 stream next to HLS
+		IcecastEnabled bool `mapstructure:"icecast_enabled"`
+		// ListenerTracking is "redirect" (count /listen hits and beacons) or "proxy" (relay playlists through the engine)
+		ListenerTracking string `mapstructure:"listener_tracking"`
+		ListenerTimeout  int    `mapstructure:"listener_timeout_seconds"`
+		GeoIPDBPath      string `mapstructure:"geoip_db_path"`
+		// ListenerSecret keys the daily listener hash; share it between engines so unique listeners add up
+		ListenerSecret string `mapstructure:"listener_secret"`
+		// NodeID names this engine in the cluster (default: hostname-runID). Tenants are leased to one node at a time.
+		NodeID          string `mapstructure:"node_id"`
+		LeaseTTLSeconds int    `mapstructure:"lease_ttl_seconds"`
+		// StallSegments restarts a mount's encoder after this many segment durations without a new segment
+		StallSegments int `mapstructure:"stall_segments"`
+		// Outgoing audio below SilenceThresholdDB (peak dBFS) for DeadAirSeconds skips the item on air
+		// and plays the station's fallback loop
+		DeadAirSeconds     int     `mapstructure:"dead_air_seconds"`
+		SilenceThresholdDB float64 `mapstructure:"silence_threshold_db"`
 	} `mapstructure:"radio"`
 	Database struct {
 		Host     string `mapstructure:"host"`
@@ -139,6 +157,17 @@ func Load() *Config {
 	viper.BindEnv("radio.hls_flags")
 	viper.BindEnv("radio.prefetch_count")
 	viper.BindEnv("radio.provider")
+	viper.BindEnv("radio.live_ingest_url")
+	viper.BindEnv("radio.icecast_enabled")
+	viper.BindEnv("radio.listener_tracking")
+	viper.BindEnv("radio.listener_timeout_seconds")
+	viper.BindEnv("radio.listener_secret")
+	viper.BindEnv("radio.geoip_db_path")
+	viper.BindEnv("radio.node_id")
+	viper.BindEnv("radio.lease_ttl_seconds")
+	viper.BindEnv("radio.stall_segments")
+	viper.BindEnv("radio.dead_air_seconds")
+	viper.BindEnv("radio.silence_threshold_db")
 
 	// Infrastructure Bindings
 	viper.BindEnv("database.host")
@@ -199,6 +228,16 @@ func Load() *Config {
 	viper.SetDefault("radio.prefetch_count", 5)
 	viper.SetDefault("radio.provider", "starvation")
 	viper.SetDefault("radio.dry_run", false)
+	viper.SetDefault("radio.live_ingest_url", "rtmp://localhost:1935/live/{stream_key}")
+	viper.SetDefault("radio.icecast_enabled", true)
+	viper.SetDefault("radio.listener_tracking", "redirect")
+	viper.SetDefault("radio.listener_timeout_seconds", 90)
+	viper.SetDefault("radio.geoip_db_path", "")
+	viper.SetDefault("radio.node_id", "")
+	viper.SetDefault("radio.lease_ttl_seconds", 15)
+	viper.SetDefault("radio.stall_segments", 3)
+	viper.SetDefault("radio.dead_air_seconds", 10)
+	viper.SetDefault("radio.silence_threshold_db", -50)
 
 	viper.SetDefault("worker.concurrency", 6)
 	viper.SetDefault("worker.queues", map[string]int{
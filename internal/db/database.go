@@ -44,6 +44,11 @@ func New(cfg *config.Config) *Client {
 	return &Client{DB: db}
 }
 
+// DryRun returns a Postgres handle that builds SQL without a server (Statement.SQL), for tests
+func DryRun() (*gorm.DB, error) {
+	return gorm.Open(postgres.New(postgres.Config{DSN: "host=localhost"}), &gorm.Config{DryRun: true, DisableAutomaticPing: true})
+}
+
 // AutoMigrate creates/updates tables based on struct definitions
 func (c *Client) AutoMigrate() {
 	log.Println("Running Database Migrations...")
@@ -53,14 +58,22 @@ func (c *Client) AutoMigrate() {
 		&models.Organization{},
 		&models.MountPoint{},
 		&models.PlayHistory{},
+		&models.ListenerSession{},
+		&models.ListenerSample{},
 		&models.Playlist{},
 		&models.PlaylistTrack{},
 		&models.Schedule{},
 		&models.ScheduleSlot{},
+		&models.PlaylistCursor{},
+		&models.RuleSet{},
 		&models.StreamState{},
+		&models.PipelineIncident{},
+		&models.QueueItem{},
+		&models.InsertionRule{},
 		&models.Album{},
 		&models.Artist{},
 		&models.Track{},
+		&models.TrackDuplicate{},
 		&models.PublicPage{},
 		&models.OrganizationSettings{},
 		&models.UserProfile{},
@@ -68,5 +81,15 @@ func (c *Client) AutoMigrate() {
 	if err != nil {
 		log.Fatalf("Migration failed: %v", err)
 	}
+
+	// ⚡️ Keyset pagination of the library walks this index instead of sorting 100k rows
+	c.DB.Exec("CREATE INDEX IF NOT EXISTS idx_tracks_library ON tracks (organization_id, kind, id DESC)")
+
+	// Tracks from before full-text search (or added while it was missing) get their search document
+	if res := c.DB.Exec("UPDATE tracks SET search_vector = " + trackSearchDocument + " WHERE search_vector IS NULL"); res.Error != nil {
+		log.Printf("Search backfill failed: %v", res.Error)
+	} else if res.RowsAffected > 0 {
+		log.Printf("Search backfill: indexed %d tracks", res.RowsAffected)
+	}
 	log.Println("Migrations Complete")
 }
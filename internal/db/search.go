@@ -0,0 +1,28 @@
+package database
+
+import (
+	"gorm.io/gorm"
+)
+
+// trackSearchDocument builds a track's tsvector: title and artists weigh most, then album, then label.
+// The 'simple' configuration does no stemming, which suits names in any language.
+const trackSearchDocument = "setweight(to_tsvector('simple', COALESCE(tracks.title, '')), 'A') || " +
+	"setweight(to_tsvector('simple', COALESCE((SELECT string_agg(artists.name, ' ') FROM track_artists " +
+	"JOIN artists ON artists.id = track_artists.artist_id WHERE track_artists.track_id = tracks.id), '')), 'A') || " +
+	"setweight(to_tsvector('simple', COALESCE((SELECT albums.title FROM albums WHERE albums.id = tracks.album_id), '')), 'B') || " +
+	"setweight(to_tsvector('simple', COALESCE((SELECT albums.publisher FROM albums WHERE albums.id = tracks.album_id), '')), 'C')"
+
+// RefreshTrackSearch rebuilds the search document of the given tracks; call it after their
+// title, artists or album change
+func RefreshTrackSearch(db *gorm.DB, trackIDs ...uint) error {
+	if len(trackIDs) == 0 {
+		return nil
+	}
+	return db.Exec("UPDATE tracks SET search_vector = "+trackSearchDocument+" WHERE tracks.id IN ?", trackIDs).Error
+}
+
+// RefreshAlbumSearch rebuilds the search document of every track of an album; call it after the
+// album's title or label change
+func RefreshAlbumSearch(db *gorm.DB, albumID uint) error {
+	return db.Exec("UPDATE tracks SET search_vector = "+trackSearchDocument+" WHERE tracks.album_id = ?", albumID).Error
+}
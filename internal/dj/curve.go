@@ -0,0 +1,186 @@
+package dj
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"math/rand"
+	"sort"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+
+	"momo-radio/internal/audio"
+	"momo-radio/internal/models"
+)
+
+const (
+	// curvePoolSize is how many candidates are scored against the curve per pick
+	curvePoolSize = 100
+	// curveTopN keeps some variety among the closest matches
+	curveTopN = 3
+	// curveMixWeight scales the harmonic score against the distance to the curve
+	curveMixWeight = 0.5
+)
+
+// ProgressSelector is a Selector that also knows where in the slot the track will air
+type ProgressSelector interface {
+	Selector
+	// PickTrackAt picks for progress (0 = slot start, 1 = slot end) into the slot
+	PickTrackAt(rules *models.RuleSet, lastTrack *models.Track, progress float64) (*models.Track, error)
+}
+
+// CurveSelector follows the RuleSet's energy/BPM curve across the slot while keeping transitions harmonic
+type CurveSelector struct {
+	db    *gorm.DB
+	orgID uuid.UUID
+}
+
+func (s *CurveSelector) Name() string { return "Curve" }
+
+// PickTrack without a position in the slot cannot follow the curve, it mixes harmonically instead
+func (s *CurveSelector) PickTrack(rules *models.RuleSet, lastTrack *models.Track) (*models.Track, error) {
+	return (&HarmonicSelector{db: s.db, orgID: s.orgID}).PickTrack(rules, lastTrack)
+}
+
+func (s *CurveSelector) PickTrackAt(rules *models.RuleSet, lastTrack *models.Track, progress float64) (*models.Track, error) {
+	if rules == nil || len(rules.Curve) == 0 {
+		return s.PickTrack(rules, lastTrack)
+	}
+
+	candidates, err := findSeparated(s.db, rules, s.orgID, func(q *gorm.DB) *gorm.DB {
+		if lastTrack != nil && lastTrack.ID != 0 {
+			q = q.Where("tracks.id <> ?", lastTrack.ID)
+		}
+		return q.Order("RANDOM()").Limit(curvePoolSize)
+	})
+	if err != nil || len(candidates) == 0 {
+		return nil, errors.New("curve: no tracks found")
+	}
+
+	// Energy targets are relative to the tenant's own library, analysers do not agree on a scale
+	var bounds struct{ Low, High float64 }
+	s.db.Model(&models.Track{}).
+		Select("COALESCE(MIN(energy), 0) AS low, COALESCE(MAX(energy), 0) AS high").
+		Where("organization_id = ? AND kind = ? AND energy > 0", s.orgID, models.TrackKindMusic).
+		Scan(&bounds)
+
+	target := EvaluateCurve(rules.Curve, progress)
+	best := rankByCurve(lastTrack, candidates, target, bounds.Low, bounds.High, curveTopN)
+	return &best[rand.Intn(len(best))], nil
+}
+
+// CurveTarget is the curve's value at one point of the slot; nil fields are not targeted
+type CurveTarget struct {
+	Energy *float64
+	BPM    *float64
+}
+
+// EvaluateCurve interpolates energy and BPM at progress, each from the points that set it.
+// Before the first or after the last point the nearest value holds.
+func EvaluateCurve(points []models.CurvePoint, progress float64) CurveTarget {
+	sorted := append([]models.CurvePoint(nil), points...)
+	sort.SliceStable(sorted, func(i, j int) bool { return sorted[i].At < sorted[j].At })
+
+	interpolate := func(value func(models.CurvePoint) *float64) *float64 {
+		var prev *models.CurvePoint
+		for i := range sorted {
+			p := &sorted[i]
+			v := value(*p)
+			if v == nil {
+				continue
+			}
+			if progress <= p.At {
+				if prev == nil || p.At == prev.At {
+					return v
+				}
+				ratio := (progress - prev.At) / (p.At - prev.At)
+				out := *value(*prev) + (*v-*value(*prev))*ratio
+				return &out
+			}
+			prev = p
+		}
+		if prev != nil {
+			return value(*prev)
+		}
+		return nil
+	}
+
+	return CurveTarget{
+		Energy: interpolate(func(p models.CurvePoint) *float64 { return p.Energy }),
+		BPM:    interpolate(func(p models.CurvePoint) *float64 { return p.BPM }),
+	}
+}
+
+// curveDistance is how far a track is from the target; lower is closer. Unanalysed tracks are pushed back.
+func curveDistance(t models.Track, target CurveTarget, lowEnergy, highEnergy float64) float64 {
+	distance := 0.0
+
+	if target.Energy != nil {
+		if t.Energy <= 0 || highEnergy <= lowEnergy {
+			distance += 100
+		} else {
+			relative := (t.Energy - lowEnergy) / (highEnergy - lowEnergy)
+			distance += math.Abs(relative-*target.Energy) * 100
+		}
+	}
+
+	if target.BPM != nil && *target.BPM > 0 {
+		if t.BPM <= 0 {
+			distance += 100
+		} else {
+			// 10% off the target costs as much as a quarter of the energy range
+			distance += math.Abs(t.BPM-*target.BPM) / *target.BPM * 250
+		}
+	}
+	return distance
+}
+
+// rankByCurve sorts candidates by distance to the curve plus, after an analysed track, the harmonic
+// mix score, and returns the best n
+func rankByCurve(prev *models.Track, candidates []models.Track, target CurveTarget, lowEnergy, highEnergy float64, n int) []models.Track {
+	type scored struct {
+		track models.Track
+		score float64
+	}
+
+	ranked := make([]scored, 0, len(candidates))
+	for _, c := range candidates {
+		score := curveDistance(c, target, lowEnergy, highEnergy)
+		if prev != nil && prev.BPM > 0 && c.BPM > 0 {
+			score += audio.CalculateMixScore(*prev, c) * curveMixWeight
+		}
+		ranked = append(ranked, scored{track: c, score: score})
+	}
+
+	sort.SliceStable(ranked, func(i, j int) bool { return ranked[i].score < ranked[j].score })
+
+	if n <= 0 || n > len(ranked) {
+		n = len(ranked)
+	}
+
+	result := make([]models.Track, n)
+	for i := 0; i < n; i++ {
+		result[i] = ranked[i].track
+	}
+	return result
+}
+
+// ValidateCurve checks each point is inside the slot and within sensible ranges
+func ValidateCurve(points []models.CurvePoint) error {
+	for i, p := range points {
+		if p.At < 0 || p.At > 1 {
+			return fmt.Errorf("curve point %d: at must be between 0 and 1", i)
+		}
+		if p.Energy == nil && p.BPM == nil {
+			return fmt.Errorf("curve point %d: set energy and/or bpm", i)
+		}
+		if p.Energy != nil && (*p.Energy < 0 || *p.Energy > 1) {
+			return fmt.Errorf("curve point %d: energy must be between 0 and 1", i)
+		}
+		if p.BPM != nil && (*p.BPM < 40 || *p.BPM > 250) {
+			return fmt.Errorf("curve point %d: bpm must be between 40 and 250", i)
+		}
+	}
+	return nil
+}
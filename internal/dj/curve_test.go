@@ -0,0 +1,73 @@
+package dj
+
+import (
+	"math"
+	"testing"
+
+	"momo-radio/internal/models"
+)
+
+func ptr(v float64) *float64 { return &v }
+
+func TestEvaluateCurve(t *testing.T) {
+	// Warm-up, peak, cool-down; BPM only set at the ends
+	curve := []models.CurvePoint{
+		{At: 1, Energy: ptr(0.3), BPM: ptr(118)},
+		{At: 0, Energy: ptr(0.2), BPM: ptr(110)},
+		{At: 0.7, Energy: ptr(0.9)},
+	}
+
+	cases := []struct {
+		progress    float64
+		energy, bpm float64
+	}{
+		{0, 0.2, 110},
+		{0.35, 0.55, 112.8},
+		{0.7, 0.9, 115.6},
+		{0.85, 0.6, 116.8},
+		{1, 0.3, 118},
+		{1.2, 0.3, 118}, // Past the end the last value holds
+	}
+	for _, c := range cases {
+		got := EvaluateCurve(curve, c.progress)
+		if got.Energy == nil || math.Abs(*got.Energy-c.energy) > 1e-9 {
+			t.Errorf("energy at %.2f = %v, want %.2f", c.progress, got.Energy, c.energy)
+		}
+		if got.BPM == nil || math.Abs(*got.BPM-c.bpm) > 1e-9 {
+			t.Errorf("bpm at %.2f = %v, want %.1f", c.progress, got.BPM, c.bpm)
+		}
+	}
+
+	if got := EvaluateCurve([]models.CurvePoint{{At: 0.5, Energy: ptr(0.4)}}, 0.1); got.BPM != nil || *got.Energy != 0.4 {
+		t.Errorf("single point should hold everywhere and leave BPM untargeted, got %+v", got)
+	}
+}
+
+func TestRankByCurve(t *testing.T) {
+	prev := &models.Track{BPM: 124, MusicalKey: "C", Scale: "major", Danceability: 1.0} // 8B
+	target := CurveTarget{Energy: ptr(0.8), BPM: ptr(125)}
+
+	candidates := []models.Track{
+		{ID: 1, BPM: 100, Energy: 0.8, MusicalKey: "C", Scale: "major"},   // Right energy, far too slow
+		{ID: 2, BPM: 125, Energy: 0.2, MusicalKey: "C", Scale: "major"},   // Right tempo, far too calm
+		{ID: 3, BPM: 125, Energy: 0.75, MusicalKey: "G", Scale: "major"},  // Close, and 9B mixes
+		{ID: 4, BPM: 125, Energy: 0.75, MusicalKey: "F#", Scale: "major"}, // As close, key clash
+		{ID: 5, BPM: 0, Energy: 0},                                        // Not analysed
+	}
+
+	ranked := rankByCurve(prev, candidates, target, 0, 1, 0)
+	if ranked[0].ID != 3 || ranked[1].ID != 4 {
+		t.Errorf("expected the closest harmonic match first, got order %v", ids(ranked))
+	}
+	if ranked[len(ranked)-1].ID != 5 {
+		t.Errorf("expected the unanalysed track last, got order %v", ids(ranked))
+	}
+}
+
+func ids(tracks []models.Track) []uint {
+	out := make([]uint, len(tracks))
+	for i, t := range tracks {
+		out[i] = t.ID
+	}
+	return out
+}
@@ -0,0 +1,140 @@
+package dj
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+	"gorm.io/gorm"
+
+	"momo-radio/internal/models"
+)
+
+// AlbumYearSQL reads the leading year of albums.year ("1998", "1998-03-01"); NULL when there is none
+const AlbumYearSQL = "CAST(SUBSTRING(albums.year FROM '^[0-9]{4}') AS int)"
+
+// MatchingTracks narrows a tracks query to the tenant's music that rules selects.
+// Unlike the selectors it ignores recent plays, so it answers "how big is this rotation".
+func MatchingTracks(db *gorm.DB, rules *models.RuleSet, orgID uuid.UUID) *gorm.DB {
+	// ⚡️ THE LOCK: Always restrict to the specific organization first!
+	db = db.Where("tracks.organization_id = ?", orgID)
+
+	// Jingles, IDs and sweepers are inserted by rule, never selected as songs
+	db = db.Where("tracks.kind = ?", models.TrackKindMusic)
+
+	if rules == nil {
+		return db
+	}
+
+	// 1. Simple criteria
+	if rules.Genre != "" {
+		db = db.Where("tracks.genre = ?", rules.Genre)
+	}
+	if rules.MinBPM > 0 {
+		db = db.Where("tracks.bpm >= ?", rules.MinBPM)
+	}
+	if rules.MaxBPM > 0 {
+		db = db.Where("tracks.bpm <= ?", rules.MaxBPM)
+	}
+	if rules.MinYear > 0 {
+		db = db.Where("tracks.album_id IN (SELECT id FROM albums WHERE "+AlbumYearSQL+" >= ?)", rules.MinYear)
+	}
+	if rules.MaxYear > 0 {
+		db = db.Where("tracks.album_id IN (SELECT id FROM albums WHERE "+AlbumYearSQL+" <= ?)", rules.MaxYear)
+	}
+
+	// 2. The structured filter; the legacy Styles CSV counts as extra includes
+	f := rules.Filter
+	styles := f.Styles
+	styles.Include = append(parseCSV(rules.Styles), styles.Include...)
+
+	// tracks.style is a ", " separated list
+	db = filterList(db, "string_to_array(LOWER(COALESCE(tracks.style, '')), ', ') && ?", styles)
+	db = filterList(db, tagMatch("tracks.ml_moods"), f.MLMoods)
+	db = filterList(db, tagMatch("tracks.ml_genres"), f.MLGenres)
+	db = filterList(db, tagMatch("tracks.ml_characteristics"), f.MLCharacteristics)
+	db = filterList(db, "EXISTS (SELECT 1 FROM track_artists JOIN artists ON artists.id = track_artists.artist_id "+
+		"WHERE track_artists.track_id = tracks.id AND LOWER(artists.artist_country) = ANY(?))", f.ArtistCountries)
+	db = filterList(db, "EXISTS (SELECT 1 FROM albums WHERE albums.id = tracks.album_id AND LOWER(albums.publisher) = ANY(?))", f.Labels)
+
+	if f.MinEnergy != nil {
+		db = db.Where("tracks.energy >= ?", *f.MinEnergy)
+	}
+	if f.MaxEnergy != nil {
+		db = db.Where("tracks.energy <= ?", *f.MaxEnergy)
+	}
+	if f.MinDanceability != nil {
+		db = db.Where("tracks.danceability >= ?", *f.MinDanceability)
+	}
+	if f.MaxDanceability != nil {
+		db = db.Where("tracks.danceability <= ?", *f.MaxDanceability)
+	}
+	if after, err := time.Parse(time.DateOnly, f.AddedAfter); err == nil {
+		db = db.Where("tracks.created_at >= ?", after)
+	}
+	if f.MaxPlayCount != nil {
+		db = db.Where("tracks.play_count <= ?", *f.MaxPlayCount)
+	}
+
+	return db
+}
+
+// tagMatch is true when any tag of an ML array column is in the (lowercased) list
+func tagMatch(column string) string {
+	return "EXISTS (SELECT 1 FROM unnest(" + column + ") AS tag WHERE LOWER(tag) = ANY(?))"
+}
+
+// filterList applies cond (true when the track matches any of the values) for includes, and its negation for excludes
+func filterList(db *gorm.DB, cond string, list models.FilterList) *gorm.DB {
+	if len(list.Include) > 0 {
+		db = db.Where(cond, lowerAll(list.Include))
+	}
+	if len(list.Exclude) > 0 {
+		db = db.Where("NOT ("+cond+")", lowerAll(list.Exclude))
+	}
+	return db
+}
+
+func lowerAll(values []string) any {
+	out := make([]string, len(values))
+	for i, v := range values {
+		out[i] = strings.ToLower(v)
+	}
+	return pq.Array(out)
+}
+
+// ValidateFilter cleans up the lists (trimmed, no blanks) and rejects impossible ranges
+func ValidateFilter(f *models.RuleFilter) error {
+	for _, list := range []*models.FilterList{&f.Styles, &f.MLMoods, &f.MLGenres, &f.MLCharacteristics, &f.ArtistCountries, &f.Labels} {
+		list.Include = cleanList(list.Include)
+		list.Exclude = cleanList(list.Exclude)
+	}
+
+	if f.MinEnergy != nil && f.MaxEnergy != nil && *f.MinEnergy > *f.MaxEnergy {
+		return fmt.Errorf("invalid energy range")
+	}
+	if f.MinDanceability != nil && f.MaxDanceability != nil && *f.MinDanceability > *f.MaxDanceability {
+		return fmt.Errorf("invalid danceability range")
+	}
+	if f.MaxPlayCount != nil && *f.MaxPlayCount < 0 {
+		return fmt.Errorf("max_play_count cannot be negative")
+	}
+	if f.AddedAfter != "" {
+		if _, err := time.Parse(time.DateOnly, f.AddedAfter); err != nil {
+			return fmt.Errorf("added_after must be a date (YYYY-MM-DD)")
+		}
+	}
+	return nil
+}
+
+func cleanList(values []string) []string {
+	var out []string
+	for _, v := range values {
+		if v = strings.TrimSpace(v); v != "" {
+			out = append(out, v)
+		}
+	}
+	return out
+}
@@ -0,0 +1,74 @@
+package dj
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+
+	database "momo-radio/internal/db"
+	"momo-radio/internal/models"
+)
+
+// dryRunDB renders statements without a database
+func dryRunDB(t *testing.T) *gorm.DB {
+	db, err := database.DryRun()
+	if err != nil {
+		t.Fatalf("open: %v", err)
+	}
+	return db
+}
+
+func TestMatchingTracksSQL(t *testing.T) {
+	db := dryRunDB(t)
+	minEnergy := 0.4
+	rules := &models.RuleSet{
+		Styles:  "Dub, Deep",
+		MaxYear: 2005,
+		Filter: models.RuleFilter{
+			MLMoods:   models.FilterList{Include: []string{"Happy"}},
+			Labels:    models.FilterList{Exclude: []string{"Big Label"}},
+			MinEnergy: &minEnergy,
+		},
+	}
+
+	sql := db.ToSQL(func(tx *gorm.DB) *gorm.DB {
+		var tracks []models.Track
+		return MatchingTracks(tx.Model(&models.Track{}), rules, uuid.New()).Find(&tracks)
+	})
+
+	for _, want := range []string{
+		"tracks.organization_id =",
+		"string_to_array(LOWER(COALESCE(tracks.style, '')), ', ') && '{\"dub\",\"deep\"}'",
+		"unnest(tracks.ml_moods)",
+		"NOT (EXISTS (SELECT 1 FROM albums WHERE albums.id = tracks.album_id AND LOWER(albums.publisher) = ANY('{\"big label\"}')))",
+		"tracks.energy >= 0.4",
+		"<= 2005",
+	} {
+		if !strings.Contains(sql, want) {
+			t.Errorf("query is missing %q:\n%s", want, sql)
+		}
+	}
+	if strings.Contains(sql, "play_count") || strings.Contains(sql, "danceability") {
+		t.Errorf("unset criteria should not filter:\n%s", sql)
+	}
+}
+
+func TestValidateFilter(t *testing.T) {
+	f := models.RuleFilter{Styles: models.FilterList{Include: []string{" Dub ", "", "  "}}}
+	if err := ValidateFilter(&f); err != nil {
+		t.Fatal(err)
+	}
+	if len(f.Styles.Include) != 1 || f.Styles.Include[0] != "Dub" {
+		t.Errorf("lists should be trimmed, got %q", f.Styles.Include)
+	}
+
+	lo, hi := 0.8, 0.2
+	if ValidateFilter(&models.RuleFilter{MinEnergy: &lo, MaxEnergy: &hi}) == nil {
+		t.Error("inverted energy range accepted")
+	}
+	if ValidateFilter(&models.RuleFilter{AddedAfter: "last week"}) == nil {
+		t.Error("invalid added_after accepted")
+	}
+}
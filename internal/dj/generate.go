@@ -0,0 +1,224 @@
+package dj
+
+import (
+	"errors"
+	"math"
+	"math/rand"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+
+	"momo-radio/internal/audio"
+	"momo-radio/internal/models"
+)
+
+const (
+	// generatePoolSize bounds how many library tracks a generated playlist is chained from
+	generatePoolSize = 400
+	// generateVariety picks among the best few next tracks, so generating twice gives two playlists
+	generateVariety = 3
+	// MaxGeneratedTracks caps a generated playlist
+	MaxGeneratedTracks = 200
+	// similarityWeight scales a feature vector distance (about 0..2) to mix score points
+	similarityWeight = 40.0
+	// DefaultTrackLength stands in for tracks whose duration was never analysed
+	DefaultTrackLength = 4 * time.Minute
+)
+
+// GenerateOptions describes the playlist to build. At least one of Seed and Rules is set.
+type GenerateOptions struct {
+	Seed     *models.Track   // First track; the rest stays close to it
+	Rules    *models.RuleSet // Which tracks qualify (and the separation policy)
+	Duration time.Duration   // Stop once the playlist is this long (0: MaxGeneratedTracks)
+}
+
+// GeneratePlaylist chains tracks by mix compatibility and acoustic similarity into a draft playlist
+func GeneratePlaylist(db *gorm.DB, orgID uuid.UUID, opts GenerateOptions) ([]models.Track, error) {
+	if opts.Seed == nil && opts.Rules == nil {
+		return nil, errors.New("a seed track or a rule set is required")
+	}
+
+	// 1. Candidate pool: the rule set's tracks, nearest in tempo to the seed first
+	query := MatchingTracks(db.Model(&models.Track{}), opts.Rules, orgID).
+		Where("tracks.key <> '' AND tracks.processing_status <> ?", "failed").
+		Preload("Artists").Preload("Album")
+	if opts.Seed != nil {
+		query = query.Where("tracks.id <> ?", opts.Seed.ID).
+			Order(gorm.Expr("ABS(tracks.bpm - ?) ASC", opts.Seed.BPM))
+	} else {
+		query = query.Order("RANDOM()")
+	}
+
+	var pool []models.Track
+	if err := query.Limit(generatePoolSize).Find(&pool).Error; err != nil {
+		return nil, err
+	}
+
+	policy := resolveSeparation(db, opts.Rules, orgID)
+	rng := rand.New(rand.NewSource(time.Now().UnixNano()))
+	return chainTracks(opts.Seed, pool, opts.Duration, policy, generateVariety, rng), nil
+}
+
+// chainTracks greedily appends the pool track that mixes best out of the previous one and stays
+// closest to the seed, keeping artists, albums and labels apart on the playlist's own timeline
+func chainTracks(seed *models.Track, pool []models.Track, target time.Duration, policy models.Separation, variety int, rng *rand.Rand) []models.Track {
+	var playlist []models.Track
+	var offsets []time.Duration // When each playlist track starts
+	var length time.Duration
+
+	add := func(t models.Track) {
+		playlist = append(playlist, t)
+		offsets = append(offsets, length)
+		length += TrackLength(t)
+	}
+
+	used := make(map[uint]bool)
+	if seed != nil {
+		add(*seed)
+		used[seed.ID] = true
+	} else if len(pool) > 0 {
+		first := pool[rng.Intn(len(pool))]
+		add(first)
+		used[first.ID] = true
+	}
+	if len(playlist) == 0 {
+		return nil
+	}
+	anchor := playlist[0]
+
+	for len(playlist) < MaxGeneratedTracks && (target <= 0 || length < target) {
+		prev := playlist[len(playlist)-1]
+
+		type scored struct {
+			track models.Track
+			score float64
+		}
+		var ranked []scored
+
+		// Loosen the separation only when nothing is left that respects it
+		for _, step := range relaxations(policy) {
+			for _, c := range pool {
+				if used[c.ID] || separatedTooLittle(c, playlist, offsets, length, step) {
+					continue
+				}
+				mix := 50.0 // Unknown tempo: neither good nor terrible
+				if prev.BPM > 0 && c.BPM > 0 {
+					mix = audio.CalculateMixScore(prev, c)
+				}
+				ranked = append(ranked, scored{track: c, score: mix + AcousticDistance(anchor, c)})
+			}
+			if len(ranked) > 0 {
+				break
+			}
+		}
+		if len(ranked) == 0 {
+			break // Pool exhausted
+		}
+
+		sort.SliceStable(ranked, func(i, j int) bool { return ranked[i].score < ranked[j].score })
+		next := ranked[rng.Intn(min(variety, len(ranked)))].track
+		add(next)
+		used[next.ID] = true
+	}
+	return playlist
+}
+
+// TrackLength is the track's duration, or DefaultTrackLength when it is unknown
+func TrackLength(t models.Track) time.Duration {
+	if t.Duration <= 0 {
+		return DefaultTrackLength
+	}
+	return time.Duration(t.Duration * float64(time.Second))
+}
+
+// separatedTooLittle reports whether c would air too soon after a playlist track sharing its artist, album or label
+func separatedTooLittle(c models.Track, playlist []models.Track, offsets []time.Duration, at time.Duration, p models.Separation) bool {
+	within := func(minutes int, i int) bool {
+		return minutes > 0 && at-offsets[i] < time.Duration(minutes)*time.Minute
+	}
+
+	for i, t := range playlist {
+		if within(p.ArtistMinutes, i) && sharesArtist(c, t) {
+			return true
+		}
+		if within(p.AlbumMinutes, i) && c.AlbumID != nil && t.AlbumID != nil && *c.AlbumID == *t.AlbumID {
+			return true
+		}
+		if within(p.LabelMinutes, i) && c.Album.Publisher != "" && strings.EqualFold(c.Album.Publisher, t.Album.Publisher) {
+			return true
+		}
+	}
+	return false
+}
+
+func sharesArtist(a, b models.Track) bool {
+	for _, x := range a.Artists {
+		for _, y := range b.Artists {
+			if x.ID == y.ID {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// AcousticDistance compares two tracks on tempo, energy, danceability and ML tags. 0 is identical;
+// features missing on either side count as a moderate difference.
+func AcousticDistance(a, b models.Track) float64 {
+	// Stored feature vectors already weigh all of it, key included
+	if d := audio.FeatureDistance(a.Features, b.Features); d >= 0 {
+		return d * similarityWeight
+	}
+
+	distance := 0.0
+
+	if a.BPM > 0 && b.BPM > 0 {
+		distance += math.Abs(a.BPM-b.BPM) / a.BPM * 100
+	} else {
+		distance += 15
+	}
+
+	if a.Energy > 0 && b.Energy > 0 {
+		distance += math.Abs(a.Energy-b.Energy) / math.Max(a.Energy, b.Energy) * 30
+	} else {
+		distance += 10
+	}
+
+	distance += math.Abs(a.Danceability-b.Danceability) * 10
+
+	tagsA := tagSet(a.MLMoods, a.MLGenres)
+	tagsB := tagSet(b.MLMoods, b.MLGenres)
+	if len(tagsA) > 0 && len(tagsB) > 0 {
+		distance += (1 - jaccard(tagsA, tagsB)) * 30
+	} else {
+		distance += 15
+	}
+	return distance
+}
+
+func tagSet(lists ...[]string) map[string]bool {
+	set := make(map[string]bool)
+	for _, list := range lists {
+		for _, tag := range list {
+			set[strings.ToLower(tag)] = true
+		}
+	}
+	return set
+}
+
+func jaccard(a, b map[string]bool) float64 {
+	shared := 0
+	for tag := range a {
+		if b[tag] {
+			shared++
+		}
+	}
+	union := len(a) + len(b) - shared
+	if union == 0 {
+		return 0
+	}
+	return float64(shared) / float64(union)
+}
@@ -0,0 +1,64 @@
+package dj
+
+import (
+	"math/rand"
+	"testing"
+	"time"
+
+	"momo-radio/internal/models"
+)
+
+func TestChainTracks(t *testing.T) {
+	artistA := models.Artist{ID: 1}
+	artistB := models.Artist{ID: 2}
+
+	seed := &models.Track{ID: 1, BPM: 122, MusicalKey: "C", Scale: "major", Energy: 0.5, Duration: 300, Artists: []models.Artist{artistA}}
+	pool := []models.Track{
+		{ID: 2, BPM: 122, MusicalKey: "C", Scale: "major", Energy: 0.5, Duration: 300, Artists: []models.Artist{artistA}},  // Perfect, but same artist
+		{ID: 3, BPM: 123, MusicalKey: "G", Scale: "major", Energy: 0.5, Duration: 300, Artists: []models.Artist{artistB}},  // 9B, close
+		{ID: 4, BPM: 150, MusicalKey: "F#", Scale: "major", Energy: 0.9, Duration: 300, Artists: []models.Artist{artistA}}, // Trainwreck
+	}
+	policy := models.Separation{ArtistMinutes: 30}
+	rng := rand.New(rand.NewSource(1))
+
+	got := chainTracks(seed, pool, 12*time.Minute, policy, 1, rng)
+	if len(got) != 3 {
+		t.Fatalf("expected 3 tracks to cover 12 minutes, got %v", ids(got))
+	}
+	if got[0].ID != 1 || got[1].ID != 3 {
+		t.Errorf("expected the seed, then the closest track by another artist, got %v", ids(got))
+	}
+	// Only artist A is left: separation relaxes rather than ending early, and mixing decides again
+	if got[2].ID != 2 {
+		t.Errorf("expected the relaxed artist repeat over the trainwreck, got %v", ids(got))
+	}
+
+	if got := chainTracks(seed, pool, 0, policy, 1, rng); len(got) != len(pool)+1 {
+		t.Errorf("without a duration the whole pool should be used, got %v", ids(got))
+	}
+	// Unknown durations count as the default length instead of never adding up
+	unprobed := make([]models.Track, 50)
+	for i := range unprobed {
+		unprobed[i] = models.Track{ID: uint(100 + i), BPM: 122}
+	}
+	if got := chainTracks(nil, unprobed, 12*time.Minute, models.Separation{}, 1, rng); len(got) != 3 {
+		t.Errorf("expected 3 default-length tracks to cover 12 minutes, got %d", len(got))
+	}
+
+	if got := chainTracks(nil, nil, time.Hour, policy, 1, rng); got != nil {
+		t.Errorf("nothing to chain from should give nothing, got %v", ids(got))
+	}
+}
+
+func TestAcousticDistance(t *testing.T) {
+	a := models.Track{BPM: 120, Energy: 0.6, Danceability: 1.2, MLMoods: []string{"happy", "party"}}
+	near := models.Track{BPM: 122, Energy: 0.55, Danceability: 1.1, MLMoods: []string{"Happy"}}
+	far := models.Track{BPM: 80, Energy: 0.1, Danceability: 0.2, MLMoods: []string{"sad"}}
+
+	if AcousticDistance(a, a) != 0 {
+		t.Errorf("a track should be at distance 0 from itself, got %f", AcousticDistance(a, a))
+	}
+	if AcousticDistance(a, near) >= AcousticDistance(a, far) {
+		t.Errorf("near (%f) should be closer than far (%f)", AcousticDistance(a, near), AcousticDistance(a, far))
+	}
+}
@@ -0,0 +1,80 @@
+package dj
+
+import (
+	"errors"
+	"math/rand"
+	"sort"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+
+	"momo-radio/internal/audio"
+	"momo-radio/internal/models"
+)
+
+const (
+	// harmonicPoolSize is how many random candidates we score per pick.
+	harmonicPoolSize = 50
+	// harmonicTopN keeps some variety: we pick randomly among the best few mixes.
+	harmonicTopN = 5
+)
+
+type HarmonicSelector struct {
+	db    *gorm.DB
+	orgID uuid.UUID
+}
+
+func (s *HarmonicSelector) Name() string { return "Harmonic" }
+
+func (s *HarmonicSelector) PickTrack(rules *models.RuleSet, lastTrack *models.Track) (*models.Track, error) {
+	candidates, err := findSeparated(s.db, rules, s.orgID, func(q *gorm.DB) *gorm.DB {
+		if lastTrack != nil && lastTrack.ID != 0 {
+			q = q.Where("tracks.id <> ?", lastTrack.ID)
+		}
+		return q.Order("RANDOM()").Limit(harmonicPoolSize)
+	})
+	if err != nil || len(candidates) == 0 {
+		return nil, errors.New("harmonic: no tracks found")
+	}
+
+	// Without an analysed previous track there is nothing to mix against.
+	if lastTrack == nil || lastTrack.BPM <= 0 {
+		return &candidates[rand.Intn(len(candidates))], nil
+	}
+
+	best := rankByMix(*lastTrack, candidates, harmonicTopN)
+	return &best[rand.Intn(len(best))], nil
+}
+
+// rankByMix sorts candidates by audio.CalculateMixScore against prev, plus how different they
+// sound when both have a feature vector, and returns the best n. Tracks without a BPM are pushed to the back.
+func rankByMix(prev models.Track, candidates []models.Track, n int) []models.Track {
+	type scored struct {
+		track models.Track
+		score float64
+	}
+
+	ranked := make([]scored, 0, len(candidates))
+	for _, c := range candidates {
+		score := audio.CalculateMixScore(prev, c)
+		if d := audio.FeatureDistance(prev.Features, c.Features); d >= 0 {
+			score += d * similarityWeight
+		}
+		if c.BPM <= 0 {
+			score += 1000
+		}
+		ranked = append(ranked, scored{track: c, score: score})
+	}
+
+	sort.SliceStable(ranked, func(i, j int) bool { return ranked[i].score < ranked[j].score })
+
+	if n <= 0 || n > len(ranked) {
+		n = len(ranked)
+	}
+
+	result := make([]models.Track, n)
+	for i := 0; i < n; i++ {
+		result[i] = ranked[i].track
+	}
+	return result
+}
@@ -0,0 +1,34 @@
+package dj
+
+import (
+	"momo-radio/internal/models"
+	"testing"
+)
+
+func TestRankByMix(t *testing.T) {
+	prev := models.Track{BPM: 120, MusicalKey: "C", Scale: "major", Danceability: 1.0} // 8B
+
+	candidates := []models.Track{
+		{ID: 1, BPM: 140, MusicalKey: "F#", Scale: "major", Danceability: 2.5}, // Trainwreck
+		{ID: 2, BPM: 0, MusicalKey: "C", Scale: "major"},                       // Not analysed
+		{ID: 3, BPM: 121, MusicalKey: "G", Scale: "major", Danceability: 1.1},  // 9B, close BPM
+		{ID: 4, BPM: 120, MusicalKey: "Eb", Scale: "major", Danceability: 1.0}, // Same BPM, key clash
+	}
+
+	ranked := rankByMix(prev, candidates, 2)
+
+	if len(ranked) != 2 {
+		t.Fatalf("expected 2 tracks, got %d", len(ranked))
+	}
+	if ranked[0].ID != 3 {
+		t.Errorf("expected harmonic match (ID 3) first, got ID %d", ranked[0].ID)
+	}
+	if ranked[1].ID != 4 {
+		t.Errorf("expected BPM match (ID 4) second, got ID %d", ranked[1].ID)
+	}
+
+	all := rankByMix(prev, candidates, 0)
+	if all[len(all)-1].ID != 2 {
+		t.Errorf("expected unanalysed track last, got ID %d", all[len(all)-1].ID)
+	}
+}
@@ -15,14 +15,15 @@ type RandomSelector struct {
 func (s *RandomSelector) Name() string { return "Random" }
 
 func (s *RandomSelector) PickTrack(rules *models.RuleSet, _ *models.Track) (*models.Track, error) {
-	var track models.Track
-	query := s.db.Model(&models.Track{})
-	query = applyBaseFilters(query, rules, s.orgID)
-
-	err := query.Order("RANDOM()").First(&track).Error
+	tracks, err := findSeparated(s.db, rules, s.orgID, func(q *gorm.DB) *gorm.DB {
+		return q.Order("RANDOM()").Limit(1)
+	})
 	if err != nil {
 		return nil, err
 	}
+	if len(tracks) == 0 {
+		return nil, gorm.ErrRecordNotFound
+	}
 
-	return &track, nil
+	return &tracks[0], nil
 }
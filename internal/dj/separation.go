@@ -0,0 +1,121 @@
+package dj
+
+import (
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+
+	"momo-radio/internal/models"
+)
+
+// DefaultSeparation applies when neither the RuleSet nor the station sets a policy
+var DefaultSeparation = models.Separation{TrackMinutes: 120}
+
+// maxSeparationMinutes caps each rule at a week
+const maxSeparationMinutes = 7 * 24 * 60
+
+// ValidateSeparation rejects negative or absurd windows
+func ValidateSeparation(s models.Separation) error {
+	for _, m := range []int{s.TrackMinutes, s.ArtistMinutes, s.AlbumMinutes, s.LabelMinutes} {
+		if m < 0 || m > maxSeparationMinutes {
+			return fmt.Errorf("separation minutes must be between 0 and %d", maxSeparationMinutes)
+		}
+	}
+	return nil
+}
+
+// resolveSeparation picks the policy on air: the RuleSet's own, else the station's, else the default
+func resolveSeparation(db *gorm.DB, rules *models.RuleSet, orgID uuid.UUID) models.Separation {
+	if rules != nil && rules.Separation != nil {
+		return *rules.Separation
+	}
+
+	var settings models.OrganizationSettings
+	if err := db.Select("separation").Where("organization_id = ?", orgID).Limit(1).Find(&settings).Error; err == nil && settings.Separation != nil {
+		return *settings.Separation
+	}
+	return DefaultSeparation
+}
+
+// relaxations lists policy and then ever looser versions of it, ending with no separation at all,
+// so a small library degrades to repeats instead of dead air
+func relaxations(p models.Separation) []models.Separation {
+	half := models.Separation{
+		TrackMinutes:  p.TrackMinutes / 2,
+		ArtistMinutes: p.ArtistMinutes / 2,
+		AlbumMinutes:  p.AlbumMinutes / 2,
+		LabelMinutes:  p.LabelMinutes / 2,
+	}
+	steps := []models.Separation{
+		p,
+		half,
+		{TrackMinutes: half.TrackMinutes, ArtistMinutes: half.ArtistMinutes}, // Albums and labels go first
+		{TrackMinutes: p.TrackMinutes / 4},
+		{},
+	}
+
+	out := steps[:1]
+	for _, s := range steps[1:] {
+		if s != out[len(out)-1] {
+			out = append(out, s)
+		}
+	}
+	return out
+}
+
+// applySeparation drops tracks whose track, artist, album or label aired within the policy's windows
+func applySeparation(db *gorm.DB, p models.Separation, orgID uuid.UUID, now time.Time) *gorm.DB {
+	since := func(minutes int) time.Time { return now.Add(-time.Duration(minutes) * time.Minute) }
+
+	if p.TrackMinutes > 0 {
+		db = db.Where("tracks.id NOT IN (SELECT track_id FROM play_histories WHERE organization_id = ? AND played_at > ?)",
+			orgID, since(p.TrackMinutes))
+	}
+	if p.ArtistMinutes > 0 {
+		db = db.Where("NOT EXISTS (SELECT 1 FROM track_artists WHERE track_artists.track_id = tracks.id AND track_artists.artist_id IN "+
+			"(SELECT played.artist_id FROM play_histories JOIN track_artists AS played ON played.track_id = play_histories.track_id "+
+			"WHERE play_histories.organization_id = ? AND play_histories.played_at > ?))",
+			orgID, since(p.ArtistMinutes))
+	}
+	if p.AlbumMinutes > 0 {
+		db = db.Where("tracks.album_id IS NULL OR tracks.album_id NOT IN "+
+			"(SELECT played.album_id FROM play_histories JOIN tracks AS played ON played.id = play_histories.track_id "+
+			"WHERE play_histories.organization_id = ? AND play_histories.played_at > ? AND played.album_id IS NOT NULL)",
+			orgID, since(p.AlbumMinutes))
+	}
+	if p.LabelMinutes > 0 {
+		db = db.Where("NOT EXISTS (SELECT 1 FROM albums WHERE albums.id = tracks.album_id AND albums.publisher <> '' AND LOWER(albums.publisher) IN "+
+			"(SELECT LOWER(label.publisher) FROM play_histories JOIN tracks AS played ON played.id = play_histories.track_id "+
+			"JOIN albums AS label ON label.id = played.album_id WHERE play_histories.organization_id = ? AND play_histories.played_at > ?))",
+			orgID, since(p.LabelMinutes))
+	}
+	return db
+}
+
+// findSeparated loads the tracks rules selects that respect the separation policy, relaxing it step by step
+// until something qualifies. shape adds the selector's own ordering, limit and exclusions.
+func findSeparated(db *gorm.DB, rules *models.RuleSet, orgID uuid.UUID, shape func(*gorm.DB) *gorm.DB) ([]models.Track, error) {
+	policy := resolveSeparation(db, rules, orgID)
+	now := time.Now()
+
+	var tracks []models.Track
+	for i, step := range relaxations(policy) {
+		// ⚡️ MatchingTracks holds the tenant lock, every attempt starts from it
+		query := MatchingTracks(db.Model(&models.Track{}), rules, orgID)
+		query = shape(applySeparation(query, step, orgID, now))
+
+		if err := query.Find(&tracks).Error; err != nil {
+			return nil, err
+		}
+		if len(tracks) > 0 {
+			if i > 0 {
+				log.Printf("[%s] Separation relaxed to %+v: library too small for %+v", orgID, step, policy)
+			}
+			return tracks, nil
+		}
+	}
+	return nil, nil
+}
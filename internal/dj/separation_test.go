@@ -0,0 +1,74 @@
+package dj
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+
+	"momo-radio/internal/models"
+)
+
+func TestRelaxations(t *testing.T) {
+	steps := relaxations(models.Separation{TrackMinutes: 240, ArtistMinutes: 60, AlbumMinutes: 120, LabelMinutes: 30})
+
+	want := []models.Separation{
+		{TrackMinutes: 240, ArtistMinutes: 60, AlbumMinutes: 120, LabelMinutes: 30},
+		{TrackMinutes: 120, ArtistMinutes: 30, AlbumMinutes: 60, LabelMinutes: 15},
+		{TrackMinutes: 120, ArtistMinutes: 30},
+		{TrackMinutes: 60},
+		{},
+	}
+	if len(steps) != len(want) {
+		t.Fatalf("got %d steps, want %d: %+v", len(steps), len(want), steps)
+	}
+	for i := range want {
+		if steps[i] != want[i] {
+			t.Errorf("step %d = %+v, want %+v", i, steps[i], want[i])
+		}
+	}
+
+	// Identical steps collapse, and the last one always separates nothing
+	if got := relaxations(models.Separation{}); len(got) != 1 {
+		t.Errorf("an empty policy should not relax, got %+v", got)
+	}
+	if got := relaxations(models.Separation{TrackMinutes: 1}); got[len(got)-1] != (models.Separation{}) {
+		t.Errorf("relaxation must end without separation, got %+v", got)
+	}
+}
+
+func TestApplySeparationSQL(t *testing.T) {
+	db := dryRunDB(t)
+	now := time.Date(2026, 3, 2, 12, 0, 0, 0, time.UTC)
+
+	sql := db.ToSQL(func(tx *gorm.DB) *gorm.DB {
+		var tracks []models.Track
+		q := applySeparation(tx.Model(&models.Track{}), models.Separation{TrackMinutes: 120, LabelMinutes: 60}, uuid.New(), now)
+		return q.Find(&tracks)
+	})
+
+	for _, want := range []string{
+		"tracks.id NOT IN (SELECT track_id FROM play_histories",
+		"played_at > '2026-03-02 10:00:00'",
+		"LOWER(albums.publisher) IN",
+		"play_histories.played_at > '2026-03-02 11:00:00'",
+	} {
+		if !strings.Contains(sql, want) {
+			t.Errorf("query is missing %q:\n%s", want, sql)
+		}
+	}
+	if strings.Contains(sql, "track_artists") {
+		t.Errorf("artist separation is off but was applied:\n%s", sql)
+	}
+}
+
+func TestValidateSeparation(t *testing.T) {
+	if ValidateSeparation(models.Separation{TrackMinutes: 180, ArtistMinutes: 45}) != nil {
+		t.Error("valid policy rejected")
+	}
+	if ValidateSeparation(models.Separation{AlbumMinutes: -1}) == nil {
+		t.Error("negative window accepted")
+	}
+}
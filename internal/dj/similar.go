@@ -0,0 +1,61 @@
+package dj
+
+import (
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+	"gorm.io/gorm"
+
+	"momo-radio/internal/models"
+)
+
+// featureDistanceSQL is the Euclidean distance between tracks.features and the bound vector
+const featureDistanceSQL = "(SELECT SQRT(SUM((f - q) * (f - q))) FROM unnest(tracks.features, ?::float8[]) AS u(f, q))"
+
+// SimilarTrack is a neighbour of a track in feature space
+type SimilarTrack struct {
+	Track    models.Track
+	Distance float64
+}
+
+// SimilarTracks returns the tenant's music closest to features, nearest first (Artists and Album preloaded).
+// exclude lists track ids to leave out, typically the seed itself.
+func SimilarTracks(db *gorm.DB, orgID uuid.UUID, features []float64, limit int, exclude ...uint) ([]SimilarTrack, error) {
+	var hits []struct {
+		ID       uint
+		Distance float64
+	}
+
+	query := MatchingTracks(db.Model(&models.Track{}), nil, orgID).
+		Where("tracks.features IS NOT NULL AND cardinality(tracks.features) = ?", len(features))
+	if len(exclude) > 0 {
+		query = query.Where("tracks.id NOT IN ?", exclude)
+	}
+
+	err := query.Select("tracks.id, "+featureDistanceSQL+" AS distance", pq.Float64Array(features)).
+		Order("distance ASC").Limit(limit).
+		Scan(&hits).Error
+	if err != nil || len(hits) == 0 {
+		return nil, err
+	}
+
+	ids := make([]uint, len(hits))
+	for i, hit := range hits {
+		ids[i] = hit.ID
+	}
+	var tracks []models.Track
+	if err := db.Preload("Artists").Preload("Album").Where("id IN ?", ids).Find(&tracks).Error; err != nil {
+		return nil, err
+	}
+
+	byID := make(map[uint]models.Track, len(tracks))
+	for _, t := range tracks {
+		byID[t.ID] = t
+	}
+	out := make([]SimilarTrack, 0, len(hits))
+	for _, hit := range hits {
+		if t, ok := byID[hit.ID]; ok {
+			out = append(out, SimilarTrack{Track: t, Distance: hit.Distance})
+		}
+	}
+	return out, nil
+}
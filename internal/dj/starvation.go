@@ -17,14 +17,11 @@ type StarvationSelector struct {
 func (s *StarvationSelector) Name() string { return "Starvation" }
 
 func (s *StarvationSelector) PickTrack(rules *models.RuleSet, _ *models.Track) (*models.Track, error) {
-	var candidates []models.Track
-
-	query := s.db.Model(&models.Track{})
-	query = applyBaseFilters(query, rules, s.orgID)
-
 	// Sort by oldest played first.
 	// We grab 20 to pick one randomly so the station doesn't feel like a loop.
-	err := query.Order("last_played_at ASC NULLS FIRST").Limit(20).Find(&candidates).Error
+	candidates, err := findSeparated(s.db, rules, s.orgID, func(q *gorm.DB) *gorm.DB {
+		return q.Order("tracks.last_played ASC NULLS FIRST").Limit(20)
+	})
 	if err != nil || len(candidates) == 0 {
 		return nil, errors.New("starvation: no tracks found")
 	}
@@ -0,0 +1,269 @@
+package ingest
+
+import (
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/lib/pq"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+
+	"momo-radio/internal/audio"
+	"momo-radio/internal/models"
+)
+
+const (
+	// duplicateThreshold is the fingerprint similarity from which two tracks are the same recording
+	duplicateThreshold = 0.85
+	// duplicateDurationWindow (seconds) limits fingerprint comparisons to tracks of about the same length
+	duplicateDurationWindow = 10.0
+	// duplicateCandidates caps how many library tracks one track is compared against, per method
+	duplicateCandidates = 200
+)
+
+type duplicateMatch struct {
+	TrackID    uint
+	Similarity float64
+	Reason     string
+}
+
+// -----------------------------------------------------------------------------
+// DUPLICATE CHECK STEP
+// -----------------------------------------------------------------------------
+type DuplicateCheckStep struct{}
+
+func (s *DuplicateCheckStep) Name() string { return "deduplicating" }
+
+// Execute flags likely duplicates for review; it never stops the ingest, editors decide
+func (s *DuplicateCheckStep) Execute(ctx *ProcessingContext) error {
+	db := ctx.Worker.db.DB
+	if ctx.Meta == nil {
+		return nil
+	}
+
+	if len(ctx.Fingerprint) > 0 {
+		db.Model(ctx.Track).Update("fingerprint", fingerprintColumn(ctx.Fingerprint))
+	}
+
+	matches, err := findDuplicates(db, *ctx.Track, ctx.Fingerprint, ctx.Meta.Artists, ctx.Meta.Title, ctx.Meta.Duration, false)
+	if err != nil {
+		log.Printf("Duplicate check failed for track %d: %v", ctx.Track.ID, err)
+		return nil
+	}
+	if n := recordDuplicates(db, *ctx.Track, matches); n > 0 {
+		log.Printf("[%s] Track %d flagged as a possible duplicate of %d track(s)", ctx.OrgID, ctx.Track.ID, n)
+	}
+	return nil
+}
+
+// findDuplicates compares a track against its tenant's music by fingerprint and by normalized artist/title.
+// olderOnly restricts the comparison to tracks added before it, so a full scan reports each pair once.
+func findDuplicates(db *gorm.DB, track models.Track, fp []uint32, artists []string, title string, duration float64, olderOnly bool) ([]duplicateMatch, error) {
+	library := func() *gorm.DB {
+		q := db.Model(&models.Track{}).
+			Where("tracks.organization_id = ? AND tracks.kind = ? AND tracks.id <> ?", track.OrganizationID, models.TrackKindMusic, track.ID)
+		if olderOnly {
+			q = q.Where("tracks.id < ?", track.ID)
+		}
+		return q
+	}
+	found := make(map[uint]*duplicateMatch)
+	var order []uint
+
+	// 1. Same audio: only tracks of about the same length can be
+	if len(fp) > 0 {
+		query := library().Select("id, fingerprint").Where("tracks.fingerprint IS NOT NULL")
+		if duration > 0 {
+			query = query.Where("ABS(tracks.duration - ?) <= ?", duration, duplicateDurationWindow).
+				Order(gorm.Expr("ABS(tracks.duration - ?)", duration))
+		}
+
+		var candidates []models.Track
+		if err := query.Limit(duplicateCandidates).Find(&candidates).Error; err != nil {
+			return nil, err
+		}
+		for _, c := range candidates {
+			if similarity := audio.FingerprintSimilarity(fp, fingerprintItems(c.Fingerprint)); similarity >= duplicateThreshold {
+				found[c.ID] = &duplicateMatch{TrackID: c.ID, Similarity: similarity, Reason: models.DuplicateReasonFingerprint}
+				order = append(order, c.ID)
+			}
+		}
+	}
+
+	// 2. Same song by name, whatever the edit ("Time For Us (Radio Edit)" = "Time For Us")
+	if normTitle := NormalizeTitle(title); normTitle != "" {
+		var candidates []models.Track
+		err := library().Preload("Artists").
+			Where("LOWER(tracks.title) LIKE ?", escapeLike(normTitle)+"%").
+			Limit(duplicateCandidates).Find(&candidates).Error
+		if err != nil {
+			return nil, err
+		}
+		for _, c := range candidates {
+			var names []string
+			for _, a := range c.Artists {
+				names = append(names, a.Name)
+			}
+			if !sameArtistTitle(title, artists, c.Title, names) {
+				continue
+			}
+			if m, ok := found[c.ID]; ok {
+				m.Reason = models.DuplicateReasonBoth
+			} else {
+				found[c.ID] = &duplicateMatch{TrackID: c.ID, Reason: models.DuplicateReasonMetadata}
+				order = append(order, c.ID)
+			}
+		}
+	}
+
+	matches := make([]duplicateMatch, 0, len(order))
+	for _, id := range order {
+		matches = append(matches, *found[id])
+	}
+	return matches, nil
+}
+
+// sameArtistTitle is true when the normalized titles are equal and at least one normalized artist is shared
+func sameArtistTitle(titleA string, artistsA []string, titleB string, artistsB []string) bool {
+	if NormalizeTitle(titleA) == "" || NormalizeTitle(titleA) != NormalizeTitle(titleB) {
+		return false
+	}
+
+	names := make(map[string]bool)
+	for _, raw := range artistsA {
+		for _, a := range NormalizeArtist(raw) {
+			names[strings.ToLower(a)] = true
+		}
+	}
+	for _, raw := range artistsB {
+		for _, b := range NormalizeArtist(raw) {
+			if names[strings.ToLower(b)] {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// recordDuplicates queues the matches for review, the later upload of each pair being the duplicate.
+// Pairs already reviewed are left alone. Returns how many matches were found.
+func recordDuplicates(db *gorm.DB, track models.Track, matches []duplicateMatch) int {
+	for _, m := range matches {
+		newer, older := track.ID, m.TrackID
+		if newer < older {
+			newer, older = older, newer
+		}
+		entry := models.TrackDuplicate{
+			OrganizationID: track.OrganizationID,
+			TrackID:        newer,
+			DuplicateOfID:  older,
+			Similarity:     m.Similarity,
+			Reason:         m.Reason,
+			Status:         models.DuplicatePending,
+		}
+		if err := db.Clauses(clause.OnConflict{DoNothing: true}).Create(&entry).Error; err != nil {
+			log.Printf("Failed to record duplicate %d/%d: %v", newer, older, err)
+		}
+	}
+	return len(matches)
+}
+
+func escapeLike(s string) string {
+	return strings.NewReplacer(`\`, `\\`, "%", `\%`, "_", `\_`).Replace(s)
+}
+
+// Fingerprint items are uint32; Postgres has no unsigned type so they are stored as bigint
+func fingerprintColumn(items []uint32) pq.Int64Array {
+	out := make(pq.Int64Array, len(items))
+	for i, v := range items {
+		out[i] = int64(v)
+	}
+	return out
+}
+
+func fingerprintItems(column pq.Int64Array) []uint32 {
+	out := make([]uint32, len(column))
+	for i, v := range column {
+		out[i] = uint32(v)
+	}
+	return out
+}
+
+// ScanDuplicates is the bulk report for an existing library: it fingerprints the tracks that have
+// no fingerprint yet, then compares every track with the ones added before it. Findings land in
+// the same review queue as ingest's.
+func (w *Worker) ScanDuplicates() {
+	// 1. Fingerprint what was ingested before duplicate detection existed
+	var missing []models.Track
+	if err := w.db.DB.Where("kind = ? AND fingerprint IS NULL AND key <> ''", models.TrackKindMusic).Find(&missing).Error; err != nil {
+		log.Fatalf("Failed to fetch tracks to fingerprint: %v", err)
+	}
+	log.Printf("Fingerprinting %d tracks...", len(missing))
+
+	for _, track := range missing {
+		tempPath := filepath.Join(w.cfg.Server.TempDir, fmt.Sprintf("fingerprint_%d.raw", track.ID))
+		if err := w.downloadTo(track.Key, tempPath); err != nil {
+			log.Printf("Failed to download track %d: %v", track.ID, err)
+			continue
+		}
+
+		fp, err := audio.Fingerprint(tempPath)
+		os.Remove(tempPath)
+		if err != nil {
+			log.Printf("Could not fingerprint track %d: %v", track.ID, err)
+			continue
+		}
+		w.db.DB.Model(&track).Update("fingerprint", fingerprintColumn(fp.Items))
+	}
+
+	// 2. Compare, oldest first
+	var tracks []models.Track
+	flagged := 0
+	err := w.db.DB.Preload("Artists").Where("kind = ?", models.TrackKindMusic).
+		FindInBatches(&tracks, repairFeaturesBatch, func(tx *gorm.DB, batch int) error {
+			for _, track := range tracks {
+				var artists []string
+				for _, a := range track.Artists {
+					artists = append(artists, a.Name)
+				}
+
+				matches, err := findDuplicates(w.db.DB, track, fingerprintItems(track.Fingerprint), artists, track.Title, track.Duration, true)
+				if err != nil {
+					log.Printf("Duplicate check failed for track %d: %v", track.ID, err)
+					continue
+				}
+				flagged += recordDuplicates(w.db.DB, track, matches)
+			}
+			log.Printf("Duplicate scan: batch %d done (%d pairs so far)", batch, flagged)
+			return nil
+		}).Error
+	if err != nil {
+		log.Fatalf("Failed to scan tracks for duplicates: %v", err)
+	}
+
+	log.Printf("Duplicate scan complete: %d pairs queued for review.", flagged)
+}
+
+// downloadTo copies a stored file to a local path
+func (w *Worker) downloadTo(key, path string) error {
+	fileStream, err := w.storage.DownloadFile(key)
+	if err != nil {
+		return err
+	}
+	defer fileStream.Body.Close()
+
+	outFile, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(outFile, fileStream.Body); err != nil {
+		outFile.Close()
+		os.Remove(path)
+		return err
+	}
+	return outFile.Close()
+}
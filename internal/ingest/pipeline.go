@@ -2,6 +2,7 @@ package ingest
 
 import (
 	"context"
+	"momo-radio/internal/audio"
 	"momo-radio/internal/metadata"
 	"momo-radio/internal/models"
 )
@@ -18,6 +19,8 @@ type ProcessingContext struct {
 	Track         *models.Track
 	Meta          *metadata.Track
 	MusicBrainzID string
+	Fingerprint   []uint32        // Raw Chromaprint, empty when fpcalc failed
+	Loudness      *audio.Loudness // EBU R128 of the normalized file, nil when the measurement failed
 }
 
 type Step interface {
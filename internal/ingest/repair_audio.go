@@ -7,6 +7,8 @@ import (
 	"os"
 	"path/filepath"
 
+	"github.com/lib/pq"
+
 	"momo-radio/internal/audio"
 	"momo-radio/internal/models"
 )
@@ -68,6 +70,8 @@ func (w *Worker) RepairAudio() {
 		}
 
 		// 3. Save to Database
+		track.BPM, track.MusicalKey, track.Scale = analysis.BPM, analysis.MusicalKey, analysis.Scale
+		track.Danceability, track.Loudness = analysis.Danceability, analysis.Loudness
 		err = w.db.DB.Model(&track).Updates(map[string]interface{}{
 			"bpm":          analysis.BPM,
 			"duration":     analysis.Duration,
@@ -75,6 +79,7 @@ func (w *Worker) RepairAudio() {
 			"scale":        analysis.Scale,
 			"danceability": analysis.Danceability,
 			"loudness":     analysis.Loudness,
+			"features":     pq.Float64Array(audio.FeatureVector(track)),
 		}).Error
 
 		if err != nil {
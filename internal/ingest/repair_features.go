@@ -0,0 +1,43 @@
+package ingest
+
+import (
+	"log"
+
+	"github.com/lib/pq"
+	"gorm.io/gorm"
+
+	"momo-radio/internal/audio"
+	"momo-radio/internal/models"
+)
+
+// repairFeaturesBatch is how many tracks are loaded at a time
+const repairFeaturesBatch = 500
+
+// RepairFeatures (re)builds the similarity feature vector of every track from its stored analysis.
+// No audio is downloaded: tracks missing analysis need -repair-audio first.
+func (w *Worker) RepairFeatures() {
+	var tracks []models.Track
+	updated, skipped := 0, 0
+
+	err := w.db.DB.Model(&models.Track{}).FindInBatches(&tracks, repairFeaturesBatch, func(tx *gorm.DB, batch int) error {
+		for _, track := range tracks {
+			features := audio.FeatureVector(track)
+			if features == nil {
+				skipped++
+				continue
+			}
+			if err := w.db.DB.Model(&track).Update("features", pq.Float64Array(features)).Error; err != nil {
+				log.Printf("Failed to save features for track %d: %v", track.ID, err)
+				continue
+			}
+			updated++
+		}
+		log.Printf("Feature repair: batch %d done (%d updated so far)", batch, updated)
+		return nil
+	}).Error
+	if err != nil {
+		log.Fatalf("Failed to fetch tracks for feature repair: %v", err)
+	}
+
+	log.Printf("Feature repair complete: %d updated, %d skipped (not analysed).", updated, skipped)
+}
@@ -62,6 +62,13 @@ func (s *AnalysisStep) Execute(ctx *ProcessingContext) error {
 	// 3. Deterministic Acoustic Fingerprinting (Chromaprint / AcoustID)
 	ctx.Worker.updateStatus(ctx.Ctx, ctx.Payload.TrackIDStr(), "fingerprinting", 50)
 
+	// The raw fingerprint is kept for duplicate detection; AcoustID wants the compressed one
+	if fp, err := audio.Fingerprint(ctx.RawPath); err != nil {
+		log.Printf("Raw fingerprint failed for track %d: %v", ctx.Payload.TrackID, err)
+	} else {
+		ctx.Fingerprint = fp.Items
+	}
+
 	mbid, err := audio.GetMusicBrainzID(ctx.RawPath, ctx.Worker.cfg.Services.AcoustIDKey)
 	if err != nil {
 		log.Printf("Acoustic fingerprinting skipped/failed for track %d: %v", ctx.Payload.TrackID, err)
@@ -84,3 +91,22 @@ func (s *NormalizeStep) Name() string { return "normalizing" }
 func (s *NormalizeStep) Execute(ctx *ProcessingContext) error {
 	return audio.Normalize(ctx.RawPath, ctx.CleanPath)
 }
+
+// -----------------------------------------------------------------------------
+// LOUDNESS STEP
+// -----------------------------------------------------------------------------
+type LoudnessStep struct{}
+
+func (s *LoudnessStep) Name() string { return "measuring" }
+
+// Execute measures the file that goes on air; the playout compensates from it. Not fatal: an unmeasured
+// track just plays without compensation.
+func (s *LoudnessStep) Execute(ctx *ProcessingContext) error {
+	l, err := audio.MeasureLoudness(ctx.CleanPath)
+	if err != nil {
+		log.Printf("Loudness measurement failed for track %d: %v", ctx.Payload.TrackID, err)
+		return nil
+	}
+	ctx.Loudness = &l
+	return nil
+}
@@ -8,6 +8,8 @@ import (
 	"github.com/lib/pq"
 	"gorm.io/gorm"
 
+	"momo-radio/internal/audio"
+	database "momo-radio/internal/db"
 	"momo-radio/internal/metadata"
 	"momo-radio/internal/models"
 )
@@ -97,6 +99,9 @@ func (s *DatabaseSaveStep) Execute(ctx *ProcessingContext) error {
 			}
 			if len(updates) > 0 {
 				db.Model(&album).Updates(updates)
+				if _, labelled := updates["publisher"]; labelled {
+					database.RefreshAlbumSearch(db, album.ID)
+				}
 			}
 		}
 
@@ -126,7 +131,18 @@ func (s *DatabaseSaveStep) Execute(ctx *ProcessingContext) error {
 	}
 
 	// 5. Finalize Track Updates
-	db.Model(track).Updates(map[string]interface{}{
+	features := audio.FeatureVector(models.Track{
+		BPM:               meta.BPM,
+		MusicalKey:        meta.MusicalKey,
+		Scale:             meta.Scale,
+		Danceability:      meta.Danceability,
+		Loudness:          meta.Loudness,
+		Energy:            meta.Energy,
+		MLMoods:           meta.MLMoods,
+		MLGenres:          meta.MLGenres,
+		MLCharacteristics: meta.MLCharacteristics,
+	})
+	updates := map[string]interface{}{
 		"key":                 ctx.DestKey,
 		"title":               meta.Title,
 		"album_id":            albumID,
@@ -143,10 +159,21 @@ func (s *DatabaseSaveStep) Execute(ctx *ProcessingContext) error {
 		"ml_moods":            pq.StringArray(meta.MLMoods),
 		"ml_genres":           pq.StringArray(meta.MLGenres),
 		"ml_characteristics":  pq.StringArray(meta.MLCharacteristics),
+		"features":            pq.Float64Array(features),
 		"processing_status":   "completed",
 		"processing_progress": 100,
-	})
+	}
+	if ctx.Loudness != nil {
+		updates["integrated_lufs"] = &ctx.Loudness.Integrated
+		updates["true_peak"] = &ctx.Loudness.TruePeak
+	}
+	db.Model(track).Updates(updates)
 
 	// Save the Many-to-Many associations explicitly
-	return db.Save(track).Error
+	if err := db.Save(track).Error; err != nil {
+		return err
+	}
+
+	// The search document needs the artists saved above
+	return database.RefreshTrackSearch(db, track.ID)
 }
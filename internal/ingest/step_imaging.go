@@ -0,0 +1,82 @@
+package ingest
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"momo-radio/internal/audio"
+	"momo-radio/internal/metadata"
+)
+
+// Station imaging (jingles, IDs, sweepers, promos) skips tagging, deep analysis and
+// enrichment: it only needs a loudness-matched file and its duration.
+
+// -----------------------------------------------------------------------------
+// IMAGING PROBE STEP
+// -----------------------------------------------------------------------------
+type ImagingProbeStep struct{}
+
+func (s *ImagingProbeStep) Name() string { return "analyzing" }
+
+func (s *ImagingProbeStep) Execute(ctx *ProcessingContext) error {
+	duration, err := audio.ProbeDuration(ctx.RawPath)
+	if err != nil {
+		return fmt.Errorf("invalid audio file format")
+	}
+
+	ctx.Meta = &metadata.Track{Title: ctx.Track.Title, Duration: duration}
+	return nil
+}
+
+// -----------------------------------------------------------------------------
+// IMAGING UPLOAD STEP
+// -----------------------------------------------------------------------------
+type ImagingUploadStep struct{}
+
+func (s *ImagingUploadStep) Name() string { return "uploading" }
+
+func (s *ImagingUploadStep) Execute(ctx *ProcessingContext) error {
+	baseName := filepath.Base(ctx.Payload.FileKey)
+	nameWithoutExt := strings.TrimSuffix(baseName, filepath.Ext(baseName))
+
+	ctx.DestKey = fmt.Sprintf("imaging/%s/%s/%s_%d.mp3", ctx.OrgID, ctx.Track.Kind, nameWithoutExt, ctx.Payload.TrackID)
+
+	fClean, err := os.Open(ctx.CleanPath)
+	if err != nil {
+		return err
+	}
+	defer fClean.Close()
+
+	return ctx.Worker.storage.UploadAssetFile(ctx.DestKey, fClean, "audio/mpeg", "public, max-age=31536000")
+}
+
+// -----------------------------------------------------------------------------
+// IMAGING SAVE STEP
+// -----------------------------------------------------------------------------
+type ImagingSaveStep struct{}
+
+func (s *ImagingSaveStep) Name() string { return "saving" }
+
+func (s *ImagingSaveStep) Execute(ctx *ProcessingContext) error {
+	title := ctx.Meta.Title
+	if title == "" {
+		baseName := filepath.Base(ctx.Payload.FileKey)
+		title = strings.TrimSuffix(baseName, filepath.Ext(baseName))
+	}
+
+	updates := map[string]interface{}{
+		"key":                 ctx.DestKey,
+		"title":               title,
+		"format":              "mp3",
+		"duration":            ctx.Meta.Duration,
+		"processing_status":   "completed",
+		"processing_progress": 100,
+	}
+	if ctx.Loudness != nil {
+		updates["integrated_lufs"] = &ctx.Loudness.Integrated
+		updates["true_peak"] = &ctx.Loudness.TruePeak
+	}
+	return ctx.Worker.db.DB.Model(ctx.Track).Updates(updates).Error
+}
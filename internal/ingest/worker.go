@@ -25,6 +25,7 @@ import (
 const TypeTrackProcess = "track:process"
 const TypeTrackEnrich = "track:enrich"
 const TypeArtistEnrich = "artist:enrich"
+const TypeImagingProcess = "imaging:process"
 
 type TrackEnrichPayload struct {
 	TrackID       uint   `json:"track_id"`
@@ -101,6 +102,37 @@ func New(cfg *config.Config, store *storage.Client, db *database.Client, redisCl
 // ============================================================================
 
 func (w *Worker) HandleProcessTask(ctx context.Context, t *asynq.Task) error {
+	return w.runPipeline(ctx, t, []Step{
+		&SetupStep{},
+		&DownloadStep{},
+		&VaultStep{},
+		&AnalysisStep{},
+		&DuplicateCheckStep{},
+		&WaveformStep{},
+		&NormalizeStep{},
+		&LoudnessStep{},
+		&UploadStep{},
+		&DatabaseSaveStep{},
+		&EnrichStep{},
+	})
+}
+
+// HandleImagingTask processes jingles, station IDs, sweepers and promos.
+// Same payload as track:process, lighter pipeline.
+func (w *Worker) HandleImagingTask(ctx context.Context, t *asynq.Task) error {
+	return w.runPipeline(ctx, t, []Step{
+		&SetupStep{},
+		&DownloadStep{},
+		&VaultStep{},
+		&ImagingProbeStep{},
+		&NormalizeStep{},
+		&LoudnessStep{},
+		&ImagingUploadStep{},
+		&ImagingSaveStep{},
+	})
+}
+
+func (w *Worker) runPipeline(ctx context.Context, t *asynq.Task, steps []Step) error {
 	timer := prometheus.NewTimer(duration)
 	defer timer.ObserveDuration()
 
@@ -118,18 +150,6 @@ func (w *Worker) HandleProcessTask(ctx context.Context, t *asynq.Task) error {
 		Payload: payload,
 	}
 
-	steps := []Step{
-		&SetupStep{},
-		&DownloadStep{},
-		&VaultStep{},
-		&AnalysisStep{},
-		&WaveformStep{},
-		&NormalizeStep{},
-		&UploadStep{},
-		&DatabaseSaveStep{},
-		&EnrichStep{},
-	}
-
 	for _, step := range steps {
 		progress := (indexOf(steps, step) * 100) / len(steps)
 		w.updateStatus(ctx, payload.TrackIDStr(), step.Name(), progress)
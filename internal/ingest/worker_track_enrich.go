@@ -10,6 +10,7 @@ import (
 
 	"github.com/hibiken/asynq"
 
+	database "momo-radio/internal/db"
 	"momo-radio/internal/metadata"
 	"momo-radio/internal/models"
 )
@@ -179,6 +180,10 @@ func (w *Worker) HandleTrackEnrichTask(ctx context.Context, t *asynq.Task) error
 
 		if len(albumUpdates) > 0 {
 			w.db.DB.Model(&album).Updates(albumUpdates)
+			// The label is part of the search document of every track on the album
+			if _, labelled := albumUpdates["publisher"]; labelled {
+				database.RefreshAlbumSearch(w.db.DB, album.ID)
+			}
 		}
 	}
 
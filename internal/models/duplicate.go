@@ -0,0 +1,40 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Duplicate review states
+const (
+	DuplicatePending  = "pending"  // Waiting for an editor
+	DuplicateMerged   = "merged"   // The newer track was folded into the original
+	DuplicateKept     = "kept"     // Not a duplicate after all, both stay
+	DuplicateRejected = "rejected" // The newer track was removed
+)
+
+// Why a track was flagged
+const (
+	DuplicateReasonFingerprint = "fingerprint" // The audio matches
+	DuplicateReasonMetadata    = "metadata"    // Same normalized artist and title
+	DuplicateReasonBoth        = "both"
+)
+
+// TrackDuplicate flags a track that looks like one already in the library, for an editor to review
+type TrackDuplicate struct {
+	ID             uint      `gorm:"primaryKey" json:"id"`
+	OrganizationID uuid.UUID `gorm:"type:uuid;not null;index" json:"organization_id"`
+
+	TrackID       uint  `gorm:"not null;uniqueIndex:idx_duplicate_pair" json:"track_id"` // The later upload
+	Track         Track `json:"-"`
+	DuplicateOfID uint  `gorm:"not null;uniqueIndex:idx_duplicate_pair" json:"duplicate_of_id"`
+	DuplicateOf   Track `json:"-"`
+
+	Similarity float64 `json:"similarity"` // Fingerprint match 0..1, 0 when only the metadata matched
+	Reason     string  `gorm:"type:varchar(20);not null" json:"reason"`
+
+	Status     string     `gorm:"type:varchar(20);not null;default:'pending';index" json:"status"`
+	ResolvedAt *time.Time `json:"resolved_at"`
+	CreatedAt  time.Time  `json:"created_at"`
+}
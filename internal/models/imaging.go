@@ -0,0 +1,59 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Track kinds. Everything except music is station imaging and never picked by the AutoDJ selectors.
+const (
+	TrackKindMusic     = "music"
+	TrackKindJingle    = "jingle"
+	TrackKindStationID = "station_id"
+	TrackKindSweeper   = "sweeper"
+	TrackKindPromo     = "promo"
+	// TrackKindFallback is the emergency loop aired over dead air; insertion rules never pick it
+	TrackKindFallback = "fallback"
+)
+
+// IsImagingKind reports whether kind is one of the station imaging kinds
+func IsImagingKind(kind string) bool {
+	switch kind {
+	case TrackKindJingle, TrackKindStationID, TrackKindSweeper, TrackKindPromo, TrackKindFallback:
+		return true
+	}
+	return false
+}
+
+// Insertion triggers
+const (
+	TriggerInterval  = "interval"     // Every Value minutes
+	TriggerEveryN    = "every_tracks" // Between every Value songs
+	TriggerTopOfHour = "top_of_hour"  // First song boundary of each hour, at most Value minutes late
+)
+
+// InsertionRule plays an imaging asset of Kind between songs when its trigger is due.
+// A rule without RuleSetID/ScheduleSlotID applies station-wide; otherwise only while
+// that rule set or slot is on air.
+type InsertionRule struct {
+	ID             uint      `gorm:"primaryKey" json:"id"`
+	OrganizationID uuid.UUID `gorm:"type:uuid;index;not null" json:"organization_id"`
+	CreatedAt      time.Time `json:"created_at"`
+	UpdatedAt      time.Time `json:"updated_at"`
+
+	Name    string `gorm:"type:varchar(255)" json:"name"`
+	Enabled bool   `gorm:"default:true" json:"enabled"`
+
+	// --- Scope ---
+	RuleSetID      *uint `gorm:"index" json:"ruleset_id"`
+	ScheduleSlotID *uint `gorm:"index" json:"schedule_slot_id"`
+
+	// --- What and when ---
+	Kind    string `gorm:"type:varchar(20);not null" json:"kind"`
+	Trigger string `gorm:"type:varchar(20);not null" json:"trigger"`
+	Value   int    `gorm:"not null;default:0" json:"value"`
+
+	// TalkOver plays the asset over the intro of the next song, ducking the music underneath
+	TalkOver bool `gorm:"default:false" json:"talk_over"`
+}
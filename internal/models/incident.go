@@ -0,0 +1,38 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Pipeline stages the watchdog restarts
+const (
+	StageEncoder  = "encoder"  // The HLS ffmpeg of a mount
+	StageUploader = "uploader" // The segment/playlist upload loop of a mount
+	StagePlayout  = "playout"  // The mixed audio going out to every mount
+)
+
+// What went wrong
+const (
+	IncidentExit           = "exit"            // The encoder exited on its own
+	IncidentStall          = "stall"           // No new segment for too long while audio was fed
+	IncidentUploadFailures = "upload_failures" // Uploads kept failing
+	IncidentDeadAir        = "dead_air"        // The outgoing audio stayed silent
+)
+
+// PipelineIncident records one automatic restart of a stage of a tenant's broadcast pipeline, or a
+// stretch of dead air. ResolvedAt is set once the stage produces (or uploads, or sounds) again.
+type PipelineIncident struct {
+	ID             uint      `gorm:"primaryKey" json:"id"`
+	OrganizationID uuid.UUID `gorm:"type:uuid;not null;index:idx_incident_org_time" json:"organization_id"`
+
+	Mount   string `gorm:"type:varchar(50);not null" json:"mount"`
+	Stage   string `gorm:"type:varchar(20);not null" json:"stage"`
+	Kind    string `gorm:"type:varchar(20);not null" json:"kind"`
+	Detail  string `json:"detail"`
+	Attempt int    `json:"attempt"` // Restarts of this stage since the pipeline started
+
+	CreatedAt  time.Time  `gorm:"index:idx_incident_org_time" json:"created_at"`
+	ResolvedAt *time.Time `json:"resolved_at"`
+}
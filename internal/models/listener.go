@@ -0,0 +1,33 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// ListenerSession is one listener tuned into a mount, from first request to last heartbeat.
+// Raw IP addresses are never stored, only a hash used to count unique listeners.
+type ListenerSession struct {
+	ID             uint       `gorm:"primaryKey" json:"id"`
+	OrganizationID uuid.UUID  `gorm:"type:uuid;index;not null" json:"organization_id"`
+	Mount          string     `gorm:"type:varchar(50);index" json:"mount"`
+	Source         string     `gorm:"type:varchar(20)" json:"source"` // hls, beacon, icecast
+	ListenerHash   string     `gorm:"type:varchar(64);index" json:"-"`
+	Country        string     `gorm:"type:varchar(2);index" json:"country"`
+	UserAgent      string     `gorm:"type:varchar(255)" json:"user_agent"`
+	StartedAt      time.Time  `gorm:"index" json:"started_at"`
+	LastSeenAt     time.Time  `json:"last_seen_at"`
+	EndedAt        *time.Time `gorm:"index" json:"ended_at"`
+}
+
+// ListenerSample is the number of concurrent listeners on a mount during one sampling interval.
+// Rows from several engine instances are summed per bucket.
+type ListenerSample struct {
+	ID               uint      `gorm:"primaryKey" json:"-"`
+	OrganizationID   uuid.UUID `gorm:"type:uuid;index:idx_listener_samples_org_bucket;not null" json:"organization_id"`
+	BucketStart      time.Time `gorm:"index:idx_listener_samples_org_bucket" json:"bucket_start"`
+	Mount            string    `gorm:"type:varchar(50)" json:"mount"`
+	Listeners        int       `json:"listeners"`
+	ListeningSeconds float64   `json:"listening_seconds"`
+}
@@ -37,19 +37,50 @@ type ScheduleSlot struct {
 	UpdatedAt      time.Time      `json:"updated_at"`
 	DeletedAt      gorm.DeletedAt `gorm:"index" json:"-"`
 
+	Name string `json:"name" gorm:"type:varchar(255)"` // Optional show title, defaults to the playlist/RuleSet name
+
 	ScheduleType string `json:"schedule_type" gorm:"not null;default:'one_time'"`
 	Date         string `json:"date"`
 	Days         string `json:"days" gorm:"not null;default:'Mon,Tue,Wed,Thu,Fri,Sat,Sun'"`
 
+	// Recurring slots only air between these dates (inclusive, "2006-01-02"). Empty means open-ended.
+	StartDate string `json:"start_date" gorm:"type:varchar(10)"`
+	EndDate   string `json:"end_date" gorm:"type:varchar(10)"`
+
 	IsActive bool `json:"is_active" gorm:"default:true"`
 	IsReplay bool `json:"is_replay" gorm:"default:false"`
 
 	StartTime string `json:"start_time" gorm:"type:varchar(5);not null"`
 	EndTime   string `json:"end_time" gorm:"type:varchar(5);not null"`
 
+	// How the show takes over from the one before it: "boundary" waits for the current track to end,
+	// "hard" fades it out on the hour, "soft" picks earlier tracks so they end close to StartTime.
+	// The same mode applies when the show ends into the fallback AutoDJ.
+	StartMode string `json:"start_mode" gorm:"type:varchar(10);not null;default:'boundary'"`
+	// Soft starts may land this many seconds early or late. 0 uses the default.
+	FitToleranceSeconds int `json:"fit_tolerance_seconds" gorm:"not null;default:0"`
+
 	PlaylistID *uint     `json:"playlist_id" gorm:"index"`
 	Playlist   *Playlist `json:"playlist"`
+	// Playlist order: sequential, shuffle_once, shuffle_each_loop, or play_once (then the RuleSet takes over)
+	PlaybackMode string `json:"playback_mode" gorm:"type:varchar(20);not null;default:'sequential'"`
 
 	RuleSetID *uint    `json:"ruleset_id" gorm:"index"`
 	RuleSet   *RuleSet `json:"ruleset"`
 }
+
+// PlaylistCursor is how far a playlist show got in one airing of its slot, so it resumes
+// after restarts and detours instead of starting over. Replay slots keep a single cursor
+// across airings and carry on where the previous one stopped.
+type PlaylistCursor struct {
+	ID             uint      `gorm:"primaryKey" json:"id"`
+	OrganizationID uuid.UUID `gorm:"type:uuid;not null;uniqueIndex:idx_cursor_occurrence" json:"organization_id"`
+	ScheduleSlotID uint      `gorm:"not null;uniqueIndex:idx_cursor_occurrence" json:"schedule_slot_id"`
+	Occurrence     string    `gorm:"type:varchar(20);not null;uniqueIndex:idx_cursor_occurrence" json:"occurrence"` // Airing start "2006-01-02T15:04", or "replay"
+
+	PlaylistID uint  `gorm:"not null" json:"playlist_id"`
+	Position   int   `gorm:"not null;default:0" json:"position"` // Next position to air, counting across loops
+	Seed       int64 `gorm:"not null" json:"-"`                  // Shuffle order, fixed for the cursor's lifetime
+
+	UpdatedAt time.Time `json:"updated_at"`
+}
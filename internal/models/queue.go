@@ -0,0 +1,36 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Queue item sources
+const (
+	QueueSourceAutoDJ  = "autodj"  // Filled ahead of time by the selector of the slot on air
+	QueueSourceRequest = "request" // Inserted by an editor
+)
+
+// QueueItem is an upcoming track in a tenant's playout queue. The lowest position plays next.
+type QueueItem struct {
+	ID             uint      `gorm:"primaryKey" json:"id"`
+	OrganizationID uuid.UUID `gorm:"type:uuid;not null;index:idx_queue_org_position" json:"organization_id"`
+	Position       int       `gorm:"not null;index:idx_queue_org_position" json:"position"`
+
+	TrackID uint  `gorm:"not null" json:"track_id"`
+	Track   Track `json:"track"`
+
+	Source string `gorm:"type:varchar(20);not null" json:"source"`
+	// Pinned items survive schedule changes; unpinned AutoDJ picks are re-selected when the show changes
+	Pinned bool `gorm:"not null" json:"pinned"`
+
+	SlotKey   string `gorm:"type:varchar(50)" json:"-"` // Which schedule slot picked it (AutoDJ items)
+	RuleSetID *uint  `json:"rule_set_id"`
+
+	// Playlist shows: the cursor position this item was taken from, committed once it airs
+	CursorID      *uint `gorm:"index" json:"-"`
+	PlaylistIndex *int  `json:"playlist_index,omitempty"`
+
+	CreatedAt time.Time `json:"created_at"`
+}
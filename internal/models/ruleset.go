@@ -9,17 +9,17 @@ import (
 
 // RuleSet defines the criteria for intelligent AutoDJ selection.
 type RuleSet struct {
-	OrganizationID uuid.UUID      `gorm:"type:uuid;index;not null" json:"organization_id"`
+	OrganizationID uuid.UUID      `gorm:"type:uuid;index;not null;uniqueIndex:idx_org_ruleset_name,where:deleted_at IS NULL" json:"organization_id"`
 	ID             uint           `gorm:"primaryKey" json:"id"`
 	CreatedAt      time.Time      `json:"created_at"`
 	UpdatedAt      time.Time      `json:"updated_at"`
 	DeletedAt      gorm.DeletedAt `gorm:"index" json:"-"`
 
 	// --- Metadata ---
-	Name string `gorm:"type:varchar(255);not null;uniqueIndex" json:"name"` // e.g., "Deep House Peak Hour"
+	Name string `gorm:"type:varchar(255);not null;uniqueIndex:idx_org_ruleset_name,where:deleted_at IS NULL" json:"name"` // e.g., "Deep House Peak Hour"
 
 	// --- Selection Logic ---
-	// Mode determines the algorithm: "starvation", "harmonic", "random"
+	// Mode determines the algorithm: "starvation", "harmonic", "random", "curve"
 	Mode string `gorm:"type:varchar(50);default:'starvation'" json:"mode"`
 
 	// --- Criteria Filters ---
@@ -33,7 +33,60 @@ type RuleSet struct {
 	MinYear int `gorm:"type:int;default:0" json:"min_year"`
 	MaxYear int `gorm:"type:int;default:0" json:"max_year"`
 
+	// Structured criteria on top of the simple ones above (ML tags, energy, label, ...)
+	Filter RuleFilter `gorm:"serializer:json;type:jsonb" json:"filter"`
+
+	// Intensity over the course of a slot, followed by the "curve" mode
+	Curve []CurvePoint `gorm:"serializer:json;type:jsonb" json:"curve"`
+
+	// Anti-repetition while this rule set is on air. NULL inherits the station's policy.
+	Separation *Separation `gorm:"serializer:json;type:jsonb" json:"separation"`
+
+	// --- Transitions ---
+	// Overrides the mount point crossfade while this rule set is on air. NULL/empty inherits.
+	CrossfadeSeconds *float64 `gorm:"type:numeric(4,2)" json:"crossfade_seconds"`
+	CrossfadeCurve   string   `gorm:"type:varchar(20)" json:"crossfade_curve"`
+
 	// --- Relationships ---
 	// One RuleSet can be assigned to multiple calendar slots
 	Schedules []Schedule `gorm:"foreignKey:RuleSetID" json:"schedules,omitempty"`
 }
+
+// FilterList matches tracks with any of Include (when set) and none of Exclude. Case-insensitive.
+type FilterList struct {
+	Include []string `json:"include,omitempty"`
+	Exclude []string `json:"exclude,omitempty"`
+}
+
+// RuleFilter is the structured filter expression of a RuleSet. Zero values do not filter.
+type RuleFilter struct {
+	Styles            FilterList `json:"styles"`
+	MLMoods           FilterList `json:"ml_moods"`
+	MLGenres          FilterList `json:"ml_genres"`
+	MLCharacteristics FilterList `json:"ml_characteristics"`
+	ArtistCountries   FilterList `json:"artist_countries"`
+	Labels            FilterList `json:"labels"` // Album publisher
+
+	MinEnergy       *float64 `json:"min_energy,omitempty"`
+	MaxEnergy       *float64 `json:"max_energy,omitempty"`
+	MinDanceability *float64 `json:"min_danceability,omitempty"`
+	MaxDanceability *float64 `json:"max_danceability,omitempty"`
+
+	AddedAfter   string `json:"added_after,omitempty"` // "2006-01-02", by upload date
+	MaxPlayCount *int   `json:"max_play_count,omitempty"`
+}
+
+// Separation is the minimum time, in minutes, before the same track, artist, album or label airs again. 0 disables a rule.
+type Separation struct {
+	TrackMinutes  int `json:"track_minutes"`
+	ArtistMinutes int `json:"artist_minutes"`
+	AlbumMinutes  int `json:"album_minutes"`
+	LabelMinutes  int `json:"label_minutes"`
+}
+
+// CurvePoint is a target at a point of the slot. Values in between are interpolated.
+type CurvePoint struct {
+	At     float64  `json:"at"`               // 0 = slot start, 1 = slot end
+	Energy *float64 `json:"energy,omitempty"` // 0 = calmest, 1 = most energetic track in the library
+	BPM    *float64 `json:"bpm,omitempty"`
+}
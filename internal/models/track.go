@@ -67,6 +67,7 @@ type Track struct {
 	Key       string `gorm:"uniqueIndex;not null" json:"key"`
 	MasterKey string `gorm:"type:text;not null" json:"master_key"`
 	Title     string `gorm:"index" json:"title"`
+	Kind      string `gorm:"type:varchar(20);default:'music';index" json:"kind"` // music, or an imaging kind (jingle, station_id, ...)
 
 	ProcessingStatus   string `gorm:"default:'pending';index" json:"processing_status"`
 	ProcessingProgress int    `gorm:"default:0" json:"processing_progress"`
@@ -95,12 +96,25 @@ type Track struct {
 	Loudness     float64 `json:"loudness"`
 	WaveformKey  string  `gorm:"size:512" json:"waveform_key"`
 
+	// EBU R128 of the file on air; the playout turns it to the mount's target. NULL: not measured yet.
+	IntegratedLUFS *float64 `gorm:"column:integrated_lufs" json:"integrated_lufs"`
+	TruePeak       *float64 `json:"true_peak"` // dBTP
+
 	// Mood
 	Energy            float64        `json:"energy"`
 	MLMoods           pq.StringArray `gorm:"type:text[]" json:"ml_moods"`
 	MLGenres          pq.StringArray `gorm:"type:text[]" json:"ml_genres"`
 	MLCharacteristics pq.StringArray `gorm:"type:text[]" json:"ml_characteristics"`
 
+	// Similarity: audio.FeatureVector of the analysis above, compared by Euclidean distance
+	Features pq.Float64Array `gorm:"type:double precision[]" json:"-"`
+
+	// Raw Chromaprint (uint32 items) for duplicate detection, see audio.FingerprintSimilarity
+	Fingerprint pq.Int64Array `gorm:"type:bigint[]" json:"-"`
+
+	// Full-text search over title, artists, album and label; written only by database.RefreshTrackSearch
+	SearchVector string `gorm:"type:tsvector;index:idx_tracks_search,type:gin;->:false" json:"-"`
+
 	// Radio Logic
 	PlayCount  int        `gorm:"default:0" json:"play_count"`
 	LastPlayed *time.Time `gorm:"index" json:"last_played"`
@@ -113,4 +127,12 @@ type PlayHistory struct {
 	TrackID        uint
 	Track          Track
 	PlayedAt       time.Time `gorm:"index"`
+
+	// --- Audience Retention ---
+	// Filled in once listener sessions around the play have settled; NULL means not measured yet.
+	RuleSetID      *uint      `gorm:"index" json:"rule_set_id"`
+	EndedAt        *time.Time `json:"ended_at"`
+	ListenersStart *int       `json:"listeners_start"`
+	ListenersEnd   *int       `json:"listeners_end"`
+	TuneOuts       *int       `json:"tune_outs"` // Of the listeners at start, those who left during the play
 }
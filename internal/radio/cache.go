@@ -5,6 +5,7 @@ import (
 	"log"
 	"os"
 	"path/filepath"
+	"strings"
 	"sync"
 	"time"
 )
@@ -19,6 +20,7 @@ type CacheManager struct {
 	baseDir string
 	mu      sync.Mutex
 	pending map[string]chan struct{}
+	keep    map[string][]string // Keys each owner (tenant) still needs. Key: owner
 }
 
 func NewCacheManager(storage StorageProvider, tmpDir string) *CacheManager {
@@ -31,6 +33,7 @@ func NewCacheManager(storage StorageProvider, tmpDir string) *CacheManager {
 		storage: storage,
 		baseDir: cacheDir,
 		pending: make(map[string]chan struct{}),
+		keep:    make(map[string][]string),
 	}
 }
 
@@ -83,12 +86,18 @@ func (c *CacheManager) Prefetch(keys []string) {
 	}
 }
 
-// Cleanup removes files not in the keepList
-func (c *CacheManager) Cleanup(keepKeys []string) {
+// Cleanup records the keys owner still needs (on air + queued) and removes
+// every cached file that no owner needs anymore.
+func (c *CacheManager) Cleanup(owner string, keepKeys []string) {
+	c.mu.Lock()
+	c.keep[owner] = keepKeys
 	keepMap := make(map[string]bool)
-	for _, k := range keepKeys {
-		keepMap[c.filePath(k)] = true
+	for _, keys := range c.keep {
+		for _, k := range keys {
+			keepMap[c.filePath(k)] = true
+		}
 	}
+	c.mu.Unlock()
 
 	files, err := os.ReadDir(c.baseDir)
 	if err != nil {
@@ -97,14 +106,23 @@ func (c *CacheManager) Cleanup(keepKeys []string) {
 
 	for _, file := range files {
 		fullPath := filepath.Join(c.baseDir, file.Name())
+		// In-flight downloads are renamed into place when done
+		if strings.HasSuffix(fullPath, ".tmp") {
+			continue
+		}
 		if !keepMap[fullPath] {
-			// Check age? Or just aggressive cleanup?
-			// For now, simple cleanup of anything not in the current playlist
 			os.Remove(fullPath)
 		}
 	}
 }
 
+// Release forgets what owner needed, e.g. when its pipeline stops
+func (c *CacheManager) Release(owner string) {
+	c.mu.Lock()
+	delete(c.keep, owner)
+	c.mu.Unlock()
+}
+
 func (c *CacheManager) filePath(key string) string {
 	// Simple hashing or sanitization to make key safe for filesystem
 	safeName := filepath.Base(key)
@@ -0,0 +1,244 @@
+package radio
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"math"
+	"os"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/redis/go-redis/v9"
+
+	"momo-radio/internal/models"
+)
+
+// Several engines share the tenants: each tenant's pipeline runs on the node holding its lease in Redis.
+// Nodes renew their leases on every heartbeat; when a node dies its leases expire and the others
+// claim the orphaned tenants, each up to a fair share of the cluster.
+const (
+	leaseKeyPrefix = "radio:lease:" // + org ID -> owning node ID
+	nodeKeyPrefix  = "radio:node:"  // + node ID -> tenants owned, expires with the node
+	// rebalanceEvery is how many heartbeats pass between two rebalancing steps
+	rebalanceEvery = 6
+	// rebalanceBatch caps how many tenants one step hands over; each one restarts its stream on another node
+	rebalanceBatch = 3
+)
+
+var (
+	// acquireLease takes a free lease, or re-takes one this node already holds (same node_id after a restart)
+	acquireLease = redis.NewScript(`
+local owner = redis.call("GET", KEYS[1])
+if owner == false or owner == ARGV[1] then
+	return redis.call("SET", KEYS[1], ARGV[1], "PX", ARGV[2])
+end
+return false`)
+	renewLease = redis.NewScript(`
+if redis.call("GET", KEYS[1]) == ARGV[1] then
+	return redis.call("PEXPIRE", KEYS[1], ARGV[2])
+end
+return 0`)
+	releaseLease = redis.NewScript(`
+if redis.call("GET", KEYS[1]) == ARGV[1] then
+	return redis.call("DEL", KEYS[1])
+end
+return 0`)
+)
+
+// tenantRun is one local pipeline of a tenant
+type tenantRun struct {
+	cancel context.CancelFunc
+}
+
+func defaultNodeID(runID int64) string {
+	host, err := os.Hostname()
+	if err != nil {
+		host = "engine"
+	}
+	return fmt.Sprintf("%s-%d", host, runID)
+}
+
+func (e *Engine) leaseTTL() time.Duration {
+	if e.cfg.Radio.LeaseTTLSeconds > 0 {
+		return time.Duration(e.cfg.Radio.LeaseTTLSeconds) * time.Second
+	}
+	return 15 * time.Second
+}
+
+func leaseKey(orgID uuid.UUID) string { return leaseKeyPrefix + orgID.String() }
+
+// runCluster is the heartbeat: renew leases, drop the lost ones, claim orphans and rebalance. Returns with ctx.
+func (e *Engine) runCluster(ctx context.Context) {
+	ticker := time.NewTicker(e.leaseTTL() / 3)
+	defer ticker.Stop()
+
+	for beat := 0; ; beat++ {
+		e.heartbeat(ctx)
+		if !e.draining.Load() {
+			e.reconcile(ctx, beat%rebalanceEvery == rebalanceEvery-1)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// heartbeat keeps this node registered and its leases alive. A lease that could not be renewed
+// belongs to another node by now (we stalled past the TTL): stop streaming at once.
+func (e *Engine) heartbeat(ctx context.Context) {
+	ttl := e.leaseTTL()
+	owned := e.ownedTenants()
+
+	if !e.draining.Load() {
+		e.rdb.Set(ctx, nodeKeyPrefix+e.nodeID, len(owned), ttl)
+	}
+
+	for _, orgID := range owned {
+		renewed, err := renewLease.Run(ctx, e.rdb, []string{leaseKey(orgID)}, e.nodeID, ttl.Milliseconds()).Int()
+		if err != nil {
+			log.Printf("[%s] Lease renewal failed, keeping the stream until the lease expires: %v", orgID, err)
+			continue
+		}
+		if renewed == 0 {
+			log.Printf("[%s] ⚠️ Lease lost to another node, stopping local pipeline", orgID)
+			e.stopLocal(orgID)
+		}
+	}
+}
+
+// reconcile stops tenants that should no longer run, claims unowned ones while this node is under
+// its fair share and, when rebalance is set, hands a few tenants over if it is above it
+func (e *Engine) reconcile(ctx context.Context, rebalance bool) {
+	desired, err := e.desiredTenants()
+	if err != nil {
+		log.Printf("Cluster: failed to list tenants: %v", err)
+		return
+	}
+
+	wanted := make(map[uuid.UUID]bool, len(desired))
+	for _, orgID := range desired {
+		wanted[orgID] = true
+	}
+	for _, orgID := range e.ownedTenants() {
+		if !wanted[orgID] {
+			log.Printf("[%s] No longer on air (stopped or no default mount), releasing", orgID)
+			e.releaseTenant(orgID)
+		}
+	}
+
+	share := fairShare(len(desired), e.liveNodes(ctx))
+	owned := len(e.ownedTenants())
+
+	for _, orgID := range desired {
+		if owned >= share {
+			break
+		}
+		if e.isRunning(orgID) {
+			continue
+		}
+		if e.claim(ctx, orgID) {
+			owned++
+		}
+	}
+
+	if rebalance && owned > share {
+		surplus := e.ownedTenants()
+		surplus = surplus[:min(owned-share, rebalanceBatch, len(surplus))]
+		log.Printf("Rebalancing: node %s runs %d tenants, fair share is %d, handing %d over", e.nodeID, owned, share, len(surplus))
+		for _, orgID := range surplus {
+			e.releaseTenant(orgID)
+		}
+	}
+}
+
+// fairShare is how many tenants one node should run
+func fairShare(tenants, nodes int) int {
+	if nodes < 1 {
+		nodes = 1
+	}
+	return int(math.Ceil(float64(tenants) / float64(nodes)))
+}
+
+// claim takes the tenant's lease and starts its pipeline here; false when another node holds it
+func (e *Engine) claim(ctx context.Context, orgID uuid.UUID) bool {
+	if e.draining.Load() {
+		return false
+	}
+	err := acquireLease.Run(ctx, e.rdb, []string{leaseKey(orgID)}, e.nodeID, e.leaseTTL().Milliseconds()).Err()
+	if err == redis.Nil {
+		return false
+	}
+	if err != nil {
+		log.Printf("[%s] Lease acquisition failed: %v", orgID, err)
+		return false
+	}
+
+	log.Printf("[%s] Lease acquired by node %s", orgID, e.nodeID)
+	e.startLocal(ctx, orgID)
+	return true
+}
+
+// releaseTenant stops the local pipeline and frees the lease so another node can pick the tenant up
+func (e *Engine) releaseTenant(orgID uuid.UUID) {
+	e.stopLocal(orgID)
+	if err := releaseLease.Run(context.Background(), e.rdb, []string{leaseKey(orgID)}, e.nodeID).Err(); err != nil {
+		log.Printf("[%s] Lease release failed, it will expire on its own: %v", orgID, err)
+	}
+}
+
+// drain hands every tenant over to the other nodes and stops claiming new ones, for rolling deploys
+func (e *Engine) drain() {
+	if e.draining.Swap(true) {
+		return
+	}
+	log.Printf("🚰 Draining node %s: handing over %d tenants", e.nodeID, len(e.ownedTenants()))
+
+	// Unregister first so the others compute their share without this node
+	e.rdb.Del(context.Background(), nodeKeyPrefix+e.nodeID)
+	for _, orgID := range e.ownedTenants() {
+		e.releaseTenant(orgID)
+	}
+	log.Printf("Node %s drained, safe to shut down", e.nodeID)
+}
+
+// desiredTenants are the tenants that should be on air somewhere: a default mount and not switched off
+func (e *Engine) desiredTenants() ([]uuid.UUID, error) {
+	var ids []uuid.UUID
+	err := e.db.DB.Model(&models.MountPoint{}).
+		Joins("LEFT JOIN stream_state ON stream_state.organization_id = mount_points.organization_id").
+		Where("mount_points.is_default = ? AND COALESCE(stream_state.broadcast_mode, '') <> ?", true, "offline").
+		Order("mount_points.organization_id").
+		Distinct().Pluck("mount_points.organization_id", &ids).Error
+	return ids, err
+}
+
+// liveNodes counts the registered, non-draining engines (this one included)
+func (e *Engine) liveNodes(ctx context.Context) int {
+	nodes := 0
+	iter := e.rdb.Scan(ctx, 0, nodeKeyPrefix+"*", 100).Iterator()
+	for iter.Next(ctx) {
+		nodes++
+	}
+	if err := iter.Err(); err != nil || nodes == 0 {
+		return 1
+	}
+	return nodes
+}
+
+func (e *Engine) ownedTenants() []uuid.UUID {
+	var owned []uuid.UUID
+	e.activeStreams.Range(func(key, _ any) bool {
+		owned = append(owned, key.(uuid.UUID))
+		return true
+	})
+	return owned
+}
+
+func (e *Engine) isRunning(orgID uuid.UUID) bool {
+	_, running := e.activeStreams.Load(orgID)
+	return running
+}
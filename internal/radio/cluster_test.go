@@ -0,0 +1,18 @@
+package radio
+
+import "testing"
+
+func TestFairShare(t *testing.T) {
+	tests := []struct{ tenants, nodes, want int }{
+		{10, 1, 10},
+		{10, 2, 5},
+		{10, 3, 4}, // 4+4+2: nobody is left without a node
+		{0, 3, 0},
+		{5, 0, 5}, // Redis unreachable: this node alone
+	}
+	for _, tt := range tests {
+		if got := fairShare(tt.tenants, tt.nodes); got != tt.want {
+			t.Errorf("fairShare(%d, %d) = %d, want %d", tt.tenants, tt.nodes, got, tt.want)
+		}
+	}
+}
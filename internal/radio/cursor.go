@@ -0,0 +1,176 @@
+package radio
+
+import (
+	"errors"
+	"log"
+	"math/rand"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+
+	"momo-radio/internal/models"
+	"momo-radio/internal/scheduler"
+)
+
+// cursorRetention is how long the cursors of past airings are kept (replay cursors are kept forever)
+const cursorRetention = 30 * 24 * time.Hour
+
+// errPlaylistDone is returned once a play_once show has aired every track
+var errPlaylistDone = errors.New("playlist finished")
+
+// playlistPicker walks a slot's playlist from its stored cursor
+type playlistPicker struct {
+	e        *Engine
+	orgID    uuid.UUID
+	mode     string
+	cursor   models.PlaylistCursor
+	trackIDs []uint
+	next     int // Next absolute position to hand out, counting across loops
+}
+
+// occurrenceKey names the airing of slot a cursor belongs to. Replays share one cursor and pick up where the last airing stopped.
+func occurrenceKey(slot *models.ScheduleSlot, now time.Time) string {
+	if slot.IsReplay {
+		return "replay"
+	}
+	start := scheduler.OccurrenceStart(slot, now)
+	if start.IsZero() {
+		start = now
+	}
+	return start.Format("2006-01-02T15:04")
+}
+
+// openPlaylist loads (or starts) the cursor for the airing of slot at now.
+// Positions already handed to the queue are skipped, so the queue and the cursor never repeat each other.
+func (e *Engine) openPlaylist(orgID uuid.UUID, slot *models.ScheduleSlot, now time.Time) (*playlistPicker, error) {
+	p := &playlistPicker{e: e, orgID: orgID, mode: slot.PlaybackMode}
+
+	// 1. Playable tracks in playlist order
+	err := e.db.DB.Table("playlist_tracks").
+		Joins("JOIN tracks ON tracks.id = playlist_tracks.track_id").
+		Where("playlist_tracks.playlist_id = ? AND tracks.organization_id = ? AND tracks.deleted_at IS NULL AND tracks.key <> ''", *slot.PlaylistID, orgID).
+		Order("playlist_tracks.sort_order ASC, playlist_tracks.track_id ASC").
+		Pluck("playlist_tracks.track_id", &p.trackIDs).Error
+	if err != nil {
+		return nil, err
+	}
+	if len(p.trackIDs) == 0 {
+		return nil, errNoSelection
+	}
+
+	// 2. The cursor of this airing
+	cursor, err := e.loadCursor(orgID, slot, occurrenceKey(slot, now))
+	if err != nil {
+		return nil, err
+	}
+	p.cursor = cursor
+
+	// 3. Continue after whatever is already queued from this cursor
+	p.next = cursor.Position
+	if cursor.ID != 0 {
+		var queued *int
+		e.db.DB.Model(&models.QueueItem{}).
+			Where("organization_id = ? AND cursor_id = ?", orgID, cursor.ID).
+			Select("MAX(playlist_index)").Scan(&queued)
+		if queued != nil && *queued+1 > p.next {
+			p.next = *queued + 1
+		}
+	}
+	return p, nil
+}
+
+// loadCursor finds the cursor of an occurrence, creating it on first use. A playlist swapped on the slot starts over.
+func (e *Engine) loadCursor(orgID uuid.UUID, slot *models.ScheduleSlot, occurrence string) (models.PlaylistCursor, error) {
+	fresh := models.PlaylistCursor{
+		OrganizationID: orgID,
+		ScheduleSlotID: slot.ID,
+		Occurrence:     occurrence,
+		PlaylistID:     *slot.PlaylistID,
+		Seed:           rand.Int63(),
+	}
+
+	var cursor models.PlaylistCursor
+	err := e.db.DB.Where("organization_id = ? AND schedule_slot_id = ? AND occurrence = ?", orgID, slot.ID, occurrence).First(&cursor).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		// Simulations read the cursor but never write one
+		if e.cfg.Radio.DryRun {
+			return fresh, nil
+		}
+
+		e.db.DB.Where("organization_id = ? AND occurrence <> ? AND updated_at < ?", orgID, "replay", time.Now().Add(-cursorRetention)).
+			Delete(&models.PlaylistCursor{})
+
+		// Another engine may have created it in the meantime: keep theirs
+		if err := e.db.DB.Clauses(clause.OnConflict{DoNothing: true}).Create(&fresh).Error; err != nil {
+			return cursor, err
+		}
+		err = e.db.DB.Where("organization_id = ? AND schedule_slot_id = ? AND occurrence = ?", orgID, slot.ID, occurrence).First(&cursor).Error
+	}
+	if err != nil {
+		return cursor, err
+	}
+
+	if cursor.PlaylistID != *slot.PlaylistID {
+		log.Printf("[%s] Slot %d now airs playlist %d, starting it from the top", orgID, slot.ID, *slot.PlaylistID)
+		cursor.PlaylistID = *slot.PlaylistID
+		cursor.Position = 0
+		cursor.Seed = rand.Int63()
+		if !e.cfg.Radio.DryRun {
+			e.db.DB.Model(&cursor).Updates(map[string]any{"playlist_id": cursor.PlaylistID, "position": 0, "seed": cursor.Seed})
+		}
+	}
+	return cursor, nil
+}
+
+// pick hands out the track at the next position
+func (p *playlistPicker) pick() (queuePick, error) {
+	n := len(p.trackIDs)
+	for attempt := 0; attempt < n; attempt++ {
+		if p.mode == scheduler.PlaybackPlayOnce && p.next >= n {
+			return queuePick{}, errPlaylistDone
+		}
+
+		index := p.next
+		p.next++
+
+		var track models.Track
+		err := p.e.db.DB.Preload("Artists").Preload("Album").
+			Where("organization_id = ?", p.orgID).
+			First(&track, p.trackIDs[playlistOrder(p.mode, p.cursor.Seed, index, n)]).Error
+		if err != nil {
+			continue // Removed since the playlist was loaded
+		}
+
+		qp := queuePick{track: &track, playlistIndex: &index}
+		if p.cursor.ID != 0 {
+			qp.cursorID = &p.cursor.ID
+		}
+		return qp, nil
+	}
+	return queuePick{}, errNoSelection
+}
+
+// playlistOrder maps an absolute position to an index into a playlist of n tracks.
+// Shuffled orders come from the cursor's seed, so they survive restarts; shuffle_each_loop reshuffles every pass.
+func playlistOrder(mode string, seed int64, position, n int) int {
+	loop, offset := position/n, position%n
+
+	switch mode {
+	case scheduler.PlaybackShuffleOnce:
+		return rand.New(rand.NewSource(seed)).Perm(n)[offset]
+	case scheduler.PlaybackShuffleEachLoop:
+		return rand.New(rand.NewSource(seed + int64(loop))).Perm(n)[offset]
+	}
+	return offset
+}
+
+// advanceCursor records that a playlist item went on air, so a restart continues after it
+func (e *Engine) advanceCursor(item *models.QueueItem) {
+	if item.CursorID == nil || item.PlaylistIndex == nil {
+		return
+	}
+	e.db.DB.Model(&models.PlaylistCursor{}).Where("id = ?", *item.CursorID).
+		Update("position", gorm.Expr("GREATEST(position, ?)", *item.PlaylistIndex+1))
+}
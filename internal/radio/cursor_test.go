@@ -0,0 +1,48 @@
+package radio
+
+import (
+	"testing"
+
+	"momo-radio/internal/scheduler"
+)
+
+func TestPlaylistOrder(t *testing.T) {
+	const n = 6
+
+	// One full pass of every mode airs every track exactly once
+	for _, mode := range []string{scheduler.PlaybackSequential, scheduler.PlaybackShuffleOnce, scheduler.PlaybackShuffleEachLoop, scheduler.PlaybackPlayOnce} {
+		seen := make(map[int]bool)
+		for pos := 0; pos < n; pos++ {
+			seen[playlistOrder(mode, 42, pos, n)] = true
+		}
+		if len(seen) != n {
+			t.Errorf("%s: first pass aired %d distinct tracks, want %d", mode, len(seen), n)
+		}
+	}
+
+	for pos := 0; pos < 2*n; pos++ {
+		if got := playlistOrder(scheduler.PlaybackSequential, 42, pos, n); got != pos%n {
+			t.Errorf("sequential position %d = %d, want %d", pos, got, pos%n)
+		}
+	}
+
+	// The same seed gives the same order, so a restart resumes the same shuffle
+	sameLoops, differentLoops := true, true
+	for pos := 0; pos < n; pos++ {
+		if playlistOrder(scheduler.PlaybackShuffleOnce, 42, pos, n) != playlistOrder(scheduler.PlaybackShuffleOnce, 42, pos, n) {
+			t.Fatal("shuffle_once is not stable for a seed")
+		}
+		if playlistOrder(scheduler.PlaybackShuffleOnce, 42, pos, n) != playlistOrder(scheduler.PlaybackShuffleOnce, 42, pos+n, n) {
+			sameLoops = false
+		}
+		if playlistOrder(scheduler.PlaybackShuffleEachLoop, 42, pos, n) != playlistOrder(scheduler.PlaybackShuffleEachLoop, 42, pos+n, n) {
+			differentLoops = false
+		}
+	}
+	if !sameLoops {
+		t.Error("shuffle_once changed order on the second loop")
+	}
+	if differentLoops {
+		t.Error("shuffle_each_loop repeated the first loop's order")
+	}
+}
@@ -0,0 +1,274 @@
+package radio
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/prometheus/client_golang/prometheus"
+
+	"momo-radio/internal/audio"
+	"momo-radio/internal/models"
+)
+
+// alertsChannel carries engine alerts (dead air) for dashboards and notifiers
+const alertsChannel = "radio.alerts"
+
+var (
+	deadAirEvents = prometheus.NewCounterVec(
+		prometheus.CounterOpts{Name: "radio_dead_air_events_total", Help: "Dead air detected on the outgoing audio"},
+		[]string{"organization_id"},
+	)
+	deadAirActive = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{Name: "radio_dead_air", Help: "1 while the outgoing audio is silent"},
+		[]string{"organization_id"},
+	)
+)
+
+// deadAirMonitor sits between the mixer and the encoders and measures what actually goes on air.
+// Silence and no audio at all look the same to it: the last audible write gets old.
+type deadAirMonitor struct {
+	out       io.WriteCloser
+	threshold float64 // Peak dBFS below which a write is silent
+
+	mu        sync.Mutex
+	lastSound time.Time
+	lastTrip  time.Time
+	cancel    context.CancelFunc // Cancels the item currently on air
+	fallback  bool               // The orchestrator owes a fallback loop
+}
+
+func newDeadAirMonitor(out io.WriteCloser, threshold float64) *deadAirMonitor {
+	return &deadAirMonitor{out: out, threshold: threshold, lastSound: time.Now()}
+}
+
+func (m *deadAirMonitor) Write(p []byte) (int, error) {
+	if audio.PeakDBFS(p) > m.threshold {
+		m.mu.Lock()
+		m.lastSound = time.Now()
+		m.mu.Unlock()
+	}
+	return m.out.Write(p)
+}
+
+func (m *deadAirMonitor) Close() error {
+	return m.out.Close()
+}
+
+// silentFor is how long nothing audible went on air
+func (m *deadAirMonitor) silentFor() time.Duration {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return time.Since(m.lastSound)
+}
+
+// begin derives the context for the next item on air, so dead air can cut it short
+func (m *deadAirMonitor) begin(parent context.Context) (context.Context, context.CancelFunc) {
+	ctx, cancel := context.WithCancel(parent)
+
+	m.mu.Lock()
+	m.cancel = cancel
+	m.mu.Unlock()
+
+	return ctx, func() {
+		m.mu.Lock()
+		m.cancel = nil
+		m.mu.Unlock()
+		cancel()
+	}
+}
+
+// trip skips the item on air and asks for the fallback loop, at most once per limit
+func (m *deadAirMonitor) trip(limit time.Duration) bool {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if time.Since(m.lastTrip) < limit {
+		return false
+	}
+	m.lastTrip = time.Now()
+	m.fallback = true
+	if m.cancel != nil {
+		m.cancel()
+	}
+	return true
+}
+
+// takeFallback reports (and clears) a pending fallback request
+func (m *deadAirMonitor) takeFallback() bool {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	owed := m.fallback
+	m.fallback = false
+	return owed
+}
+
+func (e *Engine) deadAirLimit() time.Duration {
+	if e.cfg.Radio.DeadAirSeconds > 0 {
+		return time.Duration(e.cfg.Radio.DeadAirSeconds) * time.Second
+	}
+	return 10 * time.Second
+}
+
+func (e *Engine) silenceThreshold() float64 {
+	if e.cfg.Radio.SilenceThresholdDB < 0 {
+		return e.cfg.Radio.SilenceThresholdDB
+	}
+	return -50
+}
+
+// watchDeadAir alerts when the outgoing audio goes silent and makes the orchestrator skip to the
+// fallback loop. A silent live feed is only reported: the DJ's stream is not ours to cut.
+func (e *Engine) watchDeadAir(ctx context.Context, orgID uuid.UUID, mount models.MountPoint, m *deadAirMonitor, live *liveSwitch) {
+	limit := e.deadAirLimit()
+	ticker := time.NewTicker(time.Second)
+	defer ticker.Stop()
+	defer deadAirActive.DeleteLabelValues(orgID.String())
+
+	var incident *models.PipelineIncident
+	resolve := func() {
+		deadAirActive.WithLabelValues(orgID.String()).Set(0)
+		e.db.DB.Model(incident).Update("resolved_at", time.Now())
+		incident = nil
+	}
+
+	for {
+		select {
+		case <-ctx.Done():
+			// The pipeline stopped: its dead air is over too
+			if incident != nil {
+				resolve()
+			}
+			return
+		case <-ticker.C:
+		}
+
+		silent := m.silentFor()
+		if silent < limit {
+			if incident != nil {
+				log.Printf("[%s] ✅ Audio is back on air after dead air", orgID)
+				resolve()
+			}
+			continue
+		}
+
+		if incident == nil {
+			incident = e.raiseDeadAir(ctx, orgID, mount, silent, live.IsLive())
+		}
+		if !live.IsLive() && m.trip(limit) {
+			log.Printf("[%s] 🚨 Dead air for %s: skipping the item on air", orgID, silent.Round(time.Second))
+		}
+	}
+}
+
+// raiseDeadAir counts, records and publishes a dead air alert
+func (e *Engine) raiseDeadAir(ctx context.Context, orgID uuid.UUID, mount models.MountPoint, silent time.Duration, isLive bool) *models.PipelineIncident {
+	deadAirEvents.WithLabelValues(orgID.String()).Inc()
+	deadAirActive.WithLabelValues(orgID.String()).Set(1)
+
+	detail := fmt.Sprintf("no audio above %.0f dBFS for %s", e.silenceThreshold(), silent.Round(time.Second))
+	if isLive {
+		detail += " (live feed)"
+	}
+	log.Printf("[%s] 🚨 Dead air: %s", orgID, detail)
+
+	incident := &models.PipelineIncident{
+		OrganizationID: orgID,
+		Mount:          mount.Slug,
+		Stage:          models.StagePlayout,
+		Kind:           models.IncidentDeadAir,
+		Detail:         detail,
+	}
+	if err := e.db.DB.Create(incident).Error; err != nil {
+		log.Printf("[%s] Failed to record dead air incident: %v", orgID, err)
+	}
+
+	alert, _ := json.Marshal(map[string]any{
+		"org_id":         orgID.String(),
+		"type":           models.IncidentDeadAir,
+		"mount":          mount.Slug,
+		"silent_seconds": int(silent.Seconds()),
+		"live":           isLive,
+		"at":             time.Now().Unix(),
+	})
+	e.rdb.Publish(ctx, alertsChannel, alert)
+	return incident
+}
+
+// playFallback airs the station's emergency loop once; false when it has none
+func (e *Engine) playFallback(ctx context.Context, orgID uuid.UUID, mixer *audio.Mixer, targetLUFS float64, live *liveSwitch) bool {
+	path, asset := e.fallbackPath(orgID)
+	if path == "" {
+		log.Printf("[%s] No fallback loop to cover dead air (upload imaging of kind \"fallback\")", orgID)
+		return false
+	}
+
+	log.Printf("[%s] 🛟 Airing the fallback loop", orgID)
+	fallbackCtx, done := live.begin(ctx)
+	err := mixer.Play(fallbackCtx, path, audio.MixOptions{GainDB: loudnessGain(asset, targetLUFS)})
+	done()
+
+	if err != nil && fallbackCtx.Err() == nil {
+		log.Printf("[%s] Fallback loop failed: %v", orgID, err)
+		return false
+	}
+	return true
+}
+
+// fallbackPath returns the station's fallback loop on local disk, refreshed from storage when it changed.
+// The copy lives outside the track cache, so it still plays when storage or the database is down.
+// The asset carries the loop's loudness; nil when the database could not tell, and the loop plays as is.
+func (e *Engine) fallbackPath(orgID uuid.UUID) (string, *models.Track) {
+	// The warm-up at pipeline start and playFallback share one download and one directory
+	lock, _ := e.fallbackLocks.LoadOrStore(orgID, &sync.Mutex{})
+	lock.(*sync.Mutex).Lock()
+	defer lock.(*sync.Mutex).Unlock()
+
+	dir := filepath.Join(e.cfg.Server.TempDir, "fallback", orgID.String())
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		log.Printf("[%s] Failed to create fallback dir: %v", orgID, err)
+		return "", nil
+	}
+
+	var asset models.Track
+	err := e.db.DB.Select("id", "key", "integrated_lufs", "true_peak").
+		Where("organization_id = ? AND kind = ? AND processing_status = ? AND key <> ''", orgID, models.TrackKindFallback, "completed").
+		Order("id DESC").First(&asset).Error
+	if err == nil {
+		path := filepath.Join(dir, fmt.Sprintf("%d_%s", asset.ID, filepath.Base(asset.Key)))
+		if e.cache.exists(path) {
+			return path, &asset
+		}
+		if err = e.cache.download(asset.Key, path); err == nil {
+			pruneDir(dir, path)
+			return path, &asset
+		}
+		log.Printf("[%s] Failed to fetch fallback loop: %v", orgID, err)
+	}
+
+	// Keep airing whatever copy we have
+	files, _ := os.ReadDir(dir)
+	for _, f := range files {
+		if path := filepath.Join(dir, f.Name()); filepath.Ext(path) != ".tmp" && e.cache.exists(path) {
+			return path, nil
+		}
+	}
+	return "", nil
+}
+
+// pruneDir removes every file of dir except keep
+func pruneDir(dir, keep string) {
+	files, _ := os.ReadDir(dir)
+	for _, f := range files {
+		if path := filepath.Join(dir, f.Name()); path != keep {
+			os.Remove(path)
+		}
+	}
+}
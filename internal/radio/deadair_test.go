@@ -0,0 +1,47 @@
+package radio
+
+import (
+	"context"
+	"io"
+	"testing"
+	"time"
+)
+
+type discardCloser struct{ io.Writer }
+
+func (discardCloser) Close() error { return nil }
+
+func TestDeadAirMonitor(t *testing.T) {
+	m := newDeadAirMonitor(discardCloser{io.Discard}, -50)
+	m.lastSound = time.Now().Add(-time.Minute)
+
+	// Digital silence does not count as sound
+	m.Write(make([]byte, 4096))
+	if m.silentFor() < 59*time.Second {
+		t.Fatalf("silence must not reset the dead air clock")
+	}
+
+	ctx, done := m.begin(context.Background())
+	defer done()
+	if !m.trip(10 * time.Second) {
+		t.Fatal("first trip should fire")
+	}
+	if ctx.Err() == nil {
+		t.Error("trip should cut the item on air short")
+	}
+	if m.trip(10 * time.Second) {
+		t.Error("a second trip within the limit should not fire")
+	}
+	if !m.takeFallback() || m.takeFallback() {
+		t.Error("the fallback is owed exactly once")
+	}
+
+	loud := make([]byte, 4096)
+	for i := 0; i < len(loud); i += 2 {
+		loud[i], loud[i+1] = 0x00, 0x40 // 16384, about -6 dBFS
+	}
+	m.Write(loud)
+	if m.silentFor() > time.Second {
+		t.Error("audible audio should reset the dead air clock")
+	}
+}
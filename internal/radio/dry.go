@@ -11,65 +11,127 @@ import (
 
 	"momo-radio/internal/dj"
 	"momo-radio/internal/models"
+	"momo-radio/internal/scheduler"
 )
 
+// Simulation bounds: a few hours of programme, or this many tracks, whichever comes first
+const (
+	simulationWindow   = 6 * time.Hour
+	maxSimulatedTracks = 150
+)
+
+// slotDrift compares when a programme was scheduled to start with when the simulation put it on air
+type slotDrift struct {
+	name      string
+	mode      string
+	scheduled time.Time
+	onAir     time.Time
+}
+
+// Simulate prints the upcoming programme of one tenant, or of every tenant with a default mount when orgID is nil
+func (e *Engine) Simulate(orgID uuid.UUID) {
+	if orgID != uuid.Nil {
+		e.runSimulation(orgID)
+		return
+	}
+
+	var mounts []models.MountPoint
+	e.db.DB.Where("is_default = ?", true).Find(&mounts)
+	for _, m := range mounts {
+		e.runSimulation(m.OrganizationID)
+	}
+}
+
 // ⚡️ Added orgID to simulate a specific tenant's station
 func (e *Engine) runSimulation(orgID uuid.UUID) {
 	fmt.Printf("\n--- DRY PLAYLIST SIMULATION FOR TENANT: %s ---\n", orgID.String())
-	fmt.Println("Logic: Uses Scheduler + Selector Strategy (No DB updates)")
+	fmt.Println("Logic: Uses Scheduler + Selector Strategy + Slot Timing (No DB updates)")
 	fmt.Println("--------------------------------------------------------------------------------")
 
 	w := tabwriter.NewWriter(os.Stdout, 0, 0, 3, ' ', 0)
-	defer w.Flush()
 
-	fmt.Fprintln(w, "TIME\tMODE\tARTIST\tTITLE\tBPM\tKEY\tPROGRAM")
-	fmt.Fprintln(w, "----\t----\t------\t-----\t---\t---\t-------")
+	fmt.Fprintln(w, "TIME\tMODE\tARTIST\tTITLE\tBPM\tKEY\tPROGRAM\tNOTE")
+	fmt.Fprintln(w, "----\t----\t------\t-----\t---\t---\t-------\t----")
 
 	// ⚡️ Pass orgID into the Selectors
 	selectors := map[string]dj.Selector{
 		"random":     dj.NewSelector("random", e.db.DB, orgID),
 		"harmonic":   dj.NewSelector("harmonic", e.db.DB, orgID),
 		"starvation": dj.NewSelector("starvation", e.db.DB, orgID),
+		"curve":      dj.NewSelector("curve", e.db.DB, orgID),
 	}
 
-	const Limit = 20
-	simulatedTime := time.Now()
+	simulatedTime := e.scheduler.Now(orgID)
+	end := simulatedTime.Add(simulationWindow)
 	var lastTrack *models.Track
-
-	for i := 0; i < Limit; i++ {
-		// ⚡️ Pass orgID into the Scheduler
-		activeSlot := e.scheduler.GetCurrentSchedule(orgID)
+	var onAir *models.ScheduleSlot
+	var drifts []slotDrift
+	// One picker per programme, so playlist shows advance through the simulation like the cursor would
+	pickers := make(map[uint]trackPicker)
+
+	for i := 0; i < maxSimulatedTracks && simulatedTime.Before(end); i++ {
+		// ⚡️ Resolve the programme at the simulated time, not the real one
+		activeSlot, boundary, hasBoundary := e.resolveProgramme(orgID, simulatedTime)
 		showName := getShowName(activeSlot)
 
-		var selectedTrack *models.Track
-		var err error
-		currentMode := "Unknown"
+		// 1. Programme change: record how far from its scheduled start it went on air
+		if onAir == nil || activeSlot.ID != onAir.ID {
+			if activeSlot.ID != 0 {
+				scheduled := simulatedTime
+				if start, _, ok := scheduler.CurrentAiring(activeSlot, simulatedTime); ok {
+					scheduled = start
+				} else if next, ok := e.scheduler.NextBoundary(orgID, onAir, simulatedTime, boundaryHorizon); ok && next.Slot != nil && next.Slot.ID == activeSlot.ID {
+					scheduled = next.At // Soft start brought forward
+				}
+				drifts = append(drifts, slotDrift{name: showName, mode: activeSlot.StartMode, scheduled: scheduled, onAir: simulatedTime})
+			}
+			onAir = activeSlot
+		}
 
-		if activeSlot != nil && activeSlot.Playlist != nil {
+		// 2. Same selection as the orchestrator, including fitting before a soft start
+		currentMode := "Random"
+		if activeSlot.PlaylistID != nil {
 			currentMode = "Playlist"
-			// ⚡️ Pass orgID into the Playlist Picker
-			selectedTrack, err = e.pickNextFromPlaylist(orgID, activeSlot.Playlist.ID, lastTrack)
-		} else if activeSlot != nil && activeSlot.RuleSet != nil {
-			mode := strings.ToLower(activeSlot.RuleSet.Mode)
-			if mode == "" {
-				mode = "random"
-			}
+		} else if activeSlot.RuleSet != nil && activeSlot.RuleSet.Mode != "" {
+			currentMode = strings.ToLower(activeSlot.RuleSet.Mode)
+		}
 
-			selector, exists := selectors[mode]
-			if !exists {
-				selector = selectors["random"]
+		picker, ok := pickers[activeSlot.ID]
+		if !ok {
+			picker = e.newTrackPicker(orgID, activeSlot, simulatedTime, selectors)
+			pickers[activeSlot.ID] = picker
+		}
+		picked, err := picker(lastTrack, simulatedTime)
+		selectedTrack := picked.track
+		note := ""
+
+		if err == nil && selectedTrack != nil && hasBoundary && boundary.Mode == scheduler.StartSoft {
+			remaining := boundary.At.Sub(simulatedTime)
+			tol := boundary.Tolerance(activeSlot)
+			if scheduler.ChooseFit([]time.Duration{trackLength(selectedTrack)}, remaining, tol, minFillGap) != 0 {
+				if fit, ok := fitCandidate(picker, lastTrack, simulatedTime, remaining, tol); ok {
+					selectedTrack = fit.track
+					note = "fitted"
+				}
 			}
-
-			currentMode = selector.Name()
-			selectedTrack, err = selector.PickTrack(activeSlot.RuleSet, lastTrack)
+		}
+		if picked.playlistIndex != nil && note == "" {
+			note = fmt.Sprintf("#%d", *picked.playlistIndex+1)
 		}
 
 		if err != nil || selectedTrack == nil {
-			fmt.Fprintf(w, "%s\tERROR\t---\tSelection Failed: %v\t---\t---\t%s\n",
+			fmt.Fprintf(w, "%s\tERROR\t---\tSelection Failed: %v\t---\t---\t%s\t\n",
 				simulatedTime.Format("15:04:05"), err, showName)
 			break
 		}
 
+		// 3. A hard start cuts the track at the boundary
+		played := trackLength(selectedTrack)
+		if hasBoundary && boundary.Mode == scheduler.StartHard && boundary.At.Before(simulatedTime.Add(played)) {
+			played = boundary.At.Sub(simulatedTime)
+			note = fmt.Sprintf("cut after %s", played.Round(time.Second))
+		}
+
 		// ⚡️ NEW: Join multiple artists for the CLI output
 		var artistNames []string
 		for _, a := range selectedTrack.Artists {
@@ -80,7 +142,7 @@ func (e *Engine) runSimulation(orgID uuid.UUID) {
 			artistStr = strings.Join(artistNames, ", ")
 		}
 
-		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%.0f\t%s\t%s\n",
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%.0f\t%s\t%s\t%s\n",
 			simulatedTime.Format("15:04:05"),
 			currentMode,
 			truncate(artistStr, 20), // ⚡️ Pass the joined string here
@@ -88,15 +150,29 @@ func (e *Engine) runSimulation(orgID uuid.UUID) {
 			selectedTrack.BPM,
 			selectedTrack.MusicalKey,
 			showName,
+			note,
 		)
 
 		lastTrack = selectedTrack
-		duration := time.Duration(selectedTrack.Duration) * time.Second
-		if duration == 0 {
-			duration = 4 * time.Minute
-		}
-		simulatedTime = simulatedTime.Add(duration)
+		simulatedTime = simulatedTime.Add(played)
+	}
+	w.Flush()
+
+	// 4. Predicted drift per slot: positive means the show goes on air late
+	fmt.Println("\n--- PREDICTED SLOT DRIFT ---")
+	w = tabwriter.NewWriter(os.Stdout, 0, 0, 3, ' ', 0)
+	fmt.Fprintln(w, "PROGRAM\tSTART MODE\tSCHEDULED\tON AIR\tDRIFT")
+	fmt.Fprintln(w, "-------\t----------\t---------\t------\t-----")
+	for _, d := range drifts {
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%+ds\n",
+			truncate(d.name, 25),
+			d.mode,
+			d.scheduled.Format("Mon 15:04:05"),
+			d.onAir.Format("Mon 15:04:05"),
+			int(d.onAir.Sub(d.scheduled).Seconds()),
+		)
 	}
+	w.Flush()
 
 	fmt.Println("\nSimulation Complete. Above is what your listeners would hear right now.")
 }
@@ -0,0 +1,41 @@
+package radio
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestIcyMetadataBlock(t *testing.T) {
+	block := icyMetadataBlock("Artist - Title")
+	want := "StreamTitle='Artist - Title';"
+
+	if int(block[0])*16 != len(block)-1 {
+		t.Fatalf("length byte %d does not match payload size %d", block[0], len(block)-1)
+	}
+	if !bytes.HasPrefix(block[1:], []byte(want)) {
+		t.Errorf("payload = %q, want prefix %q", block[1:], want)
+	}
+	if (len(block)-1)%16 != 0 {
+		t.Errorf("payload size %d is not padded to 16 bytes", len(block)-1)
+	}
+}
+
+func TestIcyWriterInterleavesMetadata(t *testing.T) {
+	var out bytes.Buffer
+	title := "A - B"
+	iw := &icyWriter{w: &out, metaint: 4, left: 4, title: func() string { return title }}
+
+	// Two full intervals: first carries the title, second is empty since it did not change
+	if _, err := iw.Write([]byte("12345678")); err != nil {
+		t.Fatal(err)
+	}
+
+	meta := icyMetadataBlock(title)
+	want := append([]byte("1234"), meta...)
+	want = append(want, []byte("5678")...)
+	want = append(want, 0)
+
+	if !bytes.Equal(out.Bytes(), want) {
+		t.Errorf("got %q, want %q", out.Bytes(), want)
+	}
+}
@@ -0,0 +1,176 @@
+package radio
+
+import (
+	"context"
+	"log"
+	"sort"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+
+	"momo-radio/internal/audio"
+	"momo-radio/internal/models"
+)
+
+// defaultTopOfHourWindow is how late into the hour a top-of-hour insertion may still air
+const defaultTopOfHourWindow = 10
+
+// imagingOverlap is the share of an asset allowed to overlap the music on either side,
+// so a short ID is heard clean between the songs instead of inside their fades
+const imagingOverlap = 0.25
+
+// imagingState tracks, per orchestrator, when each insertion rule last fired
+type imagingState struct {
+	lastFired  map[uint]time.Time
+	songsSince map[uint]int
+}
+
+func newImagingState() *imagingState {
+	return &imagingState{
+		lastFired:  make(map[uint]time.Time),
+		songsSince: make(map[uint]int),
+	}
+}
+
+func (s *imagingState) fired(ruleID uint, now time.Time) {
+	s.lastFired[ruleID] = now
+	s.songsSince[ruleID] = 0
+}
+
+// songPlayed advances every every-N-tracks counter
+func (s *imagingState) songPlayed(rules []models.InsertionRule) {
+	for _, r := range rules {
+		s.songsSince[r.ID]++
+	}
+}
+
+// insertionDue reports whether rule should fire at the song boundary at now (tenant wall clock)
+func insertionDue(rule models.InsertionRule, lastFired time.Time, songsSince int, now time.Time) bool {
+	switch rule.Trigger {
+	case models.TriggerInterval:
+		every := time.Duration(max(rule.Value, 1)) * time.Minute
+		return lastFired.IsZero() || now.Sub(lastFired) >= every
+	case models.TriggerEveryN:
+		return songsSince >= max(rule.Value, 1)
+	case models.TriggerTopOfHour:
+		window := rule.Value
+		if window <= 0 {
+			window = defaultTopOfHourWindow
+		}
+		hour := time.Date(now.Year(), now.Month(), now.Day(), now.Hour(), 0, 0, 0, now.Location())
+		return now.Sub(hour) <= time.Duration(window)*time.Minute && lastFired.Before(hour)
+	}
+	return false
+}
+
+// ruleApplies reports whether a rule is in scope while slot is on air
+func ruleApplies(rule models.InsertionRule, slot *models.ScheduleSlot) bool {
+	if rule.ScheduleSlotID != nil && (slot == nil || slot.ID != *rule.ScheduleSlotID) {
+		return false
+	}
+	if rule.RuleSetID != nil && (slot == nil || slot.RuleSetID == nil || *slot.RuleSetID != *rule.RuleSetID) {
+		return false
+	}
+	return true
+}
+
+// dueInsertions returns the rules to fire now: standalone ones first, then at most one talk-over
+func dueInsertions(rules []models.InsertionRule, st *imagingState, slot *models.ScheduleSlot, now time.Time) []models.InsertionRule {
+	var due []models.InsertionRule
+	talkOver := false
+	for _, r := range rules {
+		if !ruleApplies(r, slot) || !insertionDue(r, st.lastFired[r.ID], st.songsSince[r.ID], now) {
+			continue
+		}
+		if r.TalkOver {
+			if talkOver {
+				r.TalkOver = false // Only one voice can go over the next intro
+			}
+			talkOver = true
+		}
+		due = append(due, r)
+	}
+
+	sort.SliceStable(due, func(i, j int) bool { return !due[i].TalkOver && due[j].TalkOver })
+	return due
+}
+
+// loadInsertionRules returns the tenant's enabled rules
+func (e *Engine) loadInsertionRules(orgID uuid.UUID) []models.InsertionRule {
+	var rules []models.InsertionRule
+	if err := e.db.DB.Where("organization_id = ? AND enabled = ?", orgID, true).Order("id ASC").Find(&rules).Error; err != nil {
+		log.Printf("[%s] Failed to load insertion rules: %v", orgID, err)
+	}
+	return rules
+}
+
+// playInsertions airs the imaging that is due before the next song.
+// It returns the local file to voice over the song intro with its loudness gain, or "" when no talk-over is due.
+func (e *Engine) playInsertions(ctx context.Context, orgID uuid.UUID, rules []models.InsertionRule, st *imagingState,
+	slot *models.ScheduleSlot, mixer *audio.Mixer, opts audio.MixOptions, targetLUFS float64, live *liveSwitch) (talkOver, talkOverKey string, talkOverGain float64) {
+
+	now := e.scheduler.Now(orgID)
+	for _, rule := range dueInsertions(rules, st, slot, now) {
+		// Fire even without an asset, so an empty category does not retry on every boundary
+		st.fired(rule.ID, now)
+
+		asset := e.pickImaging(orgID, rule.Kind)
+		if asset == nil {
+			log.Printf("[%s] Insertion rule %d: no %s assets ready", orgID, rule.ID, rule.Kind)
+			continue
+		}
+
+		if rule.TalkOver {
+			path, err := e.cache.GetLocalPath(asset.Key)
+			if err != nil {
+				log.Printf("[%s] Talk-over %d unavailable: %v", orgID, asset.ID, err)
+				continue
+			}
+			talkOver, talkOverKey, talkOverGain = path, asset.Key, loudnessGain(asset, targetLUFS)
+			continue
+		}
+
+		log.Printf("[%s] Inserting %s %q (rule %d)", orgID, asset.Kind, asset.Title, rule.ID)
+		assetOpts := opts
+		assetOpts.Crossfade = imagingCrossfade(opts.Crossfade, asset)
+		assetOpts.GainDB = loudnessGain(asset, targetLUFS)
+
+		trackCtx, done := live.begin(ctx)
+		err := e.streamTrackToMixer(trackCtx, asset.Key, mixer, assetOpts)
+		interrupted := trackCtx.Err() != nil
+		done()
+		if err != nil && !interrupted {
+			log.Printf("[%s] Insertion %d failed: %v", orgID, asset.ID, err)
+		}
+		if interrupted {
+			return "", "", 0
+		}
+	}
+	return talkOver, talkOverKey, talkOverGain
+}
+
+// imagingCrossfade shortens the mount's crossfade to what the asset can spare; unknown lengths cut hard
+func imagingCrossfade(crossfade time.Duration, asset *models.Track) time.Duration {
+	limit := time.Duration(asset.Duration * imagingOverlap * float64(time.Second))
+	return min(crossfade, limit)
+}
+
+// pickImaging rotates through the assets of kind, least recently aired first
+func (e *Engine) pickImaging(orgID uuid.UUID, kind string) *models.Track {
+	var asset models.Track
+	err := e.db.DB.
+		Where("organization_id = ? AND kind = ? AND processing_status = ? AND key <> ''", orgID, kind, "completed").
+		Order("last_played ASC NULLS FIRST").Order("RANDOM()").
+		First(&asset).Error
+	if err != nil {
+		return nil
+	}
+
+	// Imaging is not music: rotate it, but keep it out of the play history
+	e.db.DB.Model(&models.Track{}).Where("id = ?", asset.ID).Updates(map[string]any{
+		"play_count":  gorm.Expr("play_count + 1"),
+		"last_played": time.Now(),
+	})
+	return &asset
+}
@@ -0,0 +1,85 @@
+package radio
+
+import (
+	"testing"
+	"time"
+
+	"momo-radio/internal/models"
+)
+
+func TestInsertionDue(t *testing.T) {
+	at := func(hhmm string) time.Time {
+		tm, _ := time.Parse("2006-01-02 15:04", "2026-03-02 "+hhmm)
+		return tm
+	}
+
+	interval := models.InsertionRule{Trigger: models.TriggerInterval, Value: 15}
+	if !insertionDue(interval, time.Time{}, 0, at("10:00")) {
+		t.Error("interval rule should fire when it never has")
+	}
+	if insertionDue(interval, at("10:00"), 0, at("10:10")) {
+		t.Error("interval rule fired after 10 of 15 minutes")
+	}
+	if !insertionDue(interval, at("10:00"), 0, at("10:16")) {
+		t.Error("interval rule did not fire after 16 minutes")
+	}
+
+	everyN := models.InsertionRule{Trigger: models.TriggerEveryN, Value: 3}
+	if insertionDue(everyN, time.Time{}, 2, at("10:00")) || !insertionDue(everyN, time.Time{}, 3, at("10:00")) {
+		t.Error("every_tracks rule should fire on the third song")
+	}
+
+	top := models.InsertionRule{Trigger: models.TriggerTopOfHour}
+	if !insertionDue(top, at("09:02"), 0, at("10:04")) {
+		t.Error("top of hour did not fire 4 minutes into the hour")
+	}
+	if insertionDue(top, at("10:04"), 0, at("10:08")) {
+		t.Error("top of hour fired twice in the same hour")
+	}
+	if insertionDue(top, at("09:02"), 0, at("10:25")) {
+		t.Error("top of hour fired outside its window")
+	}
+}
+
+func TestDueInsertionsScopeAndOrder(t *testing.T) {
+	ruleSet := uint(7)
+	other := uint(8)
+	slot := &models.ScheduleSlot{ID: 3, RuleSetID: &ruleSet}
+
+	rules := []models.InsertionRule{
+		{ID: 1, Trigger: models.TriggerEveryN, Value: 1, TalkOver: true},
+		{ID: 2, Trigger: models.TriggerEveryN, Value: 1},
+		{ID: 3, Trigger: models.TriggerEveryN, Value: 1, RuleSetID: &other},
+		{ID: 4, Trigger: models.TriggerEveryN, Value: 1, RuleSetID: &ruleSet, TalkOver: true},
+	}
+	st := newImagingState()
+	st.songPlayed(rules)
+
+	due := dueInsertions(rules, st, slot, time.Now())
+	if len(due) != 3 {
+		t.Fatalf("got %d due rules, want 3 (rule 3 is out of scope)", len(due))
+	}
+	// Standalone inserts play first, and only one talk-over survives at the end
+	talkOvers := 0
+	for _, r := range due {
+		if r.TalkOver {
+			talkOvers++
+		}
+	}
+	if talkOvers != 1 || !due[len(due)-1].TalkOver {
+		t.Errorf("want exactly one talk-over, last; got %+v", due)
+	}
+}
+
+func TestImagingCrossfade(t *testing.T) {
+	id := &models.Track{Duration: 2}
+	if got := imagingCrossfade(4*time.Second, id); got != 500*time.Millisecond {
+		t.Errorf("2s ID with a 4s crossfade: got %v, want 500ms", got)
+	}
+	if got := imagingCrossfade(time.Second, &models.Track{Duration: 30}); got != time.Second {
+		t.Errorf("a long promo keeps the mount crossfade, got %v", got)
+	}
+	if got := imagingCrossfade(4*time.Second, &models.Track{}); got != 0 {
+		t.Errorf("an unprobed asset should cut hard, got %v", got)
+	}
+}
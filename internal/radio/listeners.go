@@ -0,0 +1,433 @@
+package radio
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+
+	"momo-radio/internal/audio"
+	"momo-radio/internal/models"
+	"momo-radio/internal/utils"
+)
+
+const (
+	// listenerSampleInterval is the resolution of the concurrent-listener time series
+	listenerSampleInterval = time.Minute
+	// orphanedSessionAge closes sessions left open by an engine instance that went away
+	orphanedSessionAge = 5 * time.Minute
+	// maxSessionsPerIP bounds what a single address can open, generous enough for a busy NAT
+	maxSessionsPerIP = 50
+	// mountCacheTTL is how long a beacon's org/mount lookup is trusted
+	mountCacheTTL   = time.Minute
+	maxCachedMounts = 10000
+)
+
+// Session sources
+const (
+	SourceHLS     = "hls"
+	SourceBeacon  = "beacon"
+	SourceIcecast = "icecast"
+)
+
+type listenerSession struct {
+	id       uint // ListenerSession row, 0 until persisted
+	orgID    uuid.UUID
+	mount    string
+	ip       string
+	lastSeen time.Time
+	held     int // Open connections (Icecast) keep the session alive regardless of heartbeats
+}
+
+// listenerTracker keeps the live listening sessions of this engine instance in memory
+// and persists their start/stop, plus one concurrency sample per mount and interval.
+type listenerTracker struct {
+	db      *gorm.DB
+	geo     *utils.GeoIPDB
+	timeout time.Duration
+	secret  []byte // Keys the daily listener hash, see hashListener
+
+	mu       sync.Mutex
+	sessions map[string]*listenerSession
+	perIP    map[string]int       // Open sessions per client address
+	mounts   map[string]mountSeen // Beacon org/mount lookups
+}
+
+type mountSeen struct {
+	exists bool
+	at     time.Time
+}
+
+// newListenerTracker hashes listeners with secret; without one a random secret is used,
+// so unique listeners are only told apart within this process
+func newListenerTracker(db *gorm.DB, geo *utils.GeoIPDB, timeout time.Duration, secret string) *listenerTracker {
+	key := []byte(secret)
+	if secret == "" {
+		log.Printf("⚠️ radio.listener_secret is not set: unique listeners are counted per engine process")
+		key = make([]byte, 32)
+		rand.Read(key)
+	}
+	return &listenerTracker{
+		db:       db,
+		geo:      geo,
+		timeout:  timeout,
+		secret:   key,
+		sessions: make(map[string]*listenerSession),
+		perIP:    make(map[string]int),
+		mounts:   make(map[string]mountSeen),
+	}
+}
+
+// Touch records a request from a listener, opening a session on first sight.
+// sid is an optional player-generated id; without it listeners are told apart by IP and user agent.
+// Returns "" when the address already has too many sessions open.
+func (t *listenerTracker) Touch(r *http.Request, orgID uuid.UUID, mount, source, sid string) string {
+	ip := clientIP(r)
+	ua := r.UserAgent()
+	listener := t.hashListener(ip, ua, time.Now())
+
+	key := fmt.Sprintf("%s/%s/%s", orgID, mount, listener)
+	if sid != "" {
+		key = fmt.Sprintf("%s/%s/sid:%s", orgID, mount, sid)
+	}
+
+	now := time.Now()
+	t.mu.Lock()
+	if s, ok := t.sessions[key]; ok {
+		s.lastSeen = now
+		t.mu.Unlock()
+		return key
+	}
+	if t.perIP[ip] >= maxSessionsPerIP {
+		t.mu.Unlock()
+		return ""
+	}
+	s := &listenerSession{orgID: orgID, mount: mount, ip: ip, lastSeen: now}
+	t.sessions[key] = s
+	t.perIP[ip]++
+	t.mu.Unlock()
+
+	if len(ua) > 255 {
+		ua = ua[:255]
+	}
+	row := models.ListenerSession{
+		OrganizationID: orgID,
+		Mount:          mount,
+		Source:         source,
+		ListenerHash:   listener,
+		Country:        t.geo.Country(ip),
+		UserAgent:      ua,
+		StartedAt:      now,
+		LastSeenAt:     now,
+	}
+	if err := t.db.Create(&row).Error; err != nil {
+		log.Printf("[%s] Failed to record listener session: %v", orgID, err)
+		return key
+	}
+
+	t.mu.Lock()
+	s.id = row.ID
+	t.mu.Unlock()
+	return key
+}
+
+// Hold pins a session open for the lifetime of a connection; call the returned func on disconnect.
+func (t *listenerTracker) Hold(r *http.Request, orgID uuid.UUID, mount, source string) func() {
+	key := t.Touch(r, orgID, mount, source, "")
+
+	t.mu.Lock()
+	if s, ok := t.sessions[key]; ok {
+		s.held++
+	}
+	t.mu.Unlock()
+
+	return func() {
+		t.mu.Lock()
+		defer t.mu.Unlock()
+		if s, ok := t.sessions[key]; ok {
+			s.held--
+			s.lastSeen = time.Now()
+		}
+	}
+}
+
+// End closes a session right away (player beacon "stop").
+func (t *listenerTracker) End(r *http.Request, orgID uuid.UUID, mount, sid string) {
+	key := fmt.Sprintf("%s/%s/%s", orgID, mount, t.hashListener(clientIP(r), r.UserAgent(), time.Now()))
+	if sid != "" {
+		key = fmt.Sprintf("%s/%s/sid:%s", orgID, mount, sid)
+	}
+
+	t.mu.Lock()
+	s, ok := t.sessions[key]
+	if ok && s.held == 0 {
+		t.forget(key, s)
+	}
+	t.mu.Unlock()
+
+	if ok && s.held == 0 {
+		t.closeSessions([]*listenerSession{s}, time.Now())
+	}
+}
+
+// Run expires idle sessions and writes a concurrency sample every interval until ctx is done.
+func (t *listenerTracker) Run(ctx context.Context) {
+	ticker := time.NewTicker(listenerSampleInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case now := <-ticker.C:
+			t.sample(now)
+		}
+	}
+}
+
+func (t *listenerTracker) sample(now time.Time) {
+	type mountKey struct {
+		orgID uuid.UUID
+		mount string
+	}
+
+	// 1. Split live sessions from the ones that stopped sending heartbeats
+	var expired []*listenerSession
+	var activeIDs []uint
+	counts := make(map[mountKey]int)
+
+	t.mu.Lock()
+	for key, s := range t.sessions {
+		if s.held == 0 && now.Sub(s.lastSeen) > t.timeout {
+			expired = append(expired, s)
+			t.forget(key, s)
+			continue
+		}
+		counts[mountKey{s.orgID, s.mount}]++
+		if s.id != 0 {
+			activeIDs = append(activeIDs, s.id)
+		}
+	}
+	for key, seen := range t.mounts {
+		if now.Sub(seen.at) > mountCacheTTL {
+			delete(t.mounts, key)
+		}
+	}
+	t.mu.Unlock()
+
+	t.closeSessions(expired, now)
+
+	// 2. Keep last_seen_at fresh so crashed instances can be told apart from live ones
+	if len(activeIDs) > 0 {
+		t.db.Model(&models.ListenerSession{}).Where("id IN ?", activeIDs).Update("last_seen_at", now)
+	}
+	t.db.Model(&models.ListenerSession{}).
+		Where("ended_at IS NULL AND last_seen_at < ?", now.Add(-orphanedSessionAge)).
+		Update("ended_at", gorm.Expr("last_seen_at"))
+
+	// 3. One sample row per mount, and per-tenant gauges
+	bucket := now.Truncate(listenerSampleInterval)
+	perOrg := make(map[uuid.UUID]int)
+	samples := make([]models.ListenerSample, 0, len(counts))
+	for k, n := range counts {
+		perOrg[k.orgID] += n
+		samples = append(samples, models.ListenerSample{
+			OrganizationID:   k.orgID,
+			BucketStart:      bucket,
+			Mount:            k.mount,
+			Listeners:        n,
+			ListeningSeconds: float64(n) * listenerSampleInterval.Seconds(),
+		})
+	}
+	if len(samples) > 0 {
+		if err := t.db.Create(&samples).Error; err != nil {
+			log.Printf("Failed to store listener samples: %v", err)
+		}
+	}
+
+	listenersCurrent.Reset()
+	for orgID, n := range perOrg {
+		listenersCurrent.WithLabelValues(orgID.String()).Set(float64(n))
+		listeningSeconds.WithLabelValues(orgID.String()).Add(float64(n) * listenerSampleInterval.Seconds())
+	}
+}
+
+// forget drops a session from memory; t.mu is held
+func (t *listenerTracker) forget(key string, s *listenerSession) {
+	delete(t.sessions, key)
+	if t.perIP[s.ip]--; t.perIP[s.ip] <= 0 {
+		delete(t.perIP, s.ip)
+	}
+}
+
+// mountExists reports whether the tenant has the mount, so requests cannot open sessions for made-up stations
+func (t *listenerTracker) mountExists(orgID uuid.UUID, mount string) bool {
+	key := orgID.String() + "/" + mount
+	now := time.Now()
+
+	t.mu.Lock()
+	seen, ok := t.mounts[key]
+	t.mu.Unlock()
+	if ok && now.Sub(seen.at) < mountCacheTTL {
+		return seen.exists
+	}
+
+	var count int64
+	err := t.db.Model(&models.MountPoint{}).Where("organization_id = ? AND slug = ?", orgID, mount).Count(&count).Error
+	if err != nil {
+		return false
+	}
+
+	t.mu.Lock()
+	if len(t.mounts) >= maxCachedMounts {
+		clear(t.mounts)
+	}
+	t.mounts[key] = mountSeen{exists: count > 0, at: now}
+	t.mu.Unlock()
+	return count > 0
+}
+
+func (t *listenerTracker) closeSessions(sessions []*listenerSession, now time.Time) {
+	for _, s := range sessions {
+		if s.id == 0 {
+			continue
+		}
+		end := s.lastSeen
+		if end.After(now) {
+			end = now
+		}
+		t.db.Model(&models.ListenerSession{}).Where("id = ?", s.id).
+			Updates(map[string]interface{}{"last_seen_at": end, "ended_at": end})
+	}
+}
+
+// handleBeacon serves /beacon?org_id=&mount=&sid=[&event=stop] for players that report their own playback.
+func (e *Engine) handleBeacon(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	orgID, err := uuid.Parse(q.Get("org_id"))
+	if err != nil {
+		http.Error(w, "Invalid org_id parameter", http.StatusBadRequest)
+		return
+	}
+	mount := q.Get("mount")
+	if mount == "" {
+		mount = "radio"
+	}
+
+	w.Header().Set("Access-Control-Allow-Origin", "*")
+	if !e.listeners.mountExists(orgID, mount) {
+		http.Error(w, "Unknown station or mount", http.StatusNotFound)
+		return
+	}
+	if q.Get("event") == "stop" {
+		e.listeners.End(r, orgID, mount, q.Get("sid"))
+	} else {
+		e.listeners.Touch(r, orgID, mount, SourceBeacon, q.Get("sid"))
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// handleHLSProxy serves GET /hls/{org_id}/{path...} in segment-proxy mode.
+// Playlists are relayed from the stream bucket so every reload counts as a heartbeat,
+// segments are redirected to the bucket/CDN so audio never flows through the engine.
+func (e *Engine) handleHLSProxy(w http.ResponseWriter, r *http.Request) {
+	orgID, err := uuid.Parse(r.PathValue("org_id"))
+	if err != nil {
+		http.Error(w, "Invalid org_id", http.StatusBadRequest)
+		return
+	}
+	path := r.PathValue("path")
+	if strings.Contains(path, "..") {
+		http.Error(w, "Invalid path", http.StatusBadRequest)
+		return
+	}
+	key := fmt.Sprintf("%s/%s", orgID, path)
+
+	// {mount}/stream.m3u8 or {mount}/segment.ts; the master playlist has no mount of its own
+	if mount, _, found := strings.Cut(path, "/"); found {
+		if !e.listeners.mountExists(orgID, mount) {
+			http.Error(w, "Unknown station or mount", http.StatusNotFound)
+			return
+		}
+		e.listeners.Touch(r, orgID, mount, SourceHLS, "")
+	}
+
+	if !strings.HasSuffix(path, ".m3u8") {
+		http.Redirect(w, r, e.streamURL(key), http.StatusFound)
+		return
+	}
+
+	obj, err := e.storage.DownloadStreamFile(key)
+	if err != nil {
+		http.Error(w, "Playlist not found", http.StatusNotFound)
+		return
+	}
+	defer obj.Body.Close()
+
+	w.Header().Set("Content-Type", "application/vnd.apple.mpegurl")
+	w.Header().Set("Cache-Control", "max-age=0, no-cache, no-store, must-revalidate")
+	w.Header().Set("Access-Control-Allow-Origin", "*")
+	io.Copy(w, obj.Body)
+}
+
+// listenURL is where /listen sends a player for the given mount ("master" for the adaptive playlist)
+func (e *Engine) listenURL(orgID, mount string) string {
+	file := fmt.Sprintf("%s/stream.m3u8", mount)
+	if mount == audio.MasterMount {
+		file = audio.MasterPlaylistName
+	}
+	if e.cfg.Radio.ListenerTracking == "proxy" {
+		return fmt.Sprintf("/hls/%s/%s", orgID, file)
+	}
+	return e.streamURL(fmt.Sprintf("%s/%s", orgID, file))
+}
+
+func (e *Engine) streamURL(key string) string {
+	endpoint := strings.TrimRight(e.cfg.Storage.Endpoint, "/")
+	return fmt.Sprintf("%s/%s/%s", endpoint, e.cfg.Storage.BucketStream, key)
+}
+
+// clientIP prefers the first X-Forwarded-For hop since the engine usually sits behind a proxy.
+// The headers are only trusted from a private or loopback peer, anyone else could make them up.
+func clientIP(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		host = r.RemoteAddr
+	}
+	if peer := net.ParseIP(host); peer == nil || !(peer.IsPrivate() || peer.IsLoopback()) {
+		return host
+	}
+
+	if fwd := r.Header.Get("X-Forwarded-For"); fwd != "" {
+		first, _, _ := strings.Cut(fwd, ",")
+		return strings.TrimSpace(first)
+	}
+	if ip := r.Header.Get("X-Real-IP"); ip != "" {
+		return ip
+	}
+	return host
+}
+
+// hashListener pseudonymizes a listener with an HMAC under a key derived from the secret and the UTC day.
+// The key changes every day, so a stored hash cannot be brute-forced back to an IP without the secret,
+// and listeners are not linkable from one day to the next.
+func (t *listenerTracker) hashListener(ip, userAgent string, at time.Time) string {
+	day := hmac.New(sha256.New, t.secret)
+	day.Write([]byte(at.UTC().Format(time.DateOnly)))
+
+	mac := hmac.New(sha256.New, day.Sum(nil))
+	mac.Write([]byte(ip + "|" + userAgent))
+	return hex.EncodeToString(mac.Sum(nil)[:16])
+}
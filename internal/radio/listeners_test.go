@@ -0,0 +1,39 @@
+package radio
+
+import (
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestClientIP(t *testing.T) {
+	r := httptest.NewRequest("GET", "/beacon", nil)
+	r.RemoteAddr = "203.0.113.7:4321"
+	r.Header.Set("X-Forwarded-For", "198.51.100.1")
+	if ip := clientIP(r); ip != "203.0.113.7" {
+		t.Errorf("forwarded header from a public peer must be ignored, got %s", ip)
+	}
+
+	r.RemoteAddr = "10.0.0.2:4321"
+	r.Header.Set("X-Forwarded-For", "198.51.100.1, 10.0.0.1")
+	if ip := clientIP(r); ip != "198.51.100.1" {
+		t.Errorf("forwarded header from the proxy must be used, got %s", ip)
+	}
+}
+
+func TestHashListener(t *testing.T) {
+	a := &listenerTracker{secret: []byte("one")}
+	b := &listenerTracker{secret: []byte("two")}
+	day := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+
+	h := a.hashListener("198.51.100.1", "VLC", day)
+	if h != a.hashListener("198.51.100.1", "VLC", day.Add(time.Hour)) {
+		t.Error("same listener on the same day must hash the same")
+	}
+	if h == a.hashListener("198.51.100.1", "VLC", day.AddDate(0, 0, 1)) {
+		t.Error("the hash must rotate daily")
+	}
+	if h == b.hashListener("198.51.100.1", "VLC", day) {
+		t.Error("the hash must depend on the secret")
+	}
+}
@@ -0,0 +1,160 @@
+package radio
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+
+	"momo-radio/internal/audio"
+	"momo-radio/internal/models"
+)
+
+const (
+	// liveConnectGrace is how long a live pull must last before we consider the DJ was on air.
+	liveConnectGrace = 5 * time.Second
+	// liveMaxConnectAttempts before giving up and handing the air back to AutoDJ.
+	liveMaxConnectAttempts = 5
+)
+
+// liveSwitch tracks whether a tenant is in live mode and lets the supervisor
+// interrupt whatever is currently on air when that changes.
+type liveSwitch struct {
+	mu       sync.Mutex
+	live     bool
+	attempts int
+	cancel   context.CancelFunc // Cancels the item currently on air
+}
+
+// Set flips the mode and cuts the current item short so the orchestrator switches right away.
+func (l *liveSwitch) Set(live bool) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if l.live == live {
+		return
+	}
+	l.live = live
+	l.attempts = 0
+	if l.cancel != nil {
+		l.cancel()
+	}
+}
+
+func (l *liveSwitch) IsLive() bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.live
+}
+
+// begin derives the context for the next item on air.
+func (l *liveSwitch) begin(parent context.Context) (context.Context, context.CancelFunc) {
+	ctx, cancel := context.WithCancel(parent)
+
+	l.mu.Lock()
+	l.cancel = cancel
+	l.mu.Unlock()
+
+	return ctx, func() {
+		l.mu.Lock()
+		l.cancel = nil
+		l.mu.Unlock()
+		cancel()
+	}
+}
+
+// failedAttempt records a live pull that never got going and reports whether to keep trying.
+func (l *liveSwitch) failedAttempt() bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.attempts++
+	return l.attempts < liveMaxConnectAttempts
+}
+
+// setLiveMode is called by the supervisor on "live_start"/"live_stop" commands
+func (e *Engine) setLiveMode(orgID uuid.UUID, live bool) {
+	sw, running := e.liveSwitches.Load(orgID)
+	if !running {
+		log.Printf("[%s] Live command received but no pipeline is running here.", orgID)
+		return
+	}
+
+	if live {
+		log.Printf("[%s] 🎙️ Live takeover requested, fading out AutoDJ", orgID)
+	} else {
+		log.Printf("[%s] Live session ended, handing air back to AutoDJ", orgID)
+	}
+	sw.(*liveSwitch).Set(live)
+}
+
+// playLive splices the DJ's feed into the tenant's playout until the publisher disconnects
+func (e *Engine) playLive(ctx context.Context, orgID uuid.UUID, mount models.MountPoint, mixer *audio.Mixer, sw *liveSwitch) {
+	var org models.Organization
+	if err := e.db.DB.Select("id", "name", "stream_key").First(&org, "id = ?", orgID).Error; err != nil {
+		log.Printf("[%s] Live takeover aborted, organization lookup failed: %v", orgID, err)
+		e.endLive(orgID, sw)
+		return
+	}
+
+	url := liveIngestURL(e.cfg.Radio.LiveIngestURL, org.StreamKey)
+	go e.updateNowPlayingLive(orgID, org.Name)
+
+	liveCtx, done := sw.begin(ctx)
+	started := time.Now()
+	err := mixer.PlayLive(liveCtx, url, mixOptions(mount, nil))
+	done()
+
+	// Stopped by the supervisor (live_stop or shutdown): nothing else to do
+	if ctx.Err() != nil || !sw.IsLive() {
+		return
+	}
+
+	// The publisher may not be pushing data yet right after the auth webhook
+	if time.Since(started) < liveConnectGrace {
+		if sw.failedAttempt() {
+			log.Printf("[%s] Live feed not available yet (%v), retrying...", orgID, err)
+			time.Sleep(1 * time.Second)
+			return
+		}
+		log.Printf("[%s] Live feed never came up, falling back to AutoDJ", orgID)
+	} else {
+		log.Printf("[%s] Live publisher disconnected, falling back to AutoDJ", orgID)
+	}
+
+	e.endLive(orgID, sw)
+}
+
+func (e *Engine) endLive(orgID uuid.UUID, sw *liveSwitch) {
+	sw.Set(false)
+	if err := e.state.SetBroadcastMode(orgID, ModeAutoDJ); err != nil {
+		log.Printf("[%s] Failed to reset broadcast mode: %v", orgID, err)
+	}
+}
+
+func (e *Engine) updateNowPlayingLive(orgID uuid.UUID, stationName string) {
+	data, err := json.Marshal(CurrentTrack{
+		Title:     "Live Broadcast",
+		Artist:    stationName,
+		Show:      "Live",
+		StartedAt: time.Now().Unix(),
+	})
+	if err != nil {
+		return
+	}
+
+	destKey := fmt.Sprintf("%s/now_playing.json", orgID.String())
+	e.storage.UploadStreamFile(destKey, bytes.NewReader(data), "application/json", "max-age=0, no-cache")
+
+	e.setIcecastTitle(orgID, fmt.Sprintf("%s - Live Broadcast", stationName))
+}
+
+// liveIngestURL fills the stream key into the configured RTMP/SRT pull template
+func liveIngestURL(template, streamKey string) string {
+	return strings.ReplaceAll(template, "{stream_key}", streamKey)
+}
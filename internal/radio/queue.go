@@ -0,0 +1,254 @@
+package radio
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+
+	"momo-radio/internal/dj"
+	"momo-radio/internal/models"
+)
+
+// maxQueuePickAttempts bounds retries when the selector keeps returning tracks already queued
+const maxQueuePickAttempts = 3
+
+// queuePick is a track chosen for the queue and where it came from
+type queuePick struct {
+	track         *models.Track
+	ruleSetID     *uint
+	cursorID      *uint // Playlist shows: the cursor and position it was taken from
+	playlistIndex *int
+}
+
+// trackPicker chooses the track to follow prev, expected on air at `at`, with the selection rules currently on air
+type trackPicker func(prev *models.Track, at time.Time) (queuePick, error)
+
+// queueDepth is how many upcoming tracks are selected (and downloaded) ahead of time
+func (e *Engine) queueDepth() int {
+	if e.cfg.Radio.PrefetchCount < 1 {
+		return 1
+	}
+	return e.cfg.Radio.PrefetchCount
+}
+
+// loadQueue returns the tenant's upcoming tracks in play order
+func (e *Engine) loadQueue(orgID uuid.UUID) ([]models.QueueItem, error) {
+	var items []models.QueueItem
+	err := e.db.DB.Preload("Track").Preload("Track.Artists").Preload("Track.Album").
+		Where("organization_id = ?", orgID).
+		Order("position ASC, id ASC").
+		Find(&items).Error
+	return items, err
+}
+
+// fillQueue drops AutoDJ picks made for another show, then tops the queue up to queueDepth
+// by chaining picks after the last queued track. now is when the first queued track goes on air.
+func (e *Engine) fillQueue(orgID uuid.UUID, slotKey string, current *models.Track, now time.Time, pick trackPicker) []models.QueueItem {
+	// 1. The show changed: unpinned AutoDJ picks no longer follow the rules on air
+	e.db.DB.Where("organization_id = ? AND source = ? AND pinned = ? AND slot_key <> ?", orgID, models.QueueSourceAutoDJ, false, slotKey).
+		Delete(&models.QueueItem{})
+
+	items, err := e.loadQueue(orgID)
+	if err != nil {
+		log.Printf("[%s] Failed to load playout queue: %v", orgID, err)
+		return nil
+	}
+
+	queued := make(map[uint]bool)
+	prev := current
+	position := 0
+	at := now
+	for i := range items {
+		queued[items[i].TrackID] = true
+		prev = &items[i].Track
+		position = items[i].Position + 1
+		at = at.Add(trackLength(prev))
+	}
+	if current != nil {
+		queued[current.ID] = true
+	}
+
+	// 2. Top up, avoiding tracks that are already on their way
+	added := false
+	for len(items) < e.queueDepth() {
+		var picked queuePick
+		for attempt := 0; attempt < maxQueuePickAttempts; attempt++ {
+			picked, err = pick(prev, at)
+			if err != nil || picked.track == nil || !queued[picked.track.ID] {
+				break
+			}
+		}
+		track := picked.track
+		if err != nil || track == nil || track.ID == 0 || track.Key == "" {
+			break
+		}
+
+		item := models.QueueItem{
+			OrganizationID: orgID,
+			Position:       position,
+			TrackID:        track.ID,
+			Source:         models.QueueSourceAutoDJ,
+			SlotKey:        slotKey,
+			RuleSetID:      picked.ruleSetID,
+			CursorID:       picked.cursorID,
+			PlaylistIndex:  picked.playlistIndex,
+		}
+		if err := e.db.DB.Create(&item).Error; err != nil {
+			log.Printf("[%s] Failed to queue track %d: %v", orgID, track.ID, err)
+			break
+		}
+		item.Track = *track
+
+		items = append(items, item)
+		queued[track.ID] = true
+		prev = track
+		position++
+		at = at.Add(trackLength(track))
+		added = true
+	}
+
+	// Selectors do not preload relations, reload so now-playing gets artists and album
+	if added {
+		if reloaded, err := e.loadQueue(orgID); err == nil {
+			items = reloaded
+		}
+	}
+	return items
+}
+
+// popQueue removes and returns the first playable item
+func (e *Engine) popQueue(orgID uuid.UUID, items []models.QueueItem) *models.QueueItem {
+	for i := range items {
+		item := &items[i]
+
+		// Concurrent edits may have removed it already: only play what we actually took off the queue
+		res := e.db.DB.Where("id = ? AND organization_id = ?", item.ID, orgID).Delete(&models.QueueItem{})
+		if res.Error != nil || res.RowsAffected == 0 {
+			continue
+		}
+		if item.Track.ID == 0 || item.Track.Key == "" {
+			log.Printf("[%s] Skipping queue item %d: track no longer available", orgID, item.ID)
+			continue
+		}
+		return item
+	}
+	return nil
+}
+
+// prefetchQueue downloads every queued track and lets the cache drop what is no longer needed.
+// extra keys (e.g. a talk-over on the intro) and the tenant's imaging are kept as well.
+func (e *Engine) prefetchQueue(orgID uuid.UUID, onAir *models.Track, extra ...string) {
+	items, err := e.loadQueue(orgID)
+	if err != nil {
+		return
+	}
+
+	keys := make([]string, 0, len(items)+1+len(extra))
+	if onAir != nil && onAir.Key != "" {
+		keys = append(keys, onAir.Key)
+	}
+	for _, k := range extra {
+		if k != "" {
+			keys = append(keys, k)
+		}
+	}
+	for _, item := range items {
+		if item.Track.Key != "" {
+			keys = append(keys, item.Track.Key)
+		}
+	}
+
+	e.cache.Prefetch(keys)
+
+	// Imaging the rules can pick stays cached once downloaded, so inserting it (or voicing it over an
+	// intro, even while the queue changes) does not fetch it again every time
+	e.cache.Cleanup(orgID.String(), append(keys, e.imagingKeys(orgID)...))
+}
+
+// imagingKeys lists the assets of every kind the tenant's enabled insertion rules draw from
+func (e *Engine) imagingKeys(orgID uuid.UUID) []string {
+	var keys []string
+	err := e.db.DB.Model(&models.Track{}).
+		Where("organization_id = ? AND key <> '' AND kind IN (?)", orgID,
+			e.db.DB.Model(&models.InsertionRule{}).Select("kind").Where("organization_id = ? AND enabled = ?", orgID, true)).
+		Pluck("key", &keys).Error
+	if err != nil {
+		log.Printf("[%s] Failed to list imaging to keep cached: %v", orgID, err)
+	}
+	return keys
+}
+
+// onQueueChanged is called by the supervisor when an editor touched the queue
+func (e *Engine) onQueueChanged(orgID uuid.UUID) {
+	if _, running := e.activeStreams.Load(orgID); !running {
+		return
+	}
+
+	var state models.StreamState
+	var onAir *models.Track
+	if err := e.db.DB.Where("organization_id = ?", orgID).First(&state).Error; err == nil && state.TrackID != 0 {
+		var track models.Track
+		if e.db.DB.Select("id", "key").First(&track, state.TrackID).Error == nil {
+			onAir = &track
+		}
+	}
+	e.prefetchQueue(orgID, onAir)
+}
+
+// slotKey identifies the show an AutoDJ pick was made for
+func slotKey(slot *models.ScheduleSlot) string {
+	if slot == nil || slot.ID == 0 {
+		return "fallback"
+	}
+	return fmt.Sprintf("slot:%d", slot.ID)
+}
+
+var errNoSelection = errors.New("no selection rules on air")
+
+// newTrackPicker binds the on-air slot to the selector that should fill the queue.
+// Playlist shows continue from the slot's cursor; play_once hands over to the slot's RuleSet when the playlist is done.
+func (e *Engine) newTrackPicker(orgID uuid.UUID, slot *models.ScheduleSlot, now time.Time, selectors map[string]dj.Selector) trackPicker {
+	var playlist *playlistPicker
+	if slot != nil && slot.PlaylistID != nil {
+		var err error
+		if playlist, err = e.openPlaylist(orgID, slot, now); err != nil {
+			log.Printf("[%s] Playlist %d of slot %d unavailable: %v", orgID, *slot.PlaylistID, slot.ID, err)
+		}
+	}
+
+	return func(prev *models.Track, at time.Time) (queuePick, error) {
+		var picked queuePick
+		err := errNoSelection
+
+		if playlist != nil {
+			picked, err = playlist.pick()
+		}
+		if (playlist == nil || errors.Is(err, errPlaylistDone)) && slot != nil && slot.RuleSetID != nil {
+			mode := "random"
+			if slot.RuleSet != nil && slot.RuleSet.Mode != "" {
+				mode = strings.ToLower(slot.RuleSet.Mode)
+			}
+			selector, exists := selectors[mode]
+			if !exists {
+				selector = selectors["random"]
+			}
+			picked = queuePick{ruleSetID: slot.RuleSetID}
+			if ps, ok := selector.(dj.ProgressSelector); ok {
+				// Curve programming: aim at where in the show the track will air
+				picked.track, err = ps.PickTrackAt(slot.RuleSet, prev, slotProgress(slot, at))
+			} else {
+				picked.track, err = selector.PickTrack(slot.RuleSet, prev)
+			}
+		}
+
+		if err != nil || picked.track == nil {
+			picked = queuePick{}
+			picked.track, err = selectors["random"].PickTrack(nil, nil)
+		}
+		return picked, err
+	}
+}
@@ -0,0 +1,134 @@
+package radio
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+
+	"momo-radio/internal/audio"
+	"momo-radio/internal/models"
+)
+
+// fanoutWriter duplicates the mixed PCM into every rendition encoder.
+// A rendition whose encoder died is dropped instead of stalling the others; the watchdog adds it back
+// once restarted. While no encoder is attached the audio is discarded at real-time speed.
+type fanoutWriter struct {
+	orgID   uuid.UUID
+	mu      sync.Mutex
+	outputs map[string]*io.PipeWriter // Key: mount slug
+	closed  bool
+
+	// Read by the watchdog while a Write may be holding mu
+	stat         sync.Mutex
+	lastWrite    time.Time // When the playout last handed over audio
+	writing      string    // Output a Write is waiting on
+	writingTo    *io.PipeWriter
+	writingSince time.Time
+}
+
+func newFanoutWriter(orgID uuid.UUID) *fanoutWriter {
+	return &fanoutWriter{orgID: orgID, outputs: make(map[string]*io.PipeWriter)}
+}
+
+func (f *fanoutWriter) Add(slug string, w *io.PipeWriter) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.outputs[slug] = w
+}
+
+func (f *fanoutWriter) Write(p []byte) (int, error) {
+	f.mu.Lock()
+	if f.closed {
+		f.mu.Unlock()
+		return 0, io.ErrClosedPipe
+	}
+
+	f.stat.Lock()
+	f.lastWrite = time.Now()
+	f.stat.Unlock()
+
+	for slug, w := range f.outputs {
+		f.setWriting(slug, w)
+		_, err := w.Write(p)
+		f.setWriting("", nil)
+
+		if err != nil {
+			log.Printf("[%s] Rendition '%s' dropped from playout: %v", f.orgID, slug, err)
+			delete(f.outputs, slug)
+		}
+	}
+	idle := len(f.outputs) == 0
+	f.mu.Unlock()
+
+	if idle {
+		// ⚡️ Every encoder is being restarted: keep the playout at real-time speed meanwhile
+		time.Sleep(time.Duration(len(p)) * time.Second / (audio.PCMSampleRate * audio.PCMChannels * 2))
+	}
+	return len(p), nil
+}
+
+func (f *fanoutWriter) setWriting(slug string, w *io.PipeWriter) {
+	f.stat.Lock()
+	defer f.stat.Unlock()
+	f.writing, f.writingTo, f.writingSince = slug, w, time.Now()
+}
+
+// Fed reports whether the playout handed over audio within d
+func (f *fanoutWriter) Fed(d time.Duration) bool {
+	f.stat.Lock()
+	defer f.stat.Unlock()
+	return time.Since(f.lastWrite) < d
+}
+
+// Blocked names the output a Write has been waiting on, and since when ("" when none)
+func (f *fanoutWriter) Blocked() (string, time.Time) {
+	f.stat.Lock()
+	defer f.stat.Unlock()
+	return f.writing, f.writingSince
+}
+
+// Abort fails the pending Write to slug, which drops that output
+func (f *fanoutWriter) Abort(slug string, err error) {
+	f.stat.Lock()
+	defer f.stat.Unlock()
+	if f.writing == slug && f.writingTo != nil {
+		f.writingTo.CloseWithError(err)
+	}
+}
+
+func (f *fanoutWriter) Close() error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f.closed = true
+	for _, w := range f.outputs {
+		w.Close()
+	}
+	return nil
+}
+
+// uploadMasterPlaylist publishes {org}/master.m3u8 pointing at each mount's stream.m3u8
+func (e *Engine) uploadMasterPlaylist(orgID uuid.UUID, mounts []models.MountPoint) error {
+	renditions := make([]audio.Rendition, 0, len(mounts))
+	for _, m := range mounts {
+		renditions = append(renditions, audio.Rendition{
+			Name:    m.Name,
+			URI:     fmt.Sprintf("%s/stream.m3u8", m.Slug),
+			Bitrate: m.Bitrate,
+		})
+	}
+
+	data := audio.BuildMasterPlaylist(renditions, audio.StreamCodec(e.cfg))
+	destKey := fmt.Sprintf("%s/%s", orgID.String(), audio.MasterPlaylistName)
+
+	err := e.storage.UploadStreamFile(destKey, bytes.NewReader(data), "application/vnd.apple.mpegurl", "max-age=0, no-cache, no-store, must-revalidate")
+	if err == nil {
+		uploadsTotal.WithLabelValues("master", orgID.String()).Inc()
+	}
+	return err
+}
@@ -0,0 +1,52 @@
+package radio
+
+import (
+	"log"
+	"time"
+
+	"github.com/google/uuid"
+
+	"momo-radio/internal/models"
+)
+
+// finishTrackPlay closes the PlayHistory row once the track is off air and, after the
+// listener sessions around it have settled, stores who was tuned in and who left.
+// Only listeners present when the track started count as tune-outs, so the rate stays within 0..1.
+func (e *Engine) finishTrackPlay(orgID uuid.UUID, played <-chan uint, startedAt, endedAt time.Time) {
+	historyID := <-played
+	if historyID == 0 {
+		return
+	}
+
+	e.db.DB.Model(&models.PlayHistory{}).Where("id = ?", historyID).Update("ended_at", endedAt)
+
+	// Sessions that stopped during the track are only closed once they miss their heartbeats
+	time.Sleep(e.listeners.timeout + listenerSampleInterval)
+
+	var counts struct {
+		ListenersStart int
+		ListenersEnd   int
+		TuneOuts       int
+	}
+	err := e.db.DB.Raw(`SELECT
+			COUNT(*) FILTER (WHERE started_at <= ? AND (ended_at IS NULL OR ended_at > ?)) AS listeners_start,
+			COUNT(*) FILTER (WHERE started_at <= ? AND (ended_at IS NULL OR ended_at > ?)) AS listeners_end,
+			COUNT(*) FILTER (WHERE started_at <= ? AND ended_at > ? AND ended_at <= ?) AS tune_outs
+		FROM listener_sessions
+		WHERE organization_id = ? AND started_at <= ? AND (ended_at IS NULL OR ended_at > ?)`,
+		startedAt, startedAt,
+		endedAt, endedAt,
+		startedAt, startedAt, endedAt,
+		orgID, endedAt, startedAt,
+	).Scan(&counts).Error
+	if err != nil {
+		log.Printf("[%s] Failed to measure listener retention for play %d: %v", orgID, historyID, err)
+		return
+	}
+
+	e.db.DB.Model(&models.PlayHistory{}).Where("id = ?", historyID).Updates(map[string]any{
+		"listeners_start": counts.ListenersStart,
+		"listeners_end":   counts.ListenersEnd,
+		"tune_outs":       counts.TuneOuts,
+	})
+}
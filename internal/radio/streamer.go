@@ -10,10 +10,10 @@ import (
 	"net/http"
 	"os"
 	"path/filepath"
-	"regexp"
 	"strconv"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/google/uuid"
@@ -29,6 +29,7 @@ import (
 	"momo-radio/internal/models"
 	"momo-radio/internal/scheduler"
 	"momo-radio/internal/storage"
+	"momo-radio/internal/utils"
 )
 
 // --- METRICS ---
@@ -41,6 +42,18 @@ var (
 		prometheus.CounterOpts{Name: "radio_hls_uploads_total", Help: "HLS uploads"},
 		[]string{"type", "organization_id"},
 	)
+	icecastListeners = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{Name: "radio_icecast_listeners", Help: "Connected direct (ICY) listeners"},
+		[]string{"organization_id", "mount"},
+	)
+	listenersCurrent = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{Name: "radio_listeners_current", Help: "Concurrent listeners across all mounts"},
+		[]string{"organization_id"},
+	)
+	listeningSeconds = prometheus.NewCounterVec(
+		prometheus.CounterOpts{Name: "radio_listening_seconds_total", Help: "Total time spent listening"},
+		[]string{"organization_id"},
+	)
 	uploadDuration = prometheus.NewHistogramVec(
 		prometheus.HistogramOpts{
 			Name:    "radio_hls_upload_duration_seconds",
@@ -52,7 +65,7 @@ var (
 )
 
 func RegisterMetrics() {
-	prometheus.MustRegister(tracksPlayed, uploadsTotal, uploadDuration)
+	prometheus.MustRegister(tracksPlayed, uploadsTotal, uploadDuration, icecastListeners, listenersCurrent, listeningSeconds, pipelineRestarts, deadAirEvents, deadAirActive)
 }
 
 // --- ENGINE ---
@@ -63,10 +76,16 @@ type Engine struct {
 	db            *database.Client
 	rdb           *redis.Client
 	runID         int64
+	nodeID        string      // This engine's name in the cluster, see cluster.go
+	draining      atomic.Bool // Handing tenants over before shutdown, claims nothing new
 	cache         *CacheManager
 	state         *StateManager
 	scheduler     *scheduler.Manager
-	activeStreams sync.Map // Tracks cancellation channels. Key: uuid.UUID, Value: context.CancelFunc
+	listeners     *listenerTracker
+	activeStreams sync.Map // Pipelines running on this node. Key: uuid.UUID, Value: *tenantRun
+	liveSwitches  sync.Map // Live takeover control per running tenant. Key: uuid.UUID, Value: *liveSwitch
+	icecastMounts sync.Map // Direct ICY outputs. Key: "orgID/slug", Value: *icecastMount
+	fallbackLocks sync.Map // Serializes fallback loop downloads per tenant. Key: uuid.UUID, Value: *sync.Mutex
 }
 
 type CurrentTrack struct {
@@ -92,15 +111,33 @@ func (a *s3Adapter) DownloadFile(key string) (io.ReadCloser, error) {
 func New(cfg *config.Config, store *storage.Client, db *database.Client, rdb *redis.Client) *Engine {
 	adapter := &s3Adapter{store: store}
 
+	var geo *utils.GeoIPDB
+	if cfg.Radio.GeoIPDBPath != "" {
+		var err error
+		if geo, err = utils.LoadGeoIPDB(cfg.Radio.GeoIPDBPath); err != nil {
+			log.Printf("⚠️ GeoIP database not loaded, listener countries will be unknown: %v", err)
+		} else {
+			log.Printf("Loaded %d GeoIP ranges from %s", geo.Len(), cfg.Radio.GeoIPDBPath)
+		}
+	}
+
+	runID := time.Now().Unix()
+	nodeID := cfg.Radio.NodeID
+	if nodeID == "" {
+		nodeID = defaultNodeID(runID)
+	}
+
 	return &Engine{
 		cfg:       cfg,
 		storage:   store,
 		db:        db,
 		rdb:       rdb,
-		runID:     time.Now().Unix(),
+		runID:     runID,
+		nodeID:    nodeID,
 		cache:     NewCacheManager(adapter, cfg.Server.TempDir),
 		state:     NewStateManager(db.DB),
 		scheduler: scheduler.NewManager(db.DB, cfg.Server.Timezone),
+		listeners: newListenerTracker(db.DB, geo, time.Duration(cfg.Radio.ListenerTimeout)*time.Second, cfg.Radio.ListenerSecret),
 	}
 }
 
@@ -111,11 +148,13 @@ func (e *Engine) StartSupervisor(ctx context.Context) {
 		return
 	}
 
-	log.Printf("Engine Run ID: %d", e.runID)
+	log.Printf("Engine Run ID: %d, node: %s", e.runID, e.nodeID)
 	go e.startRedirectServer()
+	go e.listeners.Run(ctx)
 
-	log.Println("Bootstrapping active tenants from database state...")
-	e.bootstrapActiveStreams(ctx)
+	// Claims this node's share of the tenants right away, then keeps the leases alive
+	log.Println("Joining the engine cluster...")
+	go e.runCluster(ctx)
 
 	pubsub := e.rdb.Subscribe(ctx, "radio.control")
 	defer pubsub.Close()
@@ -123,10 +162,24 @@ func (e *Engine) StartSupervisor(ctx context.Context) {
 	ch := pubsub.Channel()
 	log.Println("🎧 Radio Supervisor is listening for commands on channel: radio.control")
 
-	for msg := range ch {
+	for {
+		var msg *redis.Message
+		select {
+		case <-ctx.Done():
+			// Shutting down: hand the tenants over now instead of waiting for the leases to expire
+			e.drain()
+			return
+		case m, open := <-ch:
+			if !open {
+				return
+			}
+			msg = m
+		}
+
 		var payload struct {
 			OrgID  string `json:"org_id"`
 			Action string `json:"action"`
+			Node   string `json:"node"` // drain only
 		}
 
 		if err := json.Unmarshal([]byte(msg.Payload), &payload); err != nil {
@@ -134,6 +187,13 @@ func (e *Engine) StartSupervisor(ctx context.Context) {
 			continue
 		}
 
+		if payload.Action == "drain" {
+			if payload.Node == e.nodeID {
+				go e.drain()
+			}
+			continue
+		}
+
 		orgUUID, err := uuid.Parse(payload.OrgID)
 		if err != nil {
 			log.Printf("Invalid UUID parsed from payload: %v", err)
@@ -145,69 +205,83 @@ func (e *Engine) StartSupervisor(ctx context.Context) {
 			e.handleStart(ctx, orgUUID)
 		case "stop":
 			e.handleStop(orgUUID)
+		case "live_start":
+			e.setLiveMode(orgUUID, true)
+		case "live_stop":
+			e.setLiveMode(orgUUID, false)
+		case "queue":
+			go e.onQueueChanged(orgUUID)
+		case "settings":
+			e.scheduler.InvalidateSettings(orgUUID)
 		}
 	}
 }
 
-func (e *Engine) bootstrapActiveStreams(ctx context.Context) {
-	var defaultMounts []models.MountPoint
-
-	// Fetch every default mount point across all tenants
-	err := e.db.DB.Where("is_default = ?", true).Find(&defaultMounts).Error
-	if err != nil {
-		log.Printf("Failed to query default mount points during bootstrap: %v", err)
+// handleStart is broadcast to every node: the first to take the lease runs the tenant
+func (e *Engine) handleStart(ctx context.Context, orgID uuid.UUID) {
+	if e.isRunning(orgID) {
+		log.Printf("[%s] Pipeline instance already running on this node. Skipping invocation.", orgID)
 		return
 	}
+	e.claim(ctx, orgID)
+}
 
-	log.Printf("Found %d tenant streams to restore.", len(defaultMounts))
-
-	for _, mount := range defaultMounts {
-		log.Printf("Auto-restoring pipeline for tenant: %s", mount.OrganizationID)
-		e.handleStart(ctx, mount.OrganizationID)
+// handleStop is broadcast to every node: the one running the tenant stops it and frees the lease
+func (e *Engine) handleStop(orgID uuid.UUID) {
+	if !e.isRunning(orgID) {
+		return
 	}
+	log.Printf("⏹️ Dismantling live execution pipeline for Tenant: %s", orgID)
+	e.releaseTenant(orgID)
 }
 
-func (e *Engine) handleStart(parentCtx context.Context, orgID uuid.UUID) {
-	if _, running := e.activeStreams.Load(orgID); running {
-		log.Printf("[%s] Pipeline instance already running on this cluster segment. Skpping invocation.", orgID)
+// startLocal launches the tenant's pipeline on this node; the caller holds the lease
+func (e *Engine) startLocal(parentCtx context.Context, orgID uuid.UUID) {
+	streamCtx, cancelFunc := context.WithCancel(parentCtx)
+	run := &tenantRun{cancel: cancelFunc}
+	if _, running := e.activeStreams.LoadOrStore(orgID, run); running {
+		cancelFunc()
 		return
 	}
 
 	log.Printf("▶️ Launching live transmission infrastructure for Tenant: %s", orgID)
-	streamCtx, cancelFunc := context.WithCancel(parentCtx)
-	e.activeStreams.Store(orgID, cancelFunc)
-
 	go func() {
-		defer e.activeStreams.Delete(orgID)
 		e.runTenantPipeline(streamCtx, orgID)
+
+		// Ended on its own (not stopped): free the lease so the tenant can be picked up again
+		if e.activeStreams.CompareAndDelete(orgID, run) {
+			cancelFunc()
+			releaseLease.Run(context.Background(), e.rdb, []string{leaseKey(orgID)}, e.nodeID)
+		}
 	}()
 }
 
-func (e *Engine) handleStop(orgID uuid.UUID) {
-	cancelInterface, running := e.activeStreams.Load(orgID)
-	if !running {
-		log.Printf("[%s] Stop command received but no active context was discovered here.", orgID)
-		return
+// stopLocal cancels the tenant's pipeline on this node, leaving the lease alone
+func (e *Engine) stopLocal(orgID uuid.UUID) {
+	if run, running := e.activeStreams.LoadAndDelete(orgID); running {
+		run.(*tenantRun).cancel()
 	}
-
-	log.Printf("⏹️ Dismantling live execution pipeline for Tenant: %s", orgID)
-	cancelFunc := cancelInterface.(context.CancelFunc)
-	cancelFunc()
-
-	e.activeStreams.Delete(orgID)
 }
 
 func (e *Engine) runTenantPipeline(ctx context.Context, orgID uuid.UUID) {
-	var defaultMount models.MountPoint
-	err := e.db.DB.Where("organization_id = ? AND is_default = ?", orgID, true).First(&defaultMount).Error
-	if err != nil {
+	// Every mount point becomes a rendition of the same playout; the default one drives transitions
+	var mounts []models.MountPoint
+	err := e.db.DB.Where("organization_id = ?", orgID).Order("is_default DESC, bitrate DESC").Find(&mounts).Error
+	if err != nil || len(mounts) == 0 || !mounts[0].IsDefault {
 		log.Printf("[%s] Aborting: No active default mount point found.", orgID)
 		return
 	}
 
-	segmentDir := filepath.Join(e.cfg.Radio.SegmentDir, orgID.String(), defaultMount.Slug)
-	os.RemoveAll(segmentDir)
-	os.MkdirAll(segmentDir, 0755)
+	// Station-wide encoder settings; the sound itself is per mount
+	var settings models.OrganizationSettings
+	e.db.DB.Select("ffmpeg_bitrate", "ffmpeg_sample_rate").Where("organization_id = ?", orgID).Limit(1).Find(&settings)
+	for i := range mounts {
+		if mounts[i].Bitrate <= 0 {
+			mounts[i].Bitrate = parseKbps(settings.FFmpegBitrate)
+		}
+	}
+	defaultMount := mounts[0]
+	e.closeStaleIncidents(orgID)
 
 	state, err := e.state.GetCurrentState(orgID)
 	startSequence := 0
@@ -219,29 +293,74 @@ func (e *Engine) runTenantPipeline(ctx context.Context, orgID uuid.UUID) {
 		resumeTrackID = state.TrackID
 	}
 
-	pr, pw := io.Pipe()
+	output := newFanoutWriter(orgID)
+	var uploaders sync.WaitGroup
+	var renditions []*renditionHealth
 
-	// Handle pipe termination cleanly when context finishes
-	go func() {
-		<-ctx.Done()
-		pr.Close()
-		pw.Close()
-	}()
+	for _, mount := range mounts {
+		segmentDir := filepath.Join(e.cfg.Radio.SegmentDir, orgID.String(), mount.Slug)
+		os.RemoveAll(segmentDir)
+		os.MkdirAll(segmentDir, 0755)
+
+		health := newRenditionHealth(mount.Slug, segmentDir, int64(startSequence))
+		renditions = append(renditions, health)
 
-	// Audio ingestion consumer
-	go audio.StartStreamProcess(pr, e.cfg, e.runID, int64(startSequence), segmentDir, defaultMount.Bitrate)
+		// The playout is mixed at the default mount's loudness target, other mounts are trimmed to theirs,
+		// then run through the mount's processing chain
+		trim := mountTarget(mount) - mountTarget(defaultMount)
+		opts := audio.EncoderOptions{
+			Filter:     joinFilters(e.cfg.Radio.AudioFilter, audio.ProcessingFilter(mount.Processing, mount.Bitrate, trim)),
+			SampleRate: settings.FFmpegSampleRate,
+		}
+
+		// Audio ingestion consumer, restarted by the watchdog. Same input, run ID, start number and
+		// hls_time across renditions keeps segment boundaries aligned for adaptive switching.
+		go e.superviseEncoder(ctx, orgID, mount, health, output, int64(startSequence), opts)
+
+		// Continuous MP3 output for hardware radios and directories
+		if e.cfg.Radio.IcecastEnabled {
+			icyReader, icyWriter := io.Pipe()
+			output.Add(mount.Slug+"/icecast", icyWriter)
+
+			go func() {
+				<-ctx.Done()
+				icyReader.Close()
+				icyWriter.Close()
+			}()
+
+			go func(m models.MountPoint) {
+				e.runIcecastMount(ctx, orgID, m, icyReader, opts)
+				icyReader.CloseWithError(fmt.Errorf("direct encoder for mount '%s' exited", m.Slug))
+			}(mount)
+		}
+
+		// Direct Object Storage Uploader thread
+		uploaders.Add(1)
+		go func(primary bool) {
+			defer uploaders.Done()
+			e.superviseUploader(ctx, orgID, health, primary)
+		}(mount.ID == defaultMount.ID)
+	}
+
+	go e.watchStalls(ctx, orgID, renditions, output)
+
+	if err := e.uploadMasterPlaylist(orgID, mounts); err != nil {
+		log.Printf("[%s] Failed to upload master playlist: %v", orgID, err)
+	}
 
 	// Orchestrator producer loop
-	go e.runOrchestrator(ctx, orgID, pw, resumeTrackID)
+	go e.runOrchestrator(ctx, orgID, defaultMount, output, resumeTrackID)
 
-	// Direct Object Storage Uploader thread
-	e.startStreamUploader(ctx, orgID, defaultMount.Slug, segmentDir)
+	uploaders.Wait()
 }
 
 func getShowName(slot *models.ScheduleSlot) string {
 	if slot == nil || slot.ScheduleType == "fallback" {
 		return "General Rotation"
 	}
+	if slot.Name != "" {
+		return slot.Name
+	}
 	if slot.Playlist != nil {
 		return slot.Playlist.Name
 	}
@@ -251,16 +370,36 @@ func getShowName(slot *models.ScheduleSlot) string {
 	return "Momo Radio"
 }
 
-func (e *Engine) runOrchestrator(ctx context.Context, orgID uuid.UUID, output *io.PipeWriter, resumeID uint) {
+func (e *Engine) runOrchestrator(ctx context.Context, orgID uuid.UUID, mount models.MountPoint, output io.WriteCloser, resumeID uint) {
 	defer output.Close()
 
+	// Everything the mixer sends on air is metered for dead air
+	monitor := newDeadAirMonitor(output, e.silenceThreshold())
+	mixer := audio.NewMixer(monitor)
+	defer mixer.Flush()
+	defer e.cache.Release(orgID.String())
+
+	live := &liveSwitch{}
+	e.liveSwitches.Store(orgID, live)
+	defer e.liveSwitches.Delete(orgID)
+
+	go e.watchDeadAir(ctx, orgID, mount, monitor, live)
+	go e.fallbackPath(orgID) // Have the loop on disk before it is needed
+
 	selectors := map[string]dj.Selector{
 		"random":     dj.NewSelector("random", e.db.DB, orgID),
+		"harmonic":   dj.NewSelector("harmonic", e.db.DB, orgID),
 		"starvation": dj.NewSelector("starvation", e.db.DB, orgID),
+		"curve":      dj.NewSelector("curve", e.db.DB, orgID),
 	}
 
 	var lastTrack *models.Track
 	firstRun := true
+	imaging := newImagingState()
+
+	// A song already taken off the queue when a live takeover interrupted the imaging before it
+	var heldTrack *models.Track
+	var heldRuleSetID *uint
 
 	for {
 		select {
@@ -268,8 +407,24 @@ func (e *Engine) runOrchestrator(ctx context.Context, orgID uuid.UUID, output *i
 			log.Printf("[%s] Orchestrator loop terminated by supervisor context", orgID)
 			return
 		default:
+			// The auth webhook may have flipped the mode while we were not subscribed
+			if state, err := e.state.GetCurrentState(orgID); err == nil && state.BroadcastMode == ModeLive {
+				live.Set(true)
+			}
+
+			if live.IsLive() {
+				e.playLive(ctx, orgID, mount, mixer, live)
+				continue
+			}
+
+			// Dead air cut the last item short: cover it before picking anything else
+			if monitor.takeFallback() {
+				e.playFallback(ctx, orgID, mixer, mountTarget(mount), live)
+				continue
+			}
+
 			var selectedTrack *models.Track
-			var err error
+			var ruleSetID *uint // Set when a RuleSet picked the track, for retention reporting
 
 			if firstRun && resumeID != 0 {
 				if dbErr := e.db.DB.Preload("Artists").Where("organization_id = ?", orgID).First(&selectedTrack, resumeID).Error; dbErr == nil {
@@ -278,88 +433,94 @@ func (e *Engine) runOrchestrator(ctx context.Context, orgID uuid.UUID, output *i
 				firstRun = false
 			}
 
+			if selectedTrack == nil && heldTrack != nil {
+				selectedTrack, ruleSetID = heldTrack, heldRuleSetID
+				heldTrack, heldRuleSetID = nil, nil
+			}
+
+			now := e.scheduler.Now(orgID)
+			activeSlot, boundary, hasBoundary := e.resolveProgramme(orgID, now)
+
+			// Upcoming tracks are chosen ahead of time so they can be shown, edited and downloaded
 			if selectedTrack == nil {
-				activeSlot := e.scheduler.GetCurrentSchedule(orgID)
-
-				if activeSlot != nil && activeSlot.PlaylistID != nil {
-					selectedTrack, err = e.pickNextFromPlaylist(orgID, *activeSlot.PlaylistID, lastTrack)
-				} else if activeSlot != nil && activeSlot.RuleSetID != nil {
-					mode := "random"
-					if activeSlot.RuleSet != nil && activeSlot.RuleSet.Mode != "" {
-						mode = strings.ToLower(activeSlot.RuleSet.Mode)
-					}
-					selector, exists := selectors[mode]
-					if !exists {
-						selector = selectors["random"]
-					}
-					selectedTrack, err = selector.PickTrack(activeSlot.RuleSet, lastTrack)
+				picker := e.newTrackPicker(orgID, activeSlot, now, selectors)
+				queue := e.fillQueue(orgID, slotKey(activeSlot), lastTrack, now, picker)
+				if hasBoundary && boundary.Mode == scheduler.StartSoft {
+					// Land the last track of the show close to the next one
+					queue = e.fitQueue(orgID, queue, slotKey(activeSlot), picker, lastTrack, now, boundary.At.Sub(now), boundary.Tolerance(activeSlot))
+				}
+				if item := e.popQueue(orgID, queue); item != nil {
+					selectedTrack = &item.Track
+					ruleSetID = item.RuleSetID
+					e.advanceCursor(item)
 				}
 			}
 
-			if err != nil || selectedTrack == nil {
+			if selectedTrack == nil {
 				selectedTrack, _ = selectors["random"].PickTrack(nil, nil)
+				ruleSetID = nil
 			}
 
 			if selectedTrack != nil && selectedTrack.ID != 0 && selectedTrack.Key != "" {
+				// Jingles, IDs and sweepers due at this boundary
+				opts := mixOptions(mount, activeSlot)
+				rules := e.loadInsertionRules(orgID)
+				talkOver, talkOverKey, talkOverGain := e.playInsertions(ctx, orgID, rules, imaging, activeSlot, mixer, opts, mountTarget(mount), live)
+				if live.IsLive() || ctx.Err() != nil {
+					heldTrack, heldRuleSetID = selectedTrack, ruleSetID
+					continue
+				}
+				opts.TalkOver, opts.TalkOverGainDB = talkOver, talkOverGain
+				opts.GainDB = loudnessGain(selectedTrack, mountTarget(mount))
+
 				e.state.UpdateTrack(orgID, selectedTrack.ID, 0)
 
-				e.cache.Prefetch([]string{selectedTrack.Key})
-				go e.cache.Cleanup([]string{selectedTrack.Key})
+				go e.prefetchQueue(orgID, selectedTrack, talkOverKey)
 
 				tracksPlayed.WithLabelValues(orgID.String()).Inc()
 
-				go e.updateNowPlaying(orgID, selectedTrack, getShowName(e.scheduler.GetCurrentSchedule(orgID)))
-				go e.recordTrackPlay(orgID, selectedTrack)
+				go e.updateNowPlaying(orgID, selectedTrack, getShowName(activeSlot))
+				startedAt := time.Now()
+				played := make(chan uint, 1)
+				go func(t *models.Track, ruleSetID *uint) {
+					played <- e.recordTrackPlay(orgID, t, ruleSetID, startedAt)
+				}(selectedTrack, ruleSetID)
 
 				lastTrack = selectedTrack
 
-				if err := e.streamFileToPipe(selectedTrack.Key, output); err != nil {
+				trackCtx, done := live.begin(ctx)
+				trackCtx, endItem := monitor.begin(trackCtx)
+				stopAtBoundary := func() {}
+				if hasBoundary && boundary.Mode == scheduler.StartHard {
+					// Hard start: fade whatever is playing out on the hour
+					trackCtx, stopAtBoundary = context.WithDeadline(trackCtx, boundary.At)
+				}
+				err := e.streamTrackToMixer(trackCtx, selectedTrack.Key, mixer, opts)
+				interrupted := trackCtx.Err() != nil // A live takeover, a hard start or dead air cut the track short
+				stopAtBoundary()
+				endItem()
+				done()
+				imaging.songPlayed(rules)
+
+				go e.finishTrackPlay(orgID, played, startedAt, time.Now())
+
+				if err != nil && !interrupted {
 					log.Printf("[%s] Pipe Stream Error: %v", orgID, err)
 					// If stream fails, sleep briefly before trying the next track
 					time.Sleep(1 * time.Second)
 				}
 			} else {
-				// ⚡️ FALLBACK: The tenant has no tracks in their library!
-				// Sleep to prevent an infinite CPU-burning loop.
+				// ⚡️ FALLBACK: The tenant has no tracks in their library (or the query failed)!
+				// Air the emergency loop, or sleep to prevent an infinite CPU-burning loop.
 				log.Printf("[%s] Orchestrator idle: No tracks available in library.", orgID)
-				time.Sleep(100 * time.Second)
+				if !e.playFallback(ctx, orgID, mixer, mountTarget(mount), live) {
+					time.Sleep(100 * time.Second)
+				}
 			}
 		}
 	}
 }
 
-func (e *Engine) pickNextFromPlaylist(orgID uuid.UUID, playlistID uint, lastTrack *models.Track) (*models.Track, error) {
-	var track models.Track
-	currentSortOrder := -1
-
-	if lastTrack != nil {
-		e.db.DB.Table("playlist_tracks").
-			Select("sort_order").
-			Where("playlist_id = ? AND track_id = ?", playlistID, lastTrack.ID).
-			Scan(&currentSortOrder)
-	}
-
-	err := e.db.DB.Model(&models.Track{}).
-		Joins("JOIN playlist_tracks ON playlist_tracks.track_id = tracks.id").
-		Where("playlist_tracks.playlist_id = ? AND tracks.organization_id = ? AND playlist_tracks.sort_order > ?", playlistID, orgID, currentSortOrder).
-		Preload("Artists").
-		Preload("Album").
-		Order("playlist_tracks.sort_order ASC").
-		First(&track).Error
-
-	if err != nil {
-		err = e.db.DB.Model(&models.Track{}).
-			Joins("JOIN playlist_tracks ON playlist_tracks.track_id = tracks.id").
-			Where("playlist_tracks.playlist_id = ? AND tracks.organization_id = ?", playlistID, orgID).
-			Preload("Artists").
-			Preload("Album").
-			Order("playlist_tracks.sort_order ASC").
-			First(&track).Error
-	}
-
-	return &track, err
-}
-
 func (e *Engine) updateNowPlaying(orgID uuid.UUID, t *models.Track, showName string) {
 	albumName := ""
 	if t.Album.Title != "" {
@@ -390,10 +551,13 @@ func (e *Engine) updateNowPlaying(orgID uuid.UUID, t *models.Track, showName str
 
 	destKey := fmt.Sprintf("%s/now_playing.json", orgID.String())
 	e.storage.UploadStreamFile(destKey, bytes.NewReader(data), "application/json", "max-age=0, no-cache")
+
+	e.setIcecastTitle(orgID, fmt.Sprintf("%s - %s", trackData.Artist, trackData.Title))
 }
 
-func (e *Engine) recordTrackPlay(orgID uuid.UUID, t *models.Track) {
-	now := time.Now()
+// recordTrackPlay bumps the track counters and returns the PlayHistory row id (0 on failure)
+func (e *Engine) recordTrackPlay(orgID uuid.UUID, t *models.Track, ruleSetID *uint, now time.Time) uint {
+	var history models.PlayHistory
 	err := e.db.DB.Transaction(func(tx *gorm.DB) error {
 		err := tx.Model(&models.Track{}).
 			Where("id = ? AND organization_id = ?", t.ID, orgID).
@@ -405,56 +569,124 @@ func (e *Engine) recordTrackPlay(orgID uuid.UUID, t *models.Track) {
 			return err
 		}
 
-		history := models.PlayHistory{
+		history = models.PlayHistory{
 			OrganizationID: orgID,
 			TrackID:        t.ID,
 			PlayedAt:       now,
+			RuleSetID:      ruleSetID,
 		}
 		return tx.Create(&history).Error
 	})
 	if err != nil {
 		log.Printf("[%s] Failed to record play: %v", orgID, err)
+		return 0
 	}
+	return history.ID
 }
 
-func (e *Engine) streamFileToPipe(key string, pipe *io.PipeWriter) error {
+func (e *Engine) streamTrackToMixer(ctx context.Context, key string, mixer *audio.Mixer, opts audio.MixOptions) error {
 	localPath, err := e.cache.GetLocalPath(key)
 	if err != nil {
 		return err
 	}
 
-	f, err := os.Open(localPath)
-	if err != nil {
-		return err
+	return mixer.Play(ctx, localPath, opts)
+}
+
+// mixOptions resolves the transition settings: mount point defaults, overridden by the on-air RuleSet
+func mixOptions(mount models.MountPoint, slot *models.ScheduleSlot) audio.MixOptions {
+	opts := audio.MixOptions{
+		Crossfade:   time.Duration(mount.CrossfadeSeconds * float64(time.Second)),
+		Curve:       mount.CrossfadeCurve,
+		TrimSilence: mount.TrimSilence,
 	}
-	defer f.Close()
 
-	_, err = io.Copy(pipe, f)
-	return err
+	if slot != nil && slot.RuleSet != nil {
+		if slot.RuleSet.CrossfadeSeconds != nil {
+			opts.Crossfade = time.Duration(*slot.RuleSet.CrossfadeSeconds * float64(time.Second))
+		}
+		if slot.RuleSet.CrossfadeCurve != "" {
+			opts.Curve = slot.RuleSet.CrossfadeCurve
+		}
+	}
+
+	return opts
+}
+
+// mountTarget is the integrated loudness (LUFS) a mount plays at
+func mountTarget(m models.MountPoint) float64 {
+	if m.TargetLoudness < 0 {
+		return m.TargetLoudness
+	}
+	return audio.DefaultTargetLUFS
 }
 
-func (e *Engine) startStreamUploader(ctx context.Context, orgID uuid.UUID, mountSlug string, dir string) {
+// parseKbps reads a bitrate setting like "128k"; 0 (the server's configured bitrate) when it is not one
+func parseKbps(bitrate string) int {
+	kbps, err := strconv.Atoi(strings.TrimSuffix(strings.ToLower(bitrate), "k"))
+	if err != nil || kbps <= 0 {
+		return 0
+	}
+	return kbps
+}
+
+// joinFilters chains the non-empty ffmpeg filter graphs
+func joinFilters(graphs ...string) string {
+	var parts []string
+	for _, g := range graphs {
+		if g != "" {
+			parts = append(parts, g)
+		}
+	}
+	return strings.Join(parts, ",")
+}
+
+// loudnessGain turns t to target LUFS; 0 for files that were never measured
+func loudnessGain(t *models.Track, target float64) float64 {
+	if t == nil || t.IntegratedLUFS == nil || t.TruePeak == nil {
+		return 0
+	}
+	return audio.LoudnessGain(audio.Loudness{Integrated: *t.IntegratedLUFS, TruePeak: *t.TruePeak}, target)
+}
+
+// startStreamUploader pushes a rendition's segments and playlist to storage. It returns nil once ctx
+// ends, or an error after maxUploadFailures failed uploads in a row.
+// Only the primary rendition records the HLS sequence used to resume after a restart.
+func (e *Engine) startStreamUploader(ctx context.Context, orgID uuid.UUID, h *renditionHealth, primary bool) error {
 	ticker := time.NewTicker(800 * time.Millisecond)
 	defer ticker.Stop()
 
 	var lastM3u8Time time.Time
 	uploadedSegments := make(map[string]bool)
-	seqRegex := regexp.MustCompile(`_(\d+)\.ts$`)
+	failures := 0
+
+	// Counts consecutive failures; a success resolves the uploader's incidents
+	uploaded := func(err error) error {
+		if err == nil {
+			failures = 0
+			e.resolveIncidents(orgID, h, models.StageUploader)
+			return nil
+		}
+		if failures++; failures >= maxUploadFailures {
+			return fmt.Errorf("%d uploads failed in a row: %w", failures, err)
+		}
+		return nil
+	}
 
 	for {
 		select {
 		case <-ctx.Done():
 			log.Printf("[%s] Stream local uploader mapping destroyed cleanly.", orgID)
-			return
+			return nil
 		case <-ticker.C:
-			files, err := os.ReadDir(dir)
+			files, err := os.ReadDir(h.dir)
 			if err != nil {
 				continue
 			}
 
 			for _, entry := range files {
 				filename := entry.Name()
-				fullPath := filepath.Join(dir, filename)
+				fullPath := filepath.Join(h.dir, filename)
 				info, err := entry.Info()
 				if err != nil {
 					continue
@@ -466,47 +698,59 @@ func (e *Engine) startStreamUploader(ctx context.Context, orgID uuid.UUID, mount
 
 				if filename == "stream.m3u8" {
 					if info.ModTime().After(lastM3u8Time) {
-						if err := e.uploadPlaylist(orgID, mountSlug, fullPath, filename); err == nil {
+						err := e.uploadPlaylist(orgID, h, fullPath, filename)
+						if err == nil {
 							lastM3u8Time = info.ModTime()
 						}
+						if fatal := uploaded(err); fatal != nil {
+							return fatal
+						}
 					}
 					continue
 				}
 
 				if strings.HasSuffix(filename, ".ts") && !uploadedSegments[filename] {
-					matches := seqRegex.FindStringSubmatch(filename)
+					matches := segmentSeqRegex.FindStringSubmatch(filename)
 					if len(matches) > 1 {
 						if seq, err := strconv.Atoi(matches[1]); err == nil {
-							e.state.IncrementSequence(orgID, seq)
+							h.observe(int64(seq), info.ModTime())
+							if primary {
+								e.state.IncrementSequence(orgID, seq)
+							}
 						}
 					}
 
-					if err := e.uploadSegment(orgID, mountSlug, fullPath, filename); err == nil {
+					err := e.uploadSegment(orgID, h.slug, fullPath, filename)
+					if err == nil {
 						uploadedSegments[filename] = true
 						os.Remove(fullPath)
 					}
+					if fatal := uploaded(err); fatal != nil {
+						return fatal
+					}
 				}
 			}
 
 			if len(uploadedSegments) > 100 {
-				e.cleanupUploadedMap(uploadedSegments, dir)
+				e.cleanupUploadedMap(uploadedSegments, h.dir)
 			}
 		}
 	}
 }
 
-func (e *Engine) uploadPlaylist(orgID uuid.UUID, mountSlug string, path, name string) error {
+// uploadPlaylist publishes the encoder's playlist with the restarts marked as discontinuities
+func (e *Engine) uploadPlaylist(orgID uuid.UUID, h *renditionHealth, path, name string) error {
 	timer := prometheus.NewTimer(uploadDuration.WithLabelValues("playlist"))
 	defer timer.ObserveDuration()
 
-	f, err := os.Open(path)
+	data, err := os.ReadFile(path)
 	if err != nil {
 		return err
 	}
-	defer f.Close()
+	data = audio.AddDiscontinuities(data, h.Breaks())
 
-	destKey := fmt.Sprintf("%s/%s/%s", orgID.String(), mountSlug, name)
-	err = e.storage.UploadStreamFile(destKey, f, "application/vnd.apple.mpegurl", "max-age=0, no-cache, no-store, must-revalidate")
+	destKey := fmt.Sprintf("%s/%s/%s", orgID.String(), h.slug, name)
+	err = e.storage.UploadStreamFile(destKey, bytes.NewReader(data), "application/vnd.apple.mpegurl", "max-age=0, no-cache, no-store, must-revalidate")
 	if err == nil {
 		uploadsTotal.WithLabelValues("playlist", orgID.String()).Inc()
 	}
@@ -540,7 +784,6 @@ func (e *Engine) cleanupUploadedMap(m map[string]bool, dir string) {
 }
 
 func (e *Engine) startRedirectServer() {
-	endpoint := strings.TrimRight(e.cfg.Storage.Endpoint, "/")
 	port := ":8080"
 
 	http.HandleFunc("/listen", func(w http.ResponseWriter, r *http.Request) {
@@ -555,10 +798,22 @@ func (e *Engine) startRedirectServer() {
 			mount = "radio"
 		}
 
-		publicURL := fmt.Sprintf("%s/%s/%s/%s/stream.m3u8", endpoint, e.cfg.Storage.BucketStream, orgID, mount)
-		http.Redirect(w, r, publicURL, http.StatusFound)
+		// The master playlist is resolved to a rendition by the player, sessions are counted from there on
+		if orgUUID, err := uuid.Parse(orgID); err == nil && mount != audio.MasterMount {
+			if !e.listeners.mountExists(orgUUID, mount) {
+				http.Error(w, "Unknown station or mount", http.StatusNotFound)
+				return
+			}
+			e.listeners.Touch(r, orgUUID, mount, SourceHLS, r.URL.Query().Get("sid"))
+		}
+
+		http.Redirect(w, r, e.listenURL(orgID, mount), http.StatusFound)
 	})
 
+	http.HandleFunc("/beacon", e.handleBeacon)
+	http.HandleFunc("GET /hls/{org_id}/{path...}", e.handleHLSProxy)
+	http.HandleFunc("GET /icecast/{org_id}/{mount}", e.handleIcecastListen)
+
 	http.Handle("/_metrics", promhttp.Handler())
 
 	log.Printf("Helper Server listening at %s", port)
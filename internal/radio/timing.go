@@ -0,0 +1,127 @@
+package radio
+
+import (
+	"log"
+	"time"
+
+	"github.com/google/uuid"
+
+	"momo-radio/internal/dj"
+	"momo-radio/internal/models"
+	"momo-radio/internal/scheduler"
+)
+
+const (
+	// boundaryHorizon is how far ahead the orchestrator looks for the next programme change
+	boundaryHorizon = time.Hour
+	// minFillGap is the shortest time left before a soft boundary that another track can still fill
+	minFillGap = 3 * time.Minute
+	// fitPickAttempts bounds extra selector calls when the queue has nothing that fits
+	fitPickAttempts = 5
+)
+
+func trackLength(t *models.Track) time.Duration {
+	if t == nil {
+		return dj.DefaultTrackLength
+	}
+	return dj.TrackLength(*t)
+}
+
+// slotProgress is how far into its airing slot is at `at`: 0 at the start, 1 at the end.
+// An early soft start counts as the start; the fallback programme sits in the middle.
+func slotProgress(slot *models.ScheduleSlot, at time.Time) float64 {
+	start, end, ok := scheduler.CurrentAiring(slot, at)
+	if !ok {
+		if next := scheduler.OccurrenceStart(slot, at); next.After(at) {
+			return 0
+		}
+		return 0.5
+	}
+	if !end.After(start) {
+		return 0
+	}
+	return min(max(float64(at.Sub(start))/float64(end.Sub(start)), 0), 1)
+}
+
+// resolveProgramme returns the slot to program for at now and the next change after it.
+// A soft start that is already within its tolerance begins now instead of overrunning the current show.
+func (e *Engine) resolveProgramme(orgID uuid.UUID, now time.Time) (*models.ScheduleSlot, scheduler.Boundary, bool) {
+	slot := e.scheduler.ScheduleAt(orgID, now)
+	b, ok := e.scheduler.NextBoundary(orgID, slot, now, boundaryHorizon)
+
+	if ok && b.Mode == scheduler.StartSoft && b.Slot != nil && b.At.Sub(now) <= b.Tolerance(slot) {
+		slot = b.Slot
+		b, ok = e.scheduler.NextBoundary(orgID, slot, now, boundaryHorizon)
+	}
+	return slot, b, ok
+}
+
+// fitCandidate asks the picker for a track airing at `at` that fills `remaining` before a soft boundary.
+// A playlist is only offered its next position: taking a later one would skip the ones in between for good.
+func fitCandidate(pick trackPicker, prev *models.Track, at time.Time, remaining, tol time.Duration) (queuePick, bool) {
+	var candidates []queuePick
+	var lengths []time.Duration
+
+	for i := 0; i < fitPickAttempts; i++ {
+		p, err := pick(prev, at)
+		if err != nil || p.track == nil || p.track.ID == 0 {
+			break
+		}
+		candidates = append(candidates, p)
+		lengths = append(lengths, trackLength(p.track))
+		if p.playlistIndex != nil {
+			break
+		}
+	}
+
+	if i := scheduler.ChooseFit(lengths, remaining, tol, minFillGap); i >= 0 {
+		return candidates[i], true
+	}
+	return queuePick{}, false
+}
+
+// fitQueue puts the queued track that best fills the time left before a soft boundary first,
+// adding a fresh pick ahead of the queue when nothing queued fits.
+func (e *Engine) fitQueue(orgID uuid.UUID, queue []models.QueueItem, slotKey string, pick trackPicker, prev *models.Track, now time.Time, remaining, tol time.Duration) []models.QueueItem {
+	lengths := make([]time.Duration, len(queue))
+	for i := range queue {
+		lengths[i] = trackLength(&queue[i].Track)
+	}
+
+	i := scheduler.ChooseFit(lengths, remaining, tol, minFillGap)
+	if i == 0 {
+		return queue
+	}
+	if i > 0 {
+		reordered := append([]models.QueueItem{queue[i]}, queue[:i]...)
+		return append(reordered, queue[i+1:]...)
+	}
+
+	picked, ok := fitCandidate(pick, prev, now, remaining, tol)
+	if !ok {
+		log.Printf("[%s] Soft start: nothing fits the remaining %s", orgID, remaining.Round(time.Second))
+		return queue
+	}
+
+	position := 0
+	if len(queue) > 0 {
+		position = queue[0].Position - 1
+	}
+	item := models.QueueItem{
+		OrganizationID: orgID,
+		Position:       position,
+		TrackID:        picked.track.ID,
+		Source:         models.QueueSourceAutoDJ,
+		SlotKey:        slotKey,
+		RuleSetID:      picked.ruleSetID,
+		CursorID:       picked.cursorID,
+		PlaylistIndex:  picked.playlistIndex,
+	}
+	if err := e.db.DB.Create(&item).Error; err != nil {
+		return queue
+	}
+	if err := e.db.DB.Preload("Artists").Preload("Album").First(&item.Track, picked.track.ID).Error; err != nil {
+		item.Track = *picked.track
+	}
+	return append([]models.QueueItem{item}, queue...)
+}
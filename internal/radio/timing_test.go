@@ -0,0 +1,37 @@
+package radio
+
+import (
+	"testing"
+	"time"
+
+	"momo-radio/internal/models"
+)
+
+func TestFitCandidateKeepsPlaylistOrder(t *testing.T) {
+	// Positions 0.. of a playlist: only the third track would fit the 5 minutes left
+	durations := []float64{600, 620, 290, 610, 640}
+	next := 0
+	pick := func(prev *models.Track, at time.Time) (queuePick, error) {
+		index := next
+		next++
+		return queuePick{track: &models.Track{ID: uint(index + 1), Duration: durations[index]}, playlistIndex: &index}, nil
+	}
+
+	if _, ok := fitCandidate(pick, nil, time.Now(), 5*time.Minute, time.Minute); ok {
+		t.Error("a later playlist position must not be taken to fit")
+	}
+	if next != 1 {
+		t.Errorf("picked %d playlist positions, want only the next one", next)
+	}
+
+	// Rule-based picks are free to be tried until one fits
+	next = 0
+	free := func(prev *models.Track, at time.Time) (queuePick, error) {
+		p, err := pick(prev, at)
+		p.playlistIndex = nil
+		return p, err
+	}
+	if p, ok := fitCandidate(free, nil, time.Now(), 5*time.Minute, time.Minute); !ok || p.track.ID != 3 {
+		t.Errorf("expected the third pick to fit, got %+v (%v)", p.track, ok)
+	}
+}
@@ -0,0 +1,349 @@
+package radio
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/prometheus/client_golang/prometheus"
+
+	"momo-radio/internal/audio"
+	"momo-radio/internal/models"
+)
+
+// Each mount of a running tenant has two supervised stages: its HLS encoder and its uploader.
+// A stage that exits, stalls or keeps failing is restarted with backoff and recorded as a PipelineIncident.
+// A restarted encoder carries on with the segment numbering, and the playlist marks the jump with
+// #EXT-X-DISCONTINUITY so players keep going.
+const (
+	restartBaseDelay = time.Second
+	restartMaxDelay  = 30 * time.Second
+	// stableAfter is how long a stage must have run for its next failure to start the backoff over
+	stableAfter = time.Minute
+	// maxUploadFailures in a row restart the uploader
+	maxUploadFailures = 5
+	watchdogInterval  = 2 * time.Second
+)
+
+var pipelineRestarts = prometheus.NewCounterVec(
+	prometheus.CounterOpts{Name: "radio_pipeline_restarts_total", Help: "Automatic restarts of a pipeline stage"},
+	[]string{"organization_id", "stage"},
+)
+
+// segmentSeqRegex reads the sequence number out of stream_<runID>_<seq>.ts
+var segmentSeqRegex = regexp.MustCompile(`_(\d+)\.ts$`)
+
+// restartDelay is the wait before the next restart after failures quick failures in a row
+func restartDelay(failures int) time.Duration {
+	if failures >= 5 {
+		return restartMaxDelay
+	}
+	return min(restartBaseDelay<<failures, restartMaxDelay)
+}
+
+// renditionHealth is what the watchdog knows about one mount's pipeline
+type renditionHealth struct {
+	slug string
+	dir  string
+
+	mu          sync.Mutex
+	lastSegment time.Time // Last time a segment file was written
+	highestSeq  int64     // Highest segment number produced so far
+	breaks      []int64   // First segment after each encoder restart
+	encoderUp   time.Time // When the running encoder started, zero while it is down
+	stopEncoder context.CancelFunc
+	stalled     bool            // The watchdog stopped the running encoder
+	open        map[string]bool // Stages with an unresolved incident
+}
+
+func newRenditionHealth(slug, dir string, startSequence int64) *renditionHealth {
+	return &renditionHealth{slug: slug, dir: dir, highestSeq: startSequence - 1, open: make(map[string]bool)}
+}
+
+// observe notes a segment file, from the directory scan or the uploader
+func (h *renditionHealth) observe(seq int64, modTime time.Time) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.highestSeq = max(h.highestSeq, seq)
+	if modTime.After(h.lastSegment) {
+		h.lastSegment = modTime
+	}
+}
+
+// scan picks up the segments the encoder is writing
+func (h *renditionHealth) scan() {
+	files, err := os.ReadDir(h.dir)
+	if err != nil {
+		return
+	}
+	for _, entry := range files {
+		matches := segmentSeqRegex.FindStringSubmatch(entry.Name())
+		if len(matches) < 2 {
+			continue
+		}
+		seq, err := strconv.ParseInt(matches[1], 10, 64)
+		info, infoErr := entry.Info()
+		if err == nil && infoErr == nil {
+			h.observe(seq, info.ModTime())
+		}
+	}
+}
+
+// idleFor is how long the running encoder has gone without writing a segment (0 while it is down)
+func (h *renditionHealth) idleFor() time.Duration {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if h.encoderUp.IsZero() {
+		return 0
+	}
+	if h.lastSegment.After(h.encoderUp) {
+		return time.Since(h.lastSegment)
+	}
+	return time.Since(h.encoderUp)
+}
+
+// recovered reports whether the encoder produced a segment since its last restart
+func (h *renditionHealth) recovered() bool {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return len(h.breaks) > 0 && h.highestSeq >= h.breaks[len(h.breaks)-1]
+}
+
+func (h *renditionHealth) encoderStarted(stop context.CancelFunc) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.encoderUp, h.stopEncoder, h.stalled = time.Now(), stop, false
+}
+
+// encoderStopped marks the encoder down and reports whether the watchdog stopped it
+func (h *renditionHealth) encoderStopped() bool {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.encoderUp, h.stopEncoder = time.Time{}, nil
+	return h.stalled
+}
+
+// stall stops the running encoder; its supervisor restarts it
+func (h *renditionHealth) stall() {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if h.stopEncoder != nil && !h.stalled {
+		h.stalled = true
+		h.stopEncoder()
+	}
+}
+
+// restartSequence is where a restarted encoder continues, recorded as a discontinuity
+func (h *renditionHealth) restartSequence() int64 {
+	h.scan()
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	next := h.highestSeq + 1
+	h.breaks = append(h.breaks, next)
+	return next
+}
+
+func (h *renditionHealth) Breaks() []int64 {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return append([]int64(nil), h.breaks...)
+}
+
+// setOpen flags (or clears) an unresolved incident on stage, returning the previous flag
+func (h *renditionHealth) setOpen(stage string, open bool) bool {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	was := h.open[stage]
+	h.open[stage] = open
+	return was
+}
+
+// superviseEncoder runs the mount's HLS encoder until ctx ends, restarting it whenever it exits or is stalled
+func (e *Engine) superviseEncoder(ctx context.Context, orgID uuid.UUID, m models.MountPoint, h *renditionHealth, output *fanoutWriter, startSequence int64, opts audio.EncoderOptions) {
+	seq := startSequence
+	failures := 0
+
+	for attempt := 0; ; {
+		pr, pw := io.Pipe()
+		output.Add(m.Slug, pw)
+
+		runCtx, stop := context.WithCancel(ctx)
+		h.encoderStarted(stop)
+		started := time.Now()
+
+		err := audio.StartStreamProcess(runCtx, pr, e.cfg, e.runID, seq, h.dir, m.Bitrate, opts)
+
+		stalled := h.encoderStopped()
+		stop()
+		// Fails the fanout's pending write, which drops this rendition until it is back
+		pr.CloseWithError(fmt.Errorf("encoder for mount '%s' exited", m.Slug))
+
+		if ctx.Err() != nil {
+			return
+		}
+
+		kind, detail := models.IncidentExit, "ffmpeg exited"
+		if err != nil {
+			detail = "ffmpeg exited: " + err.Error()
+		}
+		if stalled {
+			kind, detail = models.IncidentStall, fmt.Sprintf("no new segment for %s", e.stallWindow())
+		}
+
+		if time.Since(started) > stableAfter {
+			failures = 0
+		}
+		delay := restartDelay(failures)
+		failures++
+		attempt++
+
+		log.Printf("[%s] ⚠️ Encoder for mount '%s' down (%s), restarting in %s", orgID, m.Slug, detail, delay)
+		e.recordIncident(orgID, h, models.StageEncoder, kind, detail, attempt)
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(delay):
+		}
+		seq = h.restartSequence()
+	}
+}
+
+// superviseUploader runs the mount's uploader until ctx ends, restarting it when uploads keep failing
+func (e *Engine) superviseUploader(ctx context.Context, orgID uuid.UUID, h *renditionHealth, primary bool) {
+	failures := 0
+
+	for attempt := 0; ; {
+		started := time.Now()
+		err := e.startStreamUploader(ctx, orgID, h, primary)
+		if err == nil || ctx.Err() != nil {
+			return
+		}
+
+		if time.Since(started) > stableAfter {
+			failures = 0
+		}
+		delay := restartDelay(failures)
+		failures++
+		attempt++
+
+		log.Printf("[%s] ⚠️ Uploader for mount '%s' failing (%v), restarting in %s", orgID, h.slug, err, delay)
+		e.recordIncident(orgID, h, models.StageUploader, models.IncidentUploadFailures, err.Error(), attempt)
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(delay):
+		}
+	}
+}
+
+// watchStalls stops encoders that write no segments although the playout feeds them
+func (e *Engine) watchStalls(ctx context.Context, orgID uuid.UUID, renditions []*renditionHealth, output *fanoutWriter) {
+	window := e.stallWindow()
+	ticker := time.NewTicker(watchdogInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		// ⚡️ A hung encoder blocks the whole fanout: only the output it waits on is at fault
+		if slug, since := output.Blocked(); slug != "" && time.Since(since) > watchdogInterval {
+			if time.Since(since) > window {
+				e.stallOutput(orgID, slug, renditions, output)
+			}
+			continue
+		}
+
+		for _, h := range renditions {
+			h.scan()
+			if h.recovered() {
+				e.resolveIncidents(orgID, h, models.StageEncoder)
+			}
+			if output.Fed(window) && h.idleFor() > window {
+				log.Printf("[%s] ⚠️ Encoder for mount '%s' stalled: no segment for %s", orgID, h.slug, window)
+				h.stall()
+			}
+		}
+	}
+}
+
+// stallOutput restarts the encoder behind a blocked fanout output; other outputs (ICY) are dropped
+func (e *Engine) stallOutput(orgID uuid.UUID, slug string, renditions []*renditionHealth, output *fanoutWriter) {
+	for _, h := range renditions {
+		if h.slug == slug {
+			log.Printf("[%s] ⚠️ Encoder for mount '%s' stopped reading its input", orgID, slug)
+			h.stall()
+			return
+		}
+	}
+	output.Abort(slug, fmt.Errorf("output '%s' stopped reading", slug))
+}
+
+// stallWindow is how long an encoder may go without a new segment: stall_segments × segment_time
+func (e *Engine) stallWindow() time.Duration {
+	segments := e.cfg.Radio.StallSegments
+	if segments <= 0 {
+		segments = 3
+	}
+	segmentTime := e.cfg.Radio.SegmentTime
+	if segmentTime <= 0 {
+		segmentTime = 10
+	}
+	return time.Duration(segments*segmentTime) * time.Second
+}
+
+func (e *Engine) recordIncident(orgID uuid.UUID, h *renditionHealth, stage, kind, detail string, attempt int) {
+	pipelineRestarts.WithLabelValues(orgID.String(), stage).Inc()
+	h.setOpen(stage, true)
+
+	incident := models.PipelineIncident{
+		OrganizationID: orgID,
+		Mount:          h.slug,
+		Stage:          stage,
+		Kind:           kind,
+		Detail:         truncate(detail, 500),
+		Attempt:        attempt,
+	}
+	if err := e.db.DB.Create(&incident).Error; err != nil {
+		log.Printf("[%s] Failed to record pipeline incident: %v", orgID, err)
+	}
+}
+
+// closeStaleIncidents resolves incidents a previous run of the pipeline left open: it stopped or died
+// before it could see them recover, and the new run only tracks its own
+func (e *Engine) closeStaleIncidents(orgID uuid.UUID) {
+	err := e.db.DB.Model(&models.PipelineIncident{}).
+		Where("organization_id = ? AND resolved_at IS NULL", orgID).
+		Update("resolved_at", time.Now()).Error
+	if err != nil {
+		log.Printf("[%s] Failed to close stale pipeline incidents: %v", orgID, err)
+	}
+}
+
+// resolveIncidents closes the mount's open incidents of stage once it works again
+func (e *Engine) resolveIncidents(orgID uuid.UUID, h *renditionHealth, stage string) {
+	if !h.setOpen(stage, false) {
+		return
+	}
+
+	log.Printf("[%s] ✅ %s for mount '%s' recovered", orgID, strings.ToUpper(stage[:1])+stage[1:], h.slug)
+	err := e.db.DB.Model(&models.PipelineIncident{}).
+		Where("organization_id = ? AND mount = ? AND stage = ? AND resolved_at IS NULL", orgID, h.slug, stage).
+		Update("resolved_at", time.Now()).Error
+	if err != nil {
+		log.Printf("[%s] Failed to resolve pipeline incidents: %v", orgID, err)
+	}
+}
@@ -0,0 +1,44 @@
+package radio
+
+import (
+	"testing"
+	"time"
+)
+
+func TestRestartDelay(t *testing.T) {
+	cases := []struct {
+		failures int
+		want     time.Duration
+	}{
+		{0, time.Second},
+		{1, 2 * time.Second},
+		{4, 16 * time.Second},
+		{5, 30 * time.Second},
+		{60, 30 * time.Second},
+	}
+	for _, c := range cases {
+		if got := restartDelay(c.failures); got != c.want {
+			t.Errorf("restartDelay(%d) = %s, want %s", c.failures, got, c.want)
+		}
+	}
+}
+
+func TestRenditionHealthRestartSequence(t *testing.T) {
+	h := newRenditionHealth("radio", t.TempDir(), 10)
+	h.observe(14, time.Now())
+
+	if seq := h.restartSequence(); seq != 15 {
+		t.Fatalf("restart should continue after the last segment, got %d", seq)
+	}
+	if h.recovered() {
+		t.Error("no segment since the restart yet")
+	}
+
+	h.observe(15, time.Now())
+	if !h.recovered() {
+		t.Error("a segment after the restart means the encoder recovered")
+	}
+	if breaks := h.Breaks(); len(breaks) != 1 || breaks[0] != 15 {
+		t.Errorf("unexpected breaks %v", breaks)
+	}
+}
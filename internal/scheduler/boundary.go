@@ -0,0 +1,124 @@
+package scheduler
+
+import (
+	"time"
+
+	"momo-radio/internal/models"
+)
+
+// DefaultFitTolerance is how far a soft start may land from the scheduled time when the slot does not say
+const DefaultFitTolerance = 90 * time.Second
+
+// Boundary is the next moment the programme on air changes
+type Boundary struct {
+	At   time.Time
+	Mode string               // StartBoundary, StartHard or StartSoft
+	Slot *models.ScheduleSlot // The slot starting, nil when the current one ends into the fallback
+}
+
+// Tolerance is the soft-start window of the boundary
+func (b Boundary) Tolerance(current *models.ScheduleSlot) time.Duration {
+	seconds := 0
+	if b.Slot != nil {
+		seconds = b.Slot.FitToleranceSeconds
+	} else if current != nil {
+		seconds = current.FitToleranceSeconds
+	}
+	if seconds <= 0 {
+		return DefaultFitTolerance
+	}
+	return time.Duration(seconds) * time.Second
+}
+
+// CurrentAiring returns the start and end of the airing of slot that is on air at now
+func CurrentAiring(slot *models.ScheduleSlot, now time.Time) (time.Time, time.Time, bool) {
+	today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.UTC)
+
+	// Yesterday's airing may still be running past midnight
+	for _, a := range airings(slot, today.AddDate(0, 0, -1), today, now.Location()) {
+		if !now.Before(a.start) && now.Before(a.end) {
+			return a.start, a.end, true
+		}
+	}
+	return time.Time{}, time.Time{}, false
+}
+
+// OccurrenceStart returns the start of the airing of slot on air at now, or of its next airing within a day
+// (a soft start brought forward). Zero when neither exists.
+func OccurrenceStart(slot *models.ScheduleSlot, now time.Time) time.Time {
+	if start, _, ok := CurrentAiring(slot, now); ok {
+		return start
+	}
+	today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.UTC)
+	for _, a := range airings(slot, today, today.AddDate(0, 0, 1), now.Location()) {
+		if a.start.After(now) && a.start.Sub(now) <= 24*time.Hour {
+			return a.start
+		}
+	}
+	return time.Time{}
+}
+
+// NextBoundary finds the first programme change after now and within horizon: another slot
+// starting, or the current slot ending. A slot starting decides the mode; a slot ending into
+// nothing keeps its own mode. One-time slots win ties, as they do on air.
+func NextBoundary(slots []models.ScheduleSlot, current *models.ScheduleSlot, now time.Time, horizon time.Duration) (Boundary, bool) {
+	limit := now.Add(horizon)
+	today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.UTC)
+	lastDay := time.Date(limit.Year(), limit.Month(), limit.Day(), 0, 0, 0, 0, time.UTC)
+
+	var best Boundary
+	found := false
+
+	for i := range slots {
+		slot := &slots[i]
+		if !slot.IsActive || (current != nil && slot.ID == current.ID) {
+			continue
+		}
+		for _, a := range airings(slot, today, lastDay, now.Location()) {
+			if !a.start.After(now) || a.start.After(limit) {
+				continue
+			}
+			better := !found || a.start.Before(best.At) ||
+				(a.start.Equal(best.At) && slot.ScheduleType == TypeOneTime && (best.Slot == nil || best.Slot.ScheduleType != TypeOneTime))
+			if better {
+				best = Boundary{At: a.start, Mode: startMode(slot), Slot: slot}
+				found = true
+			}
+		}
+	}
+
+	// The show on air ending with nothing after it
+	if current != nil && current.ID != 0 {
+		if _, end, ok := CurrentAiring(current, now); ok && !end.After(limit) {
+			if !found || end.Before(best.At) {
+				best = Boundary{At: end, Mode: startMode(current)}
+				found = true
+			}
+		}
+	}
+	return best, found
+}
+
+func startMode(slot *models.ScheduleSlot) string {
+	if slot.StartMode == "" {
+		return StartBoundary
+	}
+	return slot.StartMode
+}
+
+// ChooseFit picks which of the candidate track lengths to play next so the programme reaches a soft
+// boundary `remaining` from now within tol. It prefers a track that lands on the boundary, then one
+// that leaves at least minGap to fill. -1 means no candidate helps.
+func ChooseFit(lengths []time.Duration, remaining, tol, minGap time.Duration) int {
+	for i, d := range lengths {
+		if diff := remaining - d; diff >= -tol && diff <= tol {
+			return i
+		}
+	}
+	for i, d := range lengths {
+		if remaining-d >= minGap {
+			return i
+		}
+	}
+	return -1
+}
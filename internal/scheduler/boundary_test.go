@@ -0,0 +1,68 @@
+package scheduler
+
+import (
+	"testing"
+	"time"
+
+	"momo-radio/internal/models"
+)
+
+func TestNextBoundary(t *testing.T) {
+	morning := models.ScheduleSlot{ID: 1, IsActive: true, ScheduleType: TypeRecurring, Days: "Mon", StartTime: "08:00", EndTime: "10:00", StartMode: StartSoft}
+	news := models.ScheduleSlot{ID: 2, IsActive: true, ScheduleType: TypeRecurring, Days: "Mon", StartTime: "10:00", EndTime: "10:30", StartMode: StartHard}
+	special := models.ScheduleSlot{ID: 3, IsActive: true, ScheduleType: TypeOneTime, Date: "2026-05-04", StartTime: "10:00", EndTime: "11:00"}
+
+	// 2026-05-04 is a Monday
+	now := time.Date(2026, 5, 4, 9, 50, 0, 0, time.UTC)
+
+	b, ok := NextBoundary([]models.ScheduleSlot{morning, news}, &morning, now, time.Hour)
+	if !ok || b.Slot == nil || b.Slot.ID != 2 || b.Mode != StartHard || !b.At.Equal(now.Add(10*time.Minute)) {
+		t.Fatalf("got %+v, want the hard news start at 10:00", b)
+	}
+
+	// A one-time special at the same instant takes the slot, and its own mode
+	b, _ = NextBoundary([]models.ScheduleSlot{morning, news, special}, &morning, now, time.Hour)
+	if b.Slot == nil || b.Slot.ID != 3 || b.Mode != StartBoundary {
+		t.Errorf("got %+v, want the one-time special", b)
+	}
+
+	// Nothing follows: the show ends into the fallback with its own mode
+	b, ok = NextBoundary([]models.ScheduleSlot{morning}, &morning, now, time.Hour)
+	if !ok || b.Slot != nil || b.Mode != StartSoft || b.At.Hour() != 10 {
+		t.Errorf("got %+v, want the soft end of the morning show", b)
+	}
+
+	// Outside the horizon
+	if _, ok := NextBoundary([]models.ScheduleSlot{morning, news}, &morning, now, 5*time.Minute); ok {
+		t.Error("expected no boundary within 5 minutes")
+	}
+}
+
+func TestChooseFit(t *testing.T) {
+	min := time.Minute
+	tol := 90 * time.Second
+	gap := 3 * min
+
+	// 10 minutes left: landing on time beats the 4 minute track, even though its 6 minutes could be filled
+	lengths := []time.Duration{4 * min, 9*min + 30*time.Second}
+	if got := ChooseFit(lengths, 10*min, tol, gap); got != 1 {
+		t.Errorf("ChooseFit = %d, want 1", got)
+	}
+
+	// 10 minutes left, nothing lands on time: the 8 minute track leaves 2 minutes, below the gap,
+	// so the 6 minute one goes first and leaves room for another track
+	lengths = []time.Duration{8 * min, 6 * min}
+	if got := ChooseFit(lengths, 10*min, tol, gap); got != 1 {
+		t.Errorf("ChooseFit = %d, want 1", got)
+	}
+
+	// Plenty of time left: the head of the queue is fine
+	if got := ChooseFit([]time.Duration{4 * min, 5 * min}, 40*min, tol, gap); got != 0 {
+		t.Errorf("ChooseFit = %d, want 0", got)
+	}
+
+	// Nothing fits
+	if got := ChooseFit([]time.Duration{8 * min}, 5*min, tol, gap); got != -1 {
+		t.Errorf("ChooseFit = %d, want -1", got)
+	}
+}
@@ -12,16 +12,26 @@ import (
 )
 
 type Manager struct {
-	db       *gorm.DB
-	timezone string
+	db        *gorm.DB
+	timezones *Timezones
 }
 
-// ⚡️ Require timezone on initialization
+// ⚡️ tz is the server-wide fallback; each tenant's own timezone takes precedence
 func NewManager(db *gorm.DB, tz string) *Manager {
 	if tz == "" {
 		tz = "UTC"
 	}
-	return &Manager{db: db, timezone: tz}
+	return &Manager{db: db, timezones: NewTimezones(db, tz)}
+}
+
+// InvalidateSettings forgets what is cached of the tenant's settings, after they changed
+func (m *Manager) InvalidateSettings(orgID uuid.UUID) {
+	m.timezones.Invalidate(orgID)
+}
+
+// Now is the current wall-clock time in the tenant's timezone
+func (m *Manager) Now(orgID uuid.UUID) time.Time {
+	return m.timezones.Now(orgID)
 }
 
 func extractHHMM(t string) string {
@@ -34,23 +44,19 @@ func extractHHMM(t string) string {
 }
 
 func (m *Manager) GetCurrentSchedule(orgID uuid.UUID) *models.ScheduleSlot {
-	loc, err := time.LoadLocation(m.timezone)
-	var now time.Time
+	return m.ScheduleAt(orgID, m.timezones.Now(orgID))
+}
 
-	if err != nil {
-		log.Printf("⚠️ Timezone error loading '%s': %v. Falling back to server default.", m.timezone, err)
-		now = time.Now()
-	} else {
-		now = time.Now().In(loc)
-	}
+// ScheduleAt resolves the slot on air at now (tenant wall clock), e.g. for simulations ahead of time
+func (m *Manager) ScheduleAt(orgID uuid.UUID, now time.Time) *models.ScheduleSlot {
+	now = now.In(m.timezones.Location(orgID))
 
-	todayDate := now.Format("2006-01-02")
 	todayDay := strings.ToLower(now.Weekday().String()[0:3])
 	currentTime := now.Format("15:04")
 
 	var schedules []models.ScheduleSlot
 
-	err = m.db.Preload("Playlist").Preload("RuleSet").
+	err := m.db.Preload("Playlist").Preload("RuleSet").
 		Where("organization_id = ? AND is_active = ?", orgID, true).
 		Find(&schedules).Error
 
@@ -64,22 +70,17 @@ func (m *Manager) GetCurrentSchedule(orgID uuid.UUID) *models.ScheduleSlot {
 	for i := range schedules {
 		slot := &schedules[i]
 
-		start := extractHHMM(slot.StartTime)
-		end := extractHHMM(slot.EndTime)
-
-		if !m.isTimeMatch(start, end, currentTime) {
+		// Handles midnight crossovers and recurring date ranges
+		if !SlotActiveAt(slot, now) {
 			continue
 		}
 
-		dbDate := strings.Split(slot.Date, "T")[0]
-
-		if slot.ScheduleType == "one_time" && dbDate == todayDate {
+		if slot.ScheduleType == TypeOneTime {
 			log.Printf("[%s] Scheduler: Playing One-Time Event (ID: %d)", orgID, slot.ID)
 			return slot
 		}
 
-		dbDays := strings.ToLower(slot.Days)
-		if slot.ScheduleType == "recurring" && strings.Contains(dbDays, todayDay) {
+		if slot.ScheduleType == TypeRecurring {
 			bestRecurringMatch = slot
 		}
 	}
@@ -89,10 +90,22 @@ func (m *Manager) GetCurrentSchedule(orgID uuid.UUID) *models.ScheduleSlot {
 		return bestRecurringMatch
 	}
 
-	log.Printf("[%s] Scheduler: No events match current time (%s %s %s). Triggering fallback AutoDJ.", orgID, m.timezone, todayDay, currentTime)
+	log.Printf("[%s] Scheduler: No events match current time (%s %s %s). Triggering fallback AutoDJ.", orgID, now.Location(), todayDay, currentTime)
 	return m.fallbackSchedule()
 }
 
+// NextBoundary returns the next programme change within horizon after now, if any
+func (m *Manager) NextBoundary(orgID uuid.UUID, current *models.ScheduleSlot, now time.Time, horizon time.Duration) (Boundary, bool) {
+	var slots []models.ScheduleSlot
+	err := m.db.Preload("Playlist").Preload("RuleSet").
+		Where("organization_id = ? AND is_active = ?", orgID, true).
+		Find(&slots).Error
+	if err != nil {
+		return Boundary{}, false
+	}
+	return NextBoundary(slots, current, now.In(m.timezones.Location(orgID)), horizon)
+}
+
 func (m *Manager) fallbackSchedule() *models.ScheduleSlot {
 	return &models.ScheduleSlot{
 		ScheduleType: "fallback",
@@ -103,13 +116,3 @@ func (m *Manager) fallbackSchedule() *models.ScheduleSlot {
 		},
 	}
 }
-
-func (m *Manager) isTimeMatch(start, end, current string) bool {
-	if start == "" || end == "" {
-		return false
-	}
-	if start <= end {
-		return current >= start && current < end
-	}
-	return current >= start || current < end
-}
@@ -0,0 +1,279 @@
+package scheduler
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"momo-radio/internal/models"
+)
+
+const (
+	DateLayout  = "2006-01-02"
+	ClockLayout = "15:04"
+
+	TypeOneTime   = "one_time"
+	TypeRecurring = "recurring"
+
+	StartBoundary = "boundary"
+	StartHard     = "hard"
+	StartSoft     = "soft"
+
+	PlaybackSequential      = "sequential"
+	PlaybackShuffleOnce     = "shuffle_once"
+	PlaybackShuffleEachLoop = "shuffle_each_loop"
+	PlaybackPlayOnce        = "play_once"
+)
+
+var weekdays = []string{"Mon", "Tue", "Wed", "Thu", "Fri", "Sat", "Sun"}
+
+// airing is one concrete broadcast of a slot, in station wall-clock time (stored as UTC)
+type airing struct {
+	start time.Time
+	end   time.Time
+}
+
+// NormalizeDays turns "wed, MON" into "Mon,Wed" and rejects anything that is not a weekday
+func NormalizeDays(days string) (string, error) {
+	set := make(map[string]bool)
+	for _, d := range strings.Split(days, ",") {
+		d = strings.TrimSpace(d)
+		if d == "" {
+			continue
+		}
+		if len(d) < 3 {
+			return "", fmt.Errorf("unknown weekday %q", d)
+		}
+		short := strings.ToUpper(d[:1]) + strings.ToLower(d[1:3])
+		known := false
+		for _, w := range weekdays {
+			if w == short {
+				known = true
+				break
+			}
+		}
+		if !known {
+			return "", fmt.Errorf("unknown weekday %q", d)
+		}
+		set[short] = true
+	}
+
+	var out []string
+	for _, w := range weekdays {
+		if set[w] {
+			out = append(out, w)
+		}
+	}
+	if len(out) == 0 {
+		return "", fmt.Errorf("at least one weekday is required")
+	}
+	return strings.Join(out, ","), nil
+}
+
+// ValidateSlot checks a slot is self-consistent before it is saved
+func ValidateSlot(slot *models.ScheduleSlot) error {
+	switch slot.PlaybackMode {
+	case "":
+		slot.PlaybackMode = PlaybackSequential
+	case PlaybackSequential, PlaybackShuffleOnce, PlaybackShuffleEachLoop, PlaybackPlayOnce:
+	default:
+		return fmt.Errorf("playback_mode must be sequential, shuffle_once, shuffle_each_loop or play_once")
+	}
+
+	// play_once is the only mode where a playlist slot also has a RuleSet: it takes over at the end
+	if slot.PlaylistID == nil && slot.RuleSetID == nil {
+		return fmt.Errorf("a slot needs either a playlist_id or a ruleset_id")
+	}
+	if slot.PlaylistID != nil && slot.RuleSetID != nil && slot.PlaybackMode != PlaybackPlayOnce {
+		return fmt.Errorf("a slot with a playlist can only have a ruleset_id with playback_mode play_once")
+	}
+	if _, err := time.Parse(ClockLayout, slot.StartTime); err != nil {
+		return fmt.Errorf("start_time must be HH:MM")
+	}
+	if _, err := time.Parse(ClockLayout, slot.EndTime); err != nil {
+		return fmt.Errorf("end_time must be HH:MM")
+	}
+
+	switch slot.ScheduleType {
+	case TypeOneTime:
+		if _, err := time.Parse(DateLayout, slotDate(slot.Date)); err != nil {
+			return fmt.Errorf("one_time slots need a date (YYYY-MM-DD)")
+		}
+	case TypeRecurring:
+		days, err := NormalizeDays(slot.Days)
+		if err != nil {
+			return err
+		}
+		slot.Days = days
+		for _, d := range []string{slot.StartDate, slot.EndDate} {
+			if d == "" {
+				continue
+			}
+			if _, err := time.Parse(DateLayout, d); err != nil {
+				return fmt.Errorf("start_date/end_date must be YYYY-MM-DD")
+			}
+		}
+		if slot.StartDate != "" && slot.EndDate != "" && slot.EndDate < slot.StartDate {
+			return fmt.Errorf("end_date is before start_date")
+		}
+	default:
+		return fmt.Errorf("schedule_type must be one_time or recurring")
+	}
+
+	switch slot.StartMode {
+	case "":
+		slot.StartMode = StartBoundary
+	case StartBoundary, StartHard, StartSoft:
+	default:
+		return fmt.Errorf("start_mode must be boundary, hard or soft")
+	}
+	if slot.FitToleranceSeconds < 0 || slot.FitToleranceSeconds > 600 {
+		return fmt.Errorf("fit_tolerance_seconds must be between 0 and 600")
+	}
+	return nil
+}
+
+// SlotsOverlap reports whether two slots of the same kind are ever on air at the same time.
+// A one-time slot is allowed over a recurring one: it deliberately pre-empts it for that date.
+func SlotsOverlap(a, b *models.ScheduleSlot) bool {
+	if a.ScheduleType != b.ScheduleType {
+		return false
+	}
+
+	// 1. Only the dates both slots can air on matter (plus one day either side for midnight crossovers)
+	loA, hiA := dateBounds(a)
+	loB, hiB := dateBounds(b)
+
+	lo := latest(loA, loB)
+	hi := earliest(hiA, hiB)
+	switch {
+	case lo.IsZero() && hi.IsZero():
+		// Both open-ended: the weekly pattern repeats, any week will do
+		lo = time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	case lo.IsZero():
+		// No start date: the last week before the earliest end date
+		lo = hi.AddDate(0, 0, -7)
+	}
+	lo = lo.AddDate(0, 0, -1)
+	if hi.IsZero() || hi.Sub(lo) > 10*24*time.Hour {
+		hi = lo.AddDate(0, 0, 10)
+	} else {
+		hi = hi.AddDate(0, 0, 1)
+	}
+
+	// 2. Compare every pair of concrete airings in that window
+	airingsB := airings(b, lo, hi, time.UTC)
+	for _, x := range airings(a, lo, hi, time.UTC) {
+		for _, y := range airingsB {
+			if x.start.Before(y.end) && y.start.Before(x.end) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// SlotActiveAt reports whether slot is on air at now, read in the location of now (the station's timezone).
+// Slot times are wall-clock: across a DST change a show keeps its local start/end and its real length changes.
+func SlotActiveAt(slot *models.ScheduleSlot, now time.Time) bool {
+	_, _, ok := CurrentAiring(slot, now)
+	return ok
+}
+
+// airings lists the broadcasts of slot starting on a date in [from, to], as instants in loc
+func airings(slot *models.ScheduleSlot, from, to time.Time, loc *time.Location) []airing {
+	start, err1 := time.Parse(ClockLayout, extractHHMM(slot.StartTime))
+	end, err2 := time.Parse(ClockLayout, extractHHMM(slot.EndTime))
+	if err1 != nil || err2 != nil {
+		return nil
+	}
+
+	// Crosses midnight, or start == end for a full day
+	endDay := 0
+	if !end.After(start) {
+		endDay = 1
+	}
+
+	var out []airing
+	for d := from; !d.After(to); d = d.AddDate(0, 0, 1) {
+		if !airsOn(slot, d) {
+			continue
+		}
+		out = append(out, airing{
+			start: wallClock(d, start, loc),
+			end:   wallClock(d.AddDate(0, 0, endDay), end, loc),
+		})
+	}
+	return out
+}
+
+// wallClock places clock on day in loc. A time skipped by a DST jump maps to the instant the clocks jumped.
+func wallClock(day, clock time.Time, loc *time.Location) time.Time {
+	t := time.Date(day.Year(), day.Month(), day.Day(), clock.Hour(), clock.Minute(), 0, 0, loc)
+	want := time.Date(day.Year(), day.Month(), day.Day(), clock.Hour(), clock.Minute(), 0, 0, time.UTC)
+	got := time.Date(t.Year(), t.Month(), t.Day(), t.Hour(), t.Minute(), 0, 0, time.UTC)
+	if got.Equal(want) {
+		return t
+	}
+
+	// Inside the gap: time.Date picked one side of the transition, snap to the transition itself
+	if got.After(want) {
+		transition, _ := t.ZoneBounds()
+		return transition
+	}
+	_, transition := t.ZoneBounds()
+	return transition
+}
+
+func airsOn(slot *models.ScheduleSlot, day time.Time) bool {
+	date := day.Format(DateLayout)
+
+	switch slot.ScheduleType {
+	case TypeOneTime:
+		return slotDate(slot.Date) == date
+	case TypeRecurring:
+		if !strings.Contains(strings.ToLower(slot.Days), strings.ToLower(day.Weekday().String()[0:3])) {
+			return false
+		}
+		if slot.StartDate != "" && date < slot.StartDate {
+			return false
+		}
+		if slot.EndDate != "" && date > slot.EndDate {
+			return false
+		}
+		return true
+	}
+	return false
+}
+
+// dateBounds returns the first and last date a slot can air on; zero when open-ended
+func dateBounds(slot *models.ScheduleSlot) (time.Time, time.Time) {
+	if slot.ScheduleType == TypeOneTime {
+		d, _ := time.Parse(DateLayout, slotDate(slot.Date))
+		return d, d
+	}
+	lo, _ := time.Parse(DateLayout, slot.StartDate)
+	hi, _ := time.Parse(DateLayout, slot.EndDate)
+	return lo, hi
+}
+
+func slotDate(date string) string {
+	return strings.Split(date, "T")[0]
+}
+
+func latest(a, b time.Time) time.Time {
+	if a.After(b) {
+		return a
+	}
+	return b
+}
+
+func earliest(a, b time.Time) time.Time {
+	if a.IsZero() {
+		return b
+	}
+	if b.IsZero() || a.Before(b) {
+		return a
+	}
+	return b
+}
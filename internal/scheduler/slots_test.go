@@ -0,0 +1,105 @@
+package scheduler
+
+import (
+	"testing"
+	"time"
+
+	"momo-radio/internal/models"
+)
+
+func TestSlotsOverlap(t *testing.T) {
+	weekly := func(days, start, end string) *models.ScheduleSlot {
+		return &models.ScheduleSlot{ScheduleType: TypeRecurring, Days: days, StartTime: start, EndTime: end}
+	}
+	once := func(date, start, end string) *models.ScheduleSlot {
+		return &models.ScheduleSlot{ScheduleType: TypeOneTime, Date: date, StartTime: start, EndTime: end}
+	}
+
+	cases := []struct {
+		name string
+		a, b *models.ScheduleSlot
+		want bool
+	}{
+		{"same day overlap", weekly("Mon,Wed", "10:00", "12:00"), weekly("Wed", "11:00", "13:00"), true},
+		{"back to back", weekly("Mon", "10:00", "12:00"), weekly("Mon", "12:00", "14:00"), false},
+		{"different days", weekly("Mon", "10:00", "12:00"), weekly("Tue", "10:00", "12:00"), false},
+		{"midnight crossover", weekly("Mon", "23:00", "01:00"), weekly("Tue", "00:30", "02:00"), true},
+		{"disjoint date ranges", &models.ScheduleSlot{ScheduleType: TypeRecurring, Days: "Mon", StartTime: "10:00", EndTime: "12:00", EndDate: "2026-03-01"},
+			&models.ScheduleSlot{ScheduleType: TypeRecurring, Days: "Mon", StartTime: "10:00", EndTime: "12:00", StartDate: "2026-03-02"}, false},
+		{"end dates only, before 2024", &models.ScheduleSlot{ScheduleType: TypeRecurring, Days: "Mon", StartTime: "10:00", EndTime: "12:00", EndDate: "2023-06-30"},
+			&models.ScheduleSlot{ScheduleType: TypeRecurring, Days: "Mon", StartTime: "11:00", EndTime: "13:00", EndDate: "2023-03-01"}, true},
+		{"one-time same date", once("2026-05-04", "20:00", "22:00"), once("2026-05-04", "21:00", "23:00"), true},
+		{"one-time into next day", once("2026-05-04", "23:00", "02:00"), once("2026-05-05", "01:00", "03:00"), true},
+		{"one-time pre-empts recurring", once("2026-05-04", "10:00", "12:00"), weekly("Mon", "10:00", "12:00"), false},
+	}
+
+	for _, tc := range cases {
+		if got := SlotsOverlap(tc.a, tc.b); got != tc.want {
+			t.Errorf("%s: SlotsOverlap = %v, want %v", tc.name, got, tc.want)
+		}
+		if got := SlotsOverlap(tc.b, tc.a); got != tc.want {
+			t.Errorf("%s (swapped): SlotsOverlap = %v, want %v", tc.name, got, tc.want)
+		}
+	}
+}
+
+func TestSlotActiveAtAfterMidnight(t *testing.T) {
+	slot := &models.ScheduleSlot{ScheduleType: TypeRecurring, Days: "Mon", StartTime: "23:00", EndTime: "02:00"}
+
+	// 2026-05-05 is a Tuesday: the Monday night show is still on air
+	if !SlotActiveAt(slot, time.Date(2026, 5, 5, 1, 30, 0, 0, time.UTC)) {
+		t.Error("expected Monday's show to still be on air at Tue 01:30")
+	}
+	if SlotActiveAt(slot, time.Date(2026, 5, 5, 23, 30, 0, 0, time.UTC)) {
+		t.Error("show should not air on Tuesday night")
+	}
+}
+
+func TestNormalizeDays(t *testing.T) {
+	got, err := NormalizeDays("sun, MON,wednesday")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got != "Mon,Wed,Sun" {
+		t.Errorf("NormalizeDays = %q, want %q", got, "Mon,Wed,Sun")
+	}
+	if _, err := NormalizeDays("Funday"); err == nil {
+		t.Error("expected an error for an unknown weekday")
+	}
+}
+
+func TestSlotActiveAtAcrossDST(t *testing.T) {
+	paris, err := time.LoadLocation("Europe/Paris")
+	if err != nil {
+		t.Skip("tzdata not available:", err)
+	}
+
+	// 2026-03-29: clocks jump from 02:00 to 03:00
+	gapShow := &models.ScheduleSlot{ScheduleType: TypeRecurring, Days: "Sun", StartTime: "02:30", EndTime: "04:00"}
+	if !SlotActiveAt(gapShow, time.Date(2026, 3, 29, 3, 0, 0, 0, paris)) {
+		t.Error("a show starting inside the DST gap should start when the clocks jump")
+	}
+	if SlotActiveAt(gapShow, time.Date(2026, 3, 29, 4, 0, 0, 0, paris)) {
+		t.Error("the show should still end at 04:00 local time")
+	}
+
+	// 2026-10-25: 02:00-03:00 happens twice, an overnight show runs an hour longer
+	overnight := &models.ScheduleSlot{ScheduleType: TypeRecurring, Days: "Sat", StartTime: "22:00", EndTime: "06:00"}
+	if !SlotActiveAt(overnight, time.Date(2026, 10, 25, 5, 30, 0, 0, paris)) {
+		t.Error("overnight show should still be on air at Sun 05:30 after the fall-back")
+	}
+
+	// Evaluated in Tokyo, the same instant is a different local day and time
+	tokyo, err := time.LoadLocation("Asia/Tokyo")
+	if err != nil {
+		t.Skip("tzdata not available:", err)
+	}
+	morning := &models.ScheduleSlot{ScheduleType: TypeRecurring, Days: "Mon", StartTime: "08:00", EndTime: "10:00"}
+	instant := time.Date(2026, 5, 4, 0, 30, 0, 0, time.UTC) // Mon 09:30 in Tokyo, Mon 02:30 in Paris
+	if !SlotActiveAt(morning, instant.In(tokyo)) {
+		t.Error("expected the show to be on air in Tokyo")
+	}
+	if SlotActiveAt(morning, instant.In(paris)) {
+		t.Error("did not expect the show to be on air in Paris")
+	}
+}
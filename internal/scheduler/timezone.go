@@ -0,0 +1,76 @@
+package scheduler
+
+import (
+	"log"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+
+	"momo-radio/internal/models"
+)
+
+// timezoneCacheTTL bounds how long a settings change takes to reach a running engine
+const timezoneCacheTTL = 5 * time.Minute
+
+// Timezones resolves each organization's own timezone (OrganizationSettings.Timezone),
+// falling back to the server-wide one when unset or invalid.
+type Timezones struct {
+	db       *gorm.DB
+	fallback *time.Location
+
+	mu    sync.RWMutex
+	cache map[uuid.UUID]cachedZone
+}
+
+type cachedZone struct {
+	loc     *time.Location
+	fetched time.Time
+}
+
+func NewTimezones(db *gorm.DB, fallback string) *Timezones {
+	loc, err := time.LoadLocation(fallback)
+	if err != nil {
+		log.Printf("⚠️ Timezone error loading '%s': %v. Falling back to UTC.", fallback, err)
+		loc = time.UTC
+	}
+	return &Timezones{db: db, fallback: loc, cache: make(map[uuid.UUID]cachedZone)}
+}
+
+// Location returns the tenant's timezone
+func (t *Timezones) Location(orgID uuid.UUID) *time.Location {
+	t.mu.RLock()
+	entry, ok := t.cache[orgID]
+	t.mu.RUnlock()
+	if ok && time.Since(entry.fetched) < timezoneCacheTTL {
+		return entry.loc
+	}
+
+	loc := t.fallback
+	var settings models.OrganizationSettings
+	if err := t.db.Select("organization_id", "timezone").Where("organization_id = ?", orgID).Limit(1).Find(&settings).Error; err == nil && settings.Timezone != "" {
+		if l, err := time.LoadLocation(settings.Timezone); err == nil {
+			loc = l
+		} else {
+			log.Printf("[%s] ⚠️ Unknown timezone '%s' in settings, using %s", orgID, settings.Timezone, t.fallback)
+		}
+	}
+
+	t.mu.Lock()
+	t.cache[orgID] = cachedZone{loc: loc, fetched: time.Now()}
+	t.mu.Unlock()
+	return loc
+}
+
+// Now is the current time on the tenant's wall clock
+func (t *Timezones) Now(orgID uuid.UUID) time.Time {
+	return time.Now().In(t.Location(orgID))
+}
+
+// Invalidate drops the cached zone after the tenant changed its settings
+func (t *Timezones) Invalidate(orgID uuid.UUID) {
+	t.mu.Lock()
+	delete(t.cache, orgID)
+	t.mu.Unlock()
+}
@@ -101,6 +101,10 @@ func (c *Client) UploadStreamFile(key string, body io.ReadSeeker, contentType, c
 	return c.backend.Put(c.bucketStream, key, body, contentType, cacheControl)
 }
 
+func (c *Client) DownloadStreamFile(key string) (*FileObject, error) {
+	return c.backend.Get(c.bucketStream, key)
+}
+
 func (c *Client) UploadAssetFile(key string, body io.ReadSeeker, contentType, cacheControl string) error {
 	return c.backend.Put(c.bucketAssets, key, body, contentType, cacheControl)
 }
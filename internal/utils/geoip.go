@@ -0,0 +1,102 @@
+package utils
+
+import (
+	"bytes"
+	"encoding/csv"
+	"fmt"
+	"io"
+	"net/netip"
+	"os"
+	"sort"
+	"strings"
+)
+
+// GeoIPDB resolves IP addresses to ISO country codes from a local range file,
+// so listener lookups never leave the box.
+// The file is a CSV of "start_ip,end_ip,country_code" rows (e.g. DB-IP "IP to Country Lite"),
+// IPv4 and IPv6 ranges can be mixed.
+type GeoIPDB struct {
+	ranges []ipRange
+}
+
+type ipRange struct {
+	start   [16]byte
+	end     [16]byte
+	country string
+}
+
+func LoadGeoIPDB(path string) (*GeoIPDB, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return ParseGeoIPDB(f)
+}
+
+func ParseGeoIPDB(r io.Reader) (*GeoIPDB, error) {
+	reader := csv.NewReader(r)
+	reader.FieldsPerRecord = -1
+	reader.ReuseRecord = true
+
+	db := &GeoIPDB{}
+	for line := 1; ; line++ {
+		record, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %w", line, err)
+		}
+		if len(record) < 3 {
+			continue
+		}
+
+		start, err1 := netip.ParseAddr(strings.TrimSpace(record[0]))
+		end, err2 := netip.ParseAddr(strings.TrimSpace(record[1]))
+		if err1 != nil || err2 != nil {
+			// Header row or garbage, skip it
+			continue
+		}
+
+		db.ranges = append(db.ranges, ipRange{
+			start:   start.As16(),
+			end:     end.As16(),
+			country: strings.ToUpper(strings.TrimSpace(record[2])),
+		})
+	}
+
+	sort.Slice(db.ranges, func(i, j int) bool {
+		return bytes.Compare(db.ranges[i].start[:], db.ranges[j].start[:]) < 0
+	})
+	return db, nil
+}
+
+// Country returns the ISO 3166 alpha-2 code for ip, or "" when it is unknown.
+func (db *GeoIPDB) Country(ip string) string {
+	if db == nil || len(db.ranges) == 0 {
+		return ""
+	}
+	addr, err := netip.ParseAddr(ip)
+	if err != nil {
+		return ""
+	}
+	key := addr.As16()
+
+	// Last range starting at or before the address
+	i := sort.Search(len(db.ranges), func(i int) bool {
+		return bytes.Compare(db.ranges[i].start[:], key[:]) > 0
+	}) - 1
+	if i < 0 || bytes.Compare(key[:], db.ranges[i].end[:]) > 0 {
+		return ""
+	}
+	return db.ranges[i].country
+}
+
+// Len is the number of ranges loaded.
+func (db *GeoIPDB) Len() int {
+	if db == nil {
+		return 0
+	}
+	return len(db.ranges)
+}
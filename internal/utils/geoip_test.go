@@ -0,0 +1,36 @@
+package utils
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestGeoIPDBCountry(t *testing.T) {
+	csv := `start_ip,end_ip,country
+1.0.0.0,1.0.0.255,AU
+8.8.8.0,8.8.8.255,us
+2001:db8::,2001:db8::ffff,FR
+`
+	db, err := ParseGeoIPDB(strings.NewReader(csv))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if db.Len() != 3 {
+		t.Fatalf("loaded %d ranges, want 3", db.Len())
+	}
+
+	cases := map[string]string{
+		"1.0.0.7":        "AU",
+		"8.8.8.8":        "US",
+		"8.8.9.1":        "",
+		"2001:db8::42":   "FR",
+		"2001:db9::1":    "",
+		"not-an-ip":      "",
+		"::ffff:1.0.0.1": "AU",
+	}
+	for ip, want := range cases {
+		if got := db.Country(ip); got != want {
+			t.Errorf("Country(%q) = %q, want %q", ip, got, want)
+		}
+	}
+}